@@ -2,20 +2,48 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"ProxyMaster_v2/internal/config"
 	"ProxyMaster_v2/internal/database"
+	httpdelivery "ProxyMaster_v2/internal/delivery/http"
 	"ProxyMaster_v2/internal/delivery/telegram"
 	"ProxyMaster_v2/internal/domain"
 	"ProxyMaster_v2/internal/domain/telegrambot"
+	"ProxyMaster_v2/internal/infrastructure/content"
+	"ProxyMaster_v2/internal/infrastructure/i18n"
+	"ProxyMaster_v2/internal/infrastructure/mail"
+	"ProxyMaster_v2/internal/infrastructure/metrics"
 	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/infrastructure/tracing"
+	"ProxyMaster_v2/internal/payments"
+	"ProxyMaster_v2/internal/payments/cryptopay"
+	"ProxyMaster_v2/internal/payments/stars"
 	"ProxyMaster_v2/internal/service"
 	"ProxyMaster_v2/pkg/logger"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
+// settingsReloadInterval как часто перечитывать настройки из DB
+const settingsReloadInterval = time.Minute
+
+// trafficCheckInterval как часто проверять трафик пользователей для предупреждений
+const trafficCheckInterval = 10 * time.Minute
+
+// paymentRetryInterval как часто повторять зачисление полученных, но не зачисленных оплат
+const paymentRetryInterval = time.Minute
+
 // Application главный интерфейс приложения
 type Application interface {
 	Run()
@@ -25,23 +53,71 @@ type Application interface {
 type app struct {
 	remnawaveClient domain.RemnawaveClient
 	telegramClient  *telegram.Client
+	// cryptoWebhook сервер для уведомлений Crypto Pay, nil если выключен
+	cryptoWebhook *http.Server
+	// httpServers сайт, admin API, метрики и проверки здоровья.
+	// Части на одном адресе работают на одном сервере
+	httpServers []*http.Server
+	// shutdownTracing досылает накопленные спаны при остановке
+	shutdownTracing func(context.Context) error
+	// logLevels уровни логов, меняются сигналами
+	logLevels *logger.Levels
+	// settings настройки из DB, перечитываются по таймеру
+	settings *service.SettingsService
+	// traffic проверяет трафик и предупреждает пользователей
+	traffic *service.TrafficService
+	// payments повторяет зачисление оплат, которые не зачислились сразу
+	payments *service.PaymentService
 	// plategaClient   *platega.Client
+
+	logger logger.Logger
 }
 
 // New собирает приложение
 func New() (Application, error) {
-	// ===конфиг .env===
+	// ===конфиг: config.yaml + env===
+	// Все проблемы конфига выводятся разом, до старта чего-либо
 	cfg, err := config.New()
 	if err != nil {
 		return nil, fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
 	// ===logger===
+	// Уровни по модулям можно менять на ходу: admin API или сигналами (см. watchLogLevelSignals)
+	logLevels, err := logger.NewLevels(cfg.Logger.Level, cfg.Logger.Levels)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора уровней логов: %w", err)
+	}
+
 	// Инициализируем главный логгер.
-	loggerClient, err := logger.New(cfg.LoggerLevel)
+	loggerClient, err := logger.New(logger.Options{
+		Mode:             cfg.Logger.Mode,
+		Levels:           logLevels,
+		File:             cfg.Logger.File,
+		FileMaxSizeMB:    cfg.Logger.FileMaxSizeMB,
+		FileMaxBackups:   cfg.Logger.FileMaxBackups,
+		FileMaxAgeDays:   cfg.Logger.FileMaxAgeDays,
+		SampleInitial:    cfg.Logger.SampleInitial,
+		SampleThereafter: cfg.Logger.SampleThereafter,
+		Secrets:          cfg.Secrets(),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("ошибка инициализации логгера: %w", err)
 	}
+	if !cfg.EnvFileLoaded {
+		loggerClient.Info("не удалось загрузить .env, берем только переменные окружения")
+	}
+	if cfg.File != "" {
+		loggerClient.Info("настройки загружены из файла", logger.Field{Key: "file", Value: cfg.File})
+	}
+
+	// Библиотеки пишут через slog, стандартный log или свой логгер (telegram-bot-api).
+	// Перенаправляем все в zap, чтобы в Loki попадал только структурированный JSON
+	slogHandler := logger.NewSlogHandler(loggerClient.Named("lib"))
+	slog.SetDefault(slog.New(slogHandler))
+	if err := tgbotapi.SetLogger(slog.NewLogLogger(slogHandler, slog.LevelInfo)); err != nil {
+		return nil, fmt.Errorf("ошибка подключения логгера telegram-bot-api: %w", err)
+	}
 
 	// Создаем logger для remnawave
 	remnawaveLogger := loggerClient.Named("remnawave")
@@ -49,54 +125,472 @@ func New() (Application, error) {
 	subscriptionLogger := loggerClient.Named("subscription")
 	// Для платежной системы
 	// plategaLogger := loggerClient.Named("platega")
+	// Для пополнения баланса
+	paymentLogger := loggerClient.Named("payment")
+	// Для телеграм бота
+	telegramLogger := loggerClient.Named("telegram")
+	// Для сайта
+	webLogger := loggerClient.Named("web")
+	// Для admin API
+	adminLogger := loggerClient.Named("admin")
+	// Для DB
+	databaseLogger := loggerClient.Named("database")
+
+	// ===tracing===
+	// Без OTEL_EXPORTER_OTLP_ENDPOINT спаны создаются, но никуда не уходят
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio, loggerClient.Named("tracing"))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка инициализации трейсинга: %w", err)
+	}
+
+	// ===metrics===
+	// Prometheus включается METRICS_LISTEN, без него события метрик никуда не уходят
+	var appMetrics domain.Metrics = domain.NopMetrics{}
+	var prometheusMetrics *metrics.Prometheus
+	if cfg.Metrics.Listen != "" {
+		prometheusMetrics = metrics.New(loggerClient.Named("metrics"))
+		appMetrics = prometheusMetrics
+	}
 
 	// ===remnawave===
-	remnawaveClient := remnawave.NewRemnaClient(cfg, remnawaveLogger)
+	remnawaveClient := remnawave.NewRemnaClient(cfg.Remnawave, remnawaveLogger)
+	remnawaveClient.SetMetrics(appMetrics)
 
 	// ===DB===
-	db, err := database.Connect(cfg.DatabaseURL)
+	db, err := database.Connect(cfg.Database.URL, databaseLogger)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка подключения к базе данных: %w", err)
 	}
 
 	// repository
-	userRepo := database.NewUserStorage(db)
+	userRepo := database.NewUserStorage(db, databaseLogger)
+	botStateRepo := database.NewBotStateStorage(db, databaseLogger)
+	transactionRepo := database.NewTransactionStorage(db, databaseLogger)
+	orderRepo := database.NewOrderStorage(db, databaseLogger)
+	adjustmentRepo := database.NewBalanceAdjustmentStorage(db, databaseLogger)
+	settingsRepo := database.NewSettingsStorage(db, databaseLogger)
+
+	// ===settings===
+	// Поддержка, цена, админы и т.п. меняются командой /set без рестарта.
+	// Значения из конфига действуют, пока админ их не поменял
+	settingsLogger := loggerClient.Named("settings")
+	settingsService, err := service.NewSettingsService(settingsRepo, map[string]string{
+		domain.SettingSupport:            cfg.Telegram.Support,
+		domain.SettingAdminIDs:           joinIDs(cfg.Telegram.AdminIDs),
+		domain.SettingMaintenance:        strconv.FormatBool(cfg.Maintenance.Enabled),
+		domain.SettingMaintenanceMessage: cfg.Maintenance.Message,
+	}, settingsLogger)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка инициализации настроек: %w", err)
+	}
+	if err := settingsService.Load(context.Background()); err != nil {
+		// Бот работает и без таблицы настроек, просто на значениях из конфига
+		settingsLogger.Warn("настройки из DB не загружены, работают значения по умолчанию", logger.Field{Key: "error", Value: err})
+	}
+	if settingsService.Maintenance() {
+		settingsLogger.Warn("включен режим техработ: покупки и пополнения приостановлены")
+	}
+	// Переключение техработ заметно в логах, даже если его сделала другая копия бота
+	settingsService.OnChange(func(key, value string) {
+		if key == domain.SettingMaintenance {
+			settingsLogger.Warn("режим техработ переключен", logger.Field{Key: "value", Value: value})
+		}
+	})
+
+	// ===i18n===
+	// Тексты бота. Если указана папка - берем каталоги из нее, иначе вшитые
+	translator, err := loadTranslator(cfg.Content.I18nDir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки переводов: %w", err)
+	}
+
+	// Шаблоны редактируемых текстов (приветствие, информация, соглашение).
+	// Папку можно править на живом боте и применить командой /reload_content
+	contentStore, err := loadContent(cfg.Content.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки шаблонов текстов: %w", err)
+	}
 
 	// ===services===
-	subService := service.NewSubscriptionService(remnawaveClient, userRepo, subscriptionLogger)
+	subService := service.NewSubscriptionService(remnawaveClient, userRepo, settingsService, subscriptionLogger)
+	subService.SetMetrics(appMetrics)
+	// Регионы - внутренние сквады панели, пользователь выбирает их в боте
+	regionService := service.NewRegionService(remnawaveClient, userRepo, subscriptionLogger)
+	deviceService := service.NewDeviceService(remnawaveClient, userRepo, settingsService, subscriptionLogger)
+	deviceService.SetMetrics(appMetrics)
+	trafficService := service.NewTrafficService(remnawaveClient, userRepo, settingsService, subscriptionLogger)
+	trafficService.SetMetrics(appMetrics)
+	linkService := service.NewLinkService(remnawaveClient, subscriptionLogger)
+	trialService := service.NewTrialService(remnawaveClient, userRepo, settingsService, subscriptionLogger)
 
 	// ===telegram bot===
 	// инициализация
-	botAPI, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	botAPI, err := tgbotapi.NewBotAPI(cfg.Telegram.Token)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка инициализации бота: %w", err)
 	}
 
 	// запускаем бота
-	telegramClient := telegram.NewClient(botAPI)
+	telegramClient := telegram.NewClient(botAPI, telegramLogger)
+	// offset обновлений храним в DB, чтобы после рестарта не обрабатывать их повторно
+	telegramClient.SetStateRepository(botStateRepo)
+
+	// middleware вокруг всех команд и кнопок. Порядок важен: первый - самый внешний
+	telegramClient.Use(
+		telegram.Tracing(),
+		telegram.Recover(telegramLogger),
+		telegram.Logging(telegramLogger),
+		telegram.Metrics(appMetrics),
+		telegram.RateLimit(translator, 2, 10),
+		telegram.RegisterUser(userRepo, telegramLogger),
+		telegram.BanCheck(userRepo, telegramLogger),
+		telegram.AdminOnly(translator, settingsService, "reload_content", "refund", "settings", "set", "maintenance"),
+		// Во время техработ меню работает, а покупка и пополнение - нет.
+		// Проверка оплаты остается: деньги за уже выставленный счет надо зачислить
+		telegram.Maintenance(translator, settingsService,
+			telegram.RouteTariff.Name(),
+			telegram.RouteTrial.Name(),
+			telegram.RouteDeviceBuy.Name(),
+			telegram.RouteTrafficBuy.Name(),
+			telegram.RouteTopupBalance.Name(),
+			telegram.RouteTopupProvider.Name(),
+			telegram.RouteTopupPay.Name(),
+		),
+	)
+
+	// По умолчанию long polling, для прода можно включить webhook
+	if cfg.Telegram.Mode == telegram.ModeWebhook {
+		telegramClient.SetUpdateSource(telegram.NewWebhookSource(botAPI, telegram.WebhookConfig{
+			URL:    cfg.Telegram.WebhookURL,
+			Listen: cfg.Telegram.WebhookListen,
+			Secret: cfg.Telegram.WebhookSecret,
+		}, telegramLogger))
+	}
 
 	// регистрируем команды из бизнес-логики (domain/bot)
 	kbBuilder := telegram.NewKeyboardBuilder()
-	startCmd := telegrambot.NewStartCommand(kbBuilder, cfg.TelegramSupport, remnawaveClient)
+	startCmd := telegrambot.NewStartCommand(kbBuilder, settingsService, remnawaveClient, userRepo, translator, contentStore, telegramLogger)
 	telegramClient.RegisterCommand(startCmd)
+	telegramClient.RegisterCommand(telegrambot.NewReloadContentCommand(contentStore, translator, telegramLogger))
+	telegramClient.RegisterCommand(telegrambot.NewSettingsCommand(settingsService, translator))
+	telegramClient.RegisterCommand(telegrambot.NewSetCommand(settingsService, translator, telegramLogger))
+	telegramClient.RegisterCommand(telegrambot.NewMaintenanceCommand(settingsService, translator, telegramLogger))
+
+	// Регистрируем обработчик кнопок: экраны регистрируют свои маршруты в роутере
+	callbackRouter := telegram.NewCallbackRouter(translator, telegramLogger)
+	callbackHandler := telegrambot.NewCallbackHandler(
+		subService, settingsService, remnawaveClient, botStateRepo, userRepo, regionService, deviceService, translator, contentStore, telegramLogger,
+	)
+	callbackHandler.Register(callbackRouter)
+
+	// ===payments===
+	// Реестр включенных платежных систем: экран пополнения показывает
+	// их в порядке регистрации
+	paymentRegistry := payments.NewRegistry()
+
+	// Telegram Stars включается курсом STARS_RUB_RATE
+	var starsGateway *stars.Gateway
+	if cfg.Stars.RubRate > 0 {
+		starsGateway, err = stars.NewGateway(
+			telegram.NewBotMessenger(botAPI), transactionRepo, userRepo, translator, cfg.Stars.RubRate, paymentLogger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка инициализации Telegram Stars: %w", err)
+		}
+
+		err = paymentRegistry.Register(newPaymentProvider(stars.Provider, "⭐ Telegram Stars", []string{stars.Currency}, true, cfg.Stars.ProviderOptions, starsGateway))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка регистрации Telegram Stars: %w", err)
+		}
+	}
+
+	// Crypto Pay включается токеном CRYPTOPAY_TOKEN
+	cryptoClient := newCryptoPayClient(cfg.CryptoPay, paymentLogger)
+	if cryptoClient != nil {
+		err = paymentRegistry.Register(newPaymentProvider(cryptopay.Provider, "💎 CryptoBot", []string{cryptoClient.Asset()}, false, cfg.CryptoPay.ProviderOptions, cryptoClient))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка регистрации Crypto Pay: %w", err)
+		}
+	}
+
+	paymentService := service.NewPaymentService(transactionRepo, userRepo, paymentRegistry, paymentLogger)
+	paymentService.SetMetrics(appMetrics)
+	paymentHandler := telegrambot.NewPaymentHandler(
+		paymentService, starsGateway, telegram.NewBotMessenger(botAPI), userRepo, translator, paymentLogger,
+	)
+	paymentHandler.Register(callbackRouter)
+	paymentService.SetNotifier(paymentHandler.NotifyTopUp)
+
+	trafficHandler := telegrambot.NewTrafficHandler(
+		trafficService, settingsService, botStateRepo, telegram.NewBotMessenger(botAPI), userRepo, translator, telegramLogger,
+	)
+	trafficHandler.Register(callbackRouter)
+	trafficService.SetNotifier(trafficHandler.NotifyTraffic)
+
+	revokeHandler := telegrambot.NewRevokeHandler(linkService, settingsService, userRepo, translator, telegramLogger)
+	revokeHandler.Register(callbackRouter)
+
+	trialHandler := telegrambot.NewTrialHandler(trialService, settingsService, userRepo, translator, telegramLogger)
+	trialHandler.Register(callbackRouter)
+
+	// Webhook об оплате от Crypto Pay. Без него оплата зачисляется
+	// по кнопке "Проверить оплату"
+	var cryptoWebhook *http.Server
+	if cryptoClient != nil && cfg.CryptoPay.WebhookListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.CryptoPay.WebhookPath, cryptoClient.WebhookHandler(paymentHandler.CryptoPaid))
+		cryptoWebhook = &http.Server{
+			Addr:              cfg.CryptoPay.WebhookListen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+	telegramClient.SetPreCheckoutHandler(paymentHandler.PreCheckout)
+	telegramClient.SetPaymentHandler(paymentHandler.SuccessfulPayment)
+	telegramClient.RegisterCommand(telegrambot.NewRefundCommand(paymentService, translator, telegramLogger))
+
+	telegramClient.SetCallbackHandler(callbackRouter.Handle)
+
+	// ===http===
+	// Части HTTP сервера по адресам: сайт, admin API, метрики и проверки могут жить на одном порту
+	httpHandlers := make(map[string][]httpdelivery.Handler)
+
+	// Сайт для тех, у кого не работает телеграм. Включается WEB_LISTEN
+	if cfg.Web.Listen != "" {
+		checkoutHandler, err := newCheckoutHandler(
+			cfg, userRepo, orderRepo, paymentService, subService, remnawaveClient, settingsService,
+			telegrambot.NewLoginCodeSender(telegram.NewBotMessenger(botAPI), userRepo, translator), webLogger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка инициализации сайта: %w", err)
+		}
+		httpHandlers[cfg.Web.Listen] = append(httpHandlers[cfg.Web.Listen], checkoutHandler)
+	}
+
+	// admin API для операторов. Включается ADMIN_API_LISTEN, нужен хотя бы один ключ
+	if cfg.AdminAPI.Listen != "" {
+		adminService := service.NewAdminService(userRepo, adjustmentRepo, transactionRepo, paymentService, remnawaveClient, settingsService, adminLogger)
+		adminHandler, err := httpdelivery.NewAdminHandler(adminService, cfg.AdminAPI.Keys, adminLogger)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка инициализации admin API: %w", err)
+		}
+		adminHandler.SetLogLevels(logLevels)
+		httpHandlers[cfg.AdminAPI.Listen] = append(httpHandlers[cfg.AdminAPI.Listen], adminHandler)
+	}
+
+	// Метрики для Prometheus. Адрес лучше держать закрытым от интернета
+	if prometheusMetrics != nil {
+		prometheusMetrics.WatchActiveSubscriptions(remnawaveClient.CountActiveUsers)
+		httpHandlers[cfg.Metrics.Listen] = append(httpHandlers[cfg.Metrics.Listen], prometheusMetrics)
+	}
+
+	// Проверки для docker/k8s. Перезапуск помогает только от локальных проблем
+	// (пул соединений с DB). Панель и телеграм внешние: их недоступность
+	// рестартом не лечится, поэтому они влияют только на /readyz
+	if cfg.Health.Listen != "" {
+		healthHandler := httpdelivery.NewHealthHandler([]httpdelivery.HealthCheck{
+			{Name: "postgres", Check: db.PingContext, Liveness: true},
+			{Name: "remnawave", Check: remnawaveClient.Ping},
+			{Name: "telegram", Check: telegramClient.Ping},
+		}, loggerClient.Named("health"))
+		httpHandlers[cfg.Health.Listen] = append(httpHandlers[cfg.Health.Listen], healthHandler)
+	}
 
-	// Регистрируем обработчик кнопок
-	callbackHandler := telegrambot.NewCallbackHandler(subService, cfg.TelegramSupport, remnawaveClient)
-	telegramClient.SetCallbackHandler(callbackHandler.Handle)
+	httpServers := make([]*http.Server, 0, len(httpHandlers))
+	for addr, handlers := range httpHandlers {
+		httpServers = append(httpServers, httpdelivery.NewServer(addr, handlers...))
+	}
 
-	// plategaClient := platega.NewClient(cfg.PlategaAPIKey, plategaLogger)
+	// plategaClient := platega.NewClient(cfg.Platega, plategaLogger)
 	// data, _ := plategaClient.CreateTransaction(context.Background(), platega.SBPQR, 100, platega.RUB, "test", "test")
 	// fmt.Println(data)
 
 	return &app{
 		remnawaveClient: remnawaveClient,
 		telegramClient:  telegramClient,
+		cryptoWebhook:   cryptoWebhook,
+		httpServers:     httpServers,
+		shutdownTracing: shutdownTracing,
+		logLevels:       logLevels,
+		settings:        settingsService,
+		traffic:         trafficService,
+		payments:        paymentService,
+		logger:          loggerClient,
 		// plategaClient:   plategaClient,
 	}, nil
 }
 
-// Run запуск приложения
+// joinIDs id через запятую, как в TELEGRAM_ADMIN_IDS
+func joinIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// loadTranslator загружает каталоги переводов из папки или вшитые в бинарник
+func loadTranslator(dir string) (*i18n.Bundle, error) {
+	if dir == "" {
+		return i18n.LoadEmbedded()
+	}
+
+	return i18n.Load(os.DirFS(dir))
+}
+
+// loadContent загружает шаблоны текстов из папки или вшитые в бинарник
+func loadContent(dir string) (*content.Store, error) {
+	if dir == "" {
+		return content.NewEmbeddedStore()
+	}
+
+	return content.NewStore(os.DirFS(dir))
+}
+
+// newPaymentProvider провайдер для реестра: условия из конфига,
+// название по умолчанию, если в конфиге не задано
+func newPaymentProvider(
+	id, defaultName string,
+	currencies []string,
+	inChat bool,
+	opts config.ProviderOptions,
+	gateway domain.PaymentGateway,
+) domain.PaymentProvider {
+	name := opts.Name
+	if name == "" {
+		name = defaultName
+	}
+
+	return domain.PaymentProvider{
+		ID:         id,
+		Name:       name,
+		Currencies: currencies,
+		MinAmount:  opts.MinAmount,
+		MaxAmount:  opts.MaxAmount,
+		FeePercent: opts.FeePercent,
+		FeeFixed:   opts.FeeFixed,
+		InChat:     inChat,
+		Gateway:    gateway,
+	}
+}
+
+// newCheckoutHandler собирает сайт покупки подписки. Вход по email включается
+// настройками SMTP, без них только по Telegram ID
+func newCheckoutHandler(
+	cfg *config.Config,
+	users domain.UserRepository,
+	orders domain.OrderRepository,
+	payments domain.PaymentService,
+	subscriptions domain.SubscriptionService,
+	remna domain.RemnawaveClient,
+	settings domain.Settings,
+	telegramSender domain.LoginCodeSender,
+	l logger.Logger,
+) (*httpdelivery.CheckoutHandler, error) {
+	var emailSender domain.LoginCodeSender
+	if cfg.SMTP.Host != "" {
+		sender, err := mail.NewSender(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.User, cfg.SMTP.Password, cfg.SMTP.From, l)
+		if err != nil {
+			return nil, err
+		}
+		emailSender = sender
+	}
+
+	auth, err := service.NewWebAuthService(users, telegramSender, emailSender, cfg.Web.SessionSecret, l)
+	if err != nil {
+		return nil, err
+	}
+	checkout := service.NewCheckoutService(orders, users, payments, subscriptions, remna, settings, l)
+
+	checkoutHandler, err := httpdelivery.NewCheckoutHandler(auth, checkout, l)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки страниц сайта: %w", err)
+	}
+
+	return checkoutHandler, nil
+}
+
+// newCryptoPayClient клиент Crypto Pay из конфига. nil если токен не задан
+// Источник курса и сам курс уже проверены config
+func newCryptoPayClient(cfg config.CryptoPay, l logger.Logger) *cryptopay.Client {
+	if cfg.Token == "" {
+		return nil
+	}
+
+	client := cryptopay.NewClient(cfg.BaseURL, cfg.Token, cfg.Asset, l)
+	if cfg.RateSource == cryptopay.RateSourceFixed {
+		client.SetRateSource(cryptopay.FixedRate(cfg.RubRate))
+	}
+
+	return client
+}
+
+// Run запуск приложения. Работает до SIGINT/SIGTERM
 func (a *app) Run() {
+	// Контекст отменится при остановке контейнера или Ctrl+C,
+	// чтобы бот успел корректно завершиться (например снять webhook)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Спаны досылаем последними, после остановки серверов и бота
+	defer a.flushTracing()
+
+	// ===logger===
+	go a.watchLogLevelSignals(ctx)
+
+	// ===settings===
+	// Подхватываем настройки, измененные другой копией бота или прямо в DB
+	go a.settings.Watch(ctx, settingsReloadInterval)
+
+	// ===traffic===
+	// Предупреждения об израсходованном трафике
+	go a.traffic.Watch(ctx, trafficCheckInterval)
+
+	// ===payments===
+	// Оплата записана, а зачисление упало (например DB моргнула) - повторяем
+	go a.payments.Watch(ctx, paymentRetryInterval)
+
+	// ===crypto pay webhook===
+	if a.cryptoWebhook != nil {
+		go func() {
+			if err := a.cryptoWebhook.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.logger.Error("ошибка webhook сервера crypto pay", logger.Field{Key: "error", Value: err})
+			}
+		}()
+		defer a.shutdown(a.cryptoWebhook)
+	}
+
+	// ===http===
+	for _, server := range a.httpServers {
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.logger.Error("ошибка HTTP сервера", logger.Field{Key: "addr", Value: server.Addr}, logger.Field{Key: "error", Value: err})
+			}
+		}()
+		defer a.shutdown(server)
+	}
+
 	// ===telegram bot===
-	a.telegramClient.Run()
+	a.telegramClient.Run(ctx)
+}
+
+// flushTracing отправляет в коллектор спаны, которые еще не ушли
+func (a *app) flushTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.shutdownTracing(ctx); err != nil {
+		a.logger.Error("ошибка остановки трейсинга", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// shutdown останавливает HTTP сервер, давая текущим запросам завершиться
+func (a *app) shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		a.logger.Error("ошибка остановки HTTP сервера", logger.Field{Key: "addr", Value: server.Addr}, logger.Field{Key: "error", Value: err})
+	}
 }
@@ -0,0 +1,9 @@
+//go:build !unix
+
+// Package app на системах без SIGUSR1/SIGUSR2 уровни логов меняются только через admin API.
+package app
+
+import "context"
+
+// watchLogLevelSignals сигналов нет, ничего не делает
+func (a *app) watchLogLevelSignals(context.Context) {}
@@ -0,0 +1,39 @@
+//go:build unix
+
+// Package app смена уровня логов сигналами, когда admin API выключен:
+// docker kill -s USR1 proxymaster_app ставит debug уровнем по умолчанию, USR2 возвращает уровни из конфига.
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// watchLogLevelSignals меняет уровни логов по SIGUSR1/SIGUSR2, пока не отменят ctx
+func (a *app) watchLogLevelSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGUSR1 {
+				// Уровень "debug" всегда верный, ошибки тут не будет
+				_ = a.logLevels.Set("", "debug")
+			} else {
+				a.logLevels.Reset()
+			}
+			a.logger.Warn("уровни логов изменены сигналом",
+				logger.Field{Key: "signal", Value: sig.String()},
+				logger.Field{Key: "levels", Value: a.logLevels.Snapshot()},
+			)
+		}
+	}
+}
@@ -1,56 +1,248 @@
-// Package config обращается к env, получает
-// данные и возвращает в виде структуры
+// Package config настройки приложения. Значения берутся из YAML файла
+// (CONFIG_FILE, по умолчанию config.yaml, если он есть), поверх него - из env
+// и .env. Чего нет ни там, ни там, берется из тега default.
+//
+// Теги полей:
+//   - yaml - ключ в файле;
+//   - env - переменная окружения (у вложенной структуры - префикс);
+//   - default - значение по умолчанию;
+//   - required:"true" - без значения приложение не стартует;
+//   - validate - url (http/https адрес) или oneof=a|b.
 package config
 
 import (
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
+	"net/url"
+	"time"
 )
 
-// Config хранит глобальные настройки для панели.
+// Config хранит глобальные настройки приложения.
 type Config struct {
-	// remnawave
-	RemnaPanelURL       string // Страница панели.
-	RemnaSecretURLToken string // Секретный токен для подключения.
-	RemnaLogin          string // логин
-	RemnaPass           string // пароль
-	RemnaKey            string // Ключ для разработчика.
-	RemnaSquadUUID      string // ID squad.
-
-	// telegram
-	TelegramToken   string
-	TelegramSupport string // Поддержка телеграмм при ошибках сервиса.
-
-	// database
-	DatabaseURL string
-
-	// platega
-	PlategaAPIKey string
-
-	// Logger
-	LoggerLevel string
-}
-
-// New создает новый экземпляр конфигурации env.
-func New() (*Config, error) {
-	// Загружаем переменные окружения из файла .env.
-	if err := godotenv.Load(); err != nil {
-		log.Println("не удалось загрузить .env")
-	}
-
-	return &Config{
-		RemnaPanelURL:       os.Getenv("REMNA_BASE_PANEL"),
-		RemnaSecretURLToken: os.Getenv("REMNA_SECRET_TOKEN"),
-		RemnaLogin:          os.Getenv("REMNA_LOGIN"),
-		RemnaPass:           os.Getenv("REMNA_PASS"),
-		RemnaKey:            os.Getenv("REMNA_TOKEN"),
-		RemnaSquadUUID:      os.Getenv("REMNA_SQUAD_UUID"),
-		TelegramToken:       os.Getenv("TELEGRAM_TOKEN"),
-		TelegramSupport:     os.Getenv("TELEGRAM_SUPPORT"),
-		DatabaseURL:         os.Getenv("DATABASE_URL"),
-		PlategaAPIKey:       os.Getenv("PLATEGA_API_KEY"),
-		LoggerLevel:         os.Getenv("LOGGER_LEVEL"),
-	}, nil
+	Remnawave   Remnawave   `yaml:"remnawave"`
+	Telegram    Telegram    `yaml:"telegram"`
+	Database    Database    `yaml:"database"`
+	Platega     Platega     `yaml:"platega"`
+	Stars       Stars       `yaml:"stars"`
+	CryptoPay   CryptoPay   `yaml:"cryptopay"`
+	Web         Web         `yaml:"web"`
+	SMTP        SMTP        `yaml:"smtp"`
+	AdminAPI    AdminAPI    `yaml:"admin_api"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Health      Health      `yaml:"health"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Logger      Logger      `yaml:"logger"`
+	Maintenance Maintenance `yaml:"maintenance"`
+	Content     Content     `yaml:"content"`
+
+	// File YAML файл, из которого читали настройки. Пусто - только env
+	File string `yaml:"-" env:"-"`
+	// EnvFileLoaded прочитан ли .env. Конфиг грузится раньше логгера,
+	// поэтому о пропущенном файле пишет приложение
+	EnvFileLoaded bool `yaml:"-" env:"-"`
+}
+
+// Remnawave панель
+type Remnawave struct {
+	PanelURL       string        `yaml:"panel_url" env:"REMNA_BASE_PANEL" required:"true" validate:"url"` // Адрес панели.
+	SecretURLToken string        `yaml:"secret_url_token" env:"REMNA_SECRET_TOKEN"`                       // Секретная query string для прохода через Nginx.
+	Login          string        `yaml:"login" env:"REMNA_LOGIN"`                                         // логин
+	Password       string        `yaml:"password" env:"REMNA_PASS"`                                       // пароль
+	Token          string        `yaml:"token" env:"REMNA_TOKEN" required:"true"`                         // API токен панели.
+	SquadUUID      string        `yaml:"squad_uuid" env:"REMNA_SQUAD_UUID"`                               // Сквад по умолчанию, если регион не выбран
+	Timeout        time.Duration `yaml:"timeout" env:"REMNA_TIMEOUT" default:"10s"`                       // Таймаут запроса к панели.
+}
+
+// Telegram бот
+type Telegram struct {
+	Token   string `yaml:"token" env:"TELEGRAM_TOKEN" required:"true"`
+	Support string `yaml:"support" env:"TELEGRAM_SUPPORT"` // Поддержка телеграмм при ошибках сервиса.
+	// Режим получения обновлений: polling (для разработки) или webhook
+	Mode          string  `yaml:"mode" env:"TELEGRAM_MODE" default:"polling" validate:"oneof=polling|webhook"`
+	WebhookURL    string  `yaml:"webhook_url" env:"TELEGRAM_WEBHOOK_URL" validate:"url"` // Публичный https адрес webhook (за reverse proxy).
+	WebhookListen string  `yaml:"webhook_listen" env:"TELEGRAM_WEBHOOK_LISTEN"`          // Адрес HTTP сервера webhook, например ":8443".
+	WebhookSecret string  `yaml:"webhook_secret" env:"TELEGRAM_WEBHOOK_SECRET"`          // Секрет для заголовка X-Telegram-Bot-Api-Secret-Token.
+	AdminIDs      []int64 `yaml:"admin_ids" env:"TELEGRAM_ADMIN_IDS"`                    // Telegram ID админов, в env через запятую.
+}
+
+// Database postgres
+type Database struct {
+	URL string `yaml:"url" env:"DATABASE_URL" required:"true"`
+}
+
+// Platega платежная система
+type Platega struct {
+	APIKey     string        `yaml:"api_key" env:"PLATEGA_API_KEY"`
+	MerchantID string        `yaml:"merchant_id" env:"PLATEGA_MERCHANT_ID"`
+	BaseURL    string        `yaml:"base_url" env:"PLATEGA_BASE_URL" default:"https://app.platega.io" validate:"url"`
+	Timeout    time.Duration `yaml:"timeout" env:"PLATEGA_TIMEOUT" default:"30s"`
+}
+
+// Stars оплата Telegram Stars
+type Stars struct {
+	RubRate float64 `yaml:"rub_rate" env:"STARS_RUB_RATE"` // Сколько рублей баланса дает 1 звезда. 0 - оплата звездами выключена.
+
+	ProviderOptions `yaml:",inline" env:"STARS_"` // Название, лимиты и комиссия.
+}
+
+// CryptoPay оплата через Crypto Pay (CryptoBot)
+type CryptoPay struct {
+	Token         string  `yaml:"token" env:"CRYPTOPAY_TOKEN"`                                                      // Токен API. Пусто - оплата криптовалютой выключена.
+	BaseURL       string  `yaml:"base_url" env:"CRYPTOPAY_BASE_URL" default:"https://pay.crypt.bot" validate:"url"` // Адрес API.
+	Asset         string  `yaml:"asset" env:"CRYPTOPAY_ASSET" default:"USDT"`                                       // Криптовалюта счета.
+	RateSource    string  `yaml:"rate_source" env:"CRYPTOPAY_RATE_SOURCE" default:"api" validate:"oneof=api|fixed"` // Откуда курс к рублю.
+	RubRate       float64 `yaml:"rub_rate" env:"CRYPTOPAY_RUB_RATE"`                                                // Курс для fixed: сколько рублей стоит 1 единица криптовалюты.
+	WebhookListen string  `yaml:"webhook_listen" env:"CRYPTOPAY_WEBHOOK_LISTEN"`                                    // Адрес HTTP сервера для webhook, например ":8081". Пусто - без webhook.
+	WebhookPath   string  `yaml:"webhook_path" env:"CRYPTOPAY_WEBHOOK_PATH" default:"/cryptopay/webhook"`           // Путь webhook.
+
+	ProviderOptions `yaml:",inline" env:"CRYPTOPAY_"` // Название, лимиты и комиссия.
+}
+
+// ProviderOptions условия платежной системы для пользователя.
+// В env с префиксом провайдера: STARS_MIN_AMOUNT, CRYPTOPAY_FEE_PERCENT...
+type ProviderOptions struct {
+	Name       string  `yaml:"name" env:"NAME"`               // Название на экране пополнения.
+	MinAmount  int     `yaml:"min_amount" env:"MIN_AMOUNT"`   // Минимум пополнения в рублях. 0 - без ограничения.
+	MaxAmount  int     `yaml:"max_amount" env:"MAX_AMOUNT"`   // Максимум пополнения в рублях. 0 - без ограничения.
+	FeePercent float64 `yaml:"fee_percent" env:"FEE_PERCENT"` // Комиссия в процентах сверх суммы.
+	FeeFixed   int     `yaml:"fee_fixed" env:"FEE_FIXED"`     // Фиксированная комиссия в рублях сверх суммы.
+}
+
+// Web сайт покупки подписки без телеграма
+type Web struct {
+	Listen        string `yaml:"listen" env:"WEB_LISTEN"`                 // Адрес HTTP сервера сайта, например ":8080". Пусто - сайт выключен.
+	SessionSecret string `yaml:"session_secret" env:"WEB_SESSION_SECRET"` // Ключ подписи сессий. Пусто - случайный, после рестарта нужно войти заново.
+}
+
+// SMTP почта для кода входа на сайт. Без Host вход только по Telegram ID
+type SMTP struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" env:"SMTP_PORT" default:"587"` // STARTTLS.
+	User     string `yaml:"user" env:"SMTP_USER"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+	From     string `yaml:"from" env:"SMTP_FROM"` // Адрес отправителя.
+}
+
+// AdminAPI admin API для операторов
+type AdminAPI struct {
+	Listen string            `yaml:"listen" env:"ADMIN_API_LISTEN"` // Адрес HTTP сервера. Может совпадать с web.listen. Пусто - выключен.
+	Keys   map[string]string `yaml:"keys" env:"ADMIN_API_KEYS"`     // Ключи доступа, в env "имя:ключ,имя2:ключ2". Имя пишется в журнал баланса.
+}
+
+// Metrics метрики Prometheus
+type Metrics struct {
+	Listen string `yaml:"listen" env:"METRICS_LISTEN"` // Адрес HTTP сервера для GET /metrics. Пусто - метрики выключены.
+}
+
+// Health проверки здоровья
+type Health struct {
+	Listen string `yaml:"listen" env:"HEALTH_LISTEN"` // Адрес HTTP сервера для /healthz и /readyz. Пусто - проверки выключены.
+}
+
+// Tracing OpenTelemetry
+type Tracing struct {
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"url"` // OTLP/HTTP коллектор (http://tempo:4318). Пусто - трейсинг выключен.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`       // Доля сохраняемых трейсов от 0 до 1.
+}
+
+// Logger логи
+type Logger struct {
+	Level  string `yaml:"level" env:"LOGGER_LEVEL" default:"info" validate:"oneof=debug|info|warn|error"` // Уровень всех модулей.
+	Levels string `yaml:"levels" env:"LOGGER_LEVELS"`                                                     // Уровни отдельных модулей (имена из Named): "remnawave=debug,telegram=warn".
+	Mode   string `yaml:"mode" env:"LOGGER_MODE" default:"json" validate:"oneof=json|console"`            // json для Loki или console для разработки.
+	// Файл логов с ротацией. Пусто - только stderr
+	File           string `yaml:"file" env:"LOGGER_FILE"`
+	FileMaxSizeMB  int    `yaml:"file_max_size_mb" env:"LOGGER_FILE_MAX_SIZE_MB" default:"100"`
+	FileMaxBackups int    `yaml:"file_max_backups" env:"LOGGER_FILE_MAX_BACKUPS"`
+	FileMaxAgeDays int    `yaml:"file_max_age_days" env:"LOGGER_FILE_MAX_AGE_DAYS"`
+	// Прореживание debug логов: первые N одинаковых сообщений в секунду, дальше каждое M-е. 0 - выключено
+	SampleInitial    int `yaml:"sample_initial" env:"LOGGER_SAMPLE_INITIAL"`
+	SampleThereafter int `yaml:"sample_thereafter" env:"LOGGER_SAMPLE_THEREAFTER"`
+}
+
+// Maintenance режим техработ при старте. Дальше его переключает админ
+// командой /maintenance, значение из DB важнее конфига
+type Maintenance struct {
+	Enabled bool   `yaml:"enabled" env:"MAINTENANCE"`         // Покупки и пополнения приостановлены.
+	Message string `yaml:"message" env:"MAINTENANCE_MESSAGE"` // Текст для пользователей. Пусто - стандартный.
+}
+
+// Content тексты бота
+type Content struct {
+	I18nDir string `yaml:"i18n_dir" env:"I18N_DIR"` // Папка с каталогами переводов. Пусто - вшитые в бинарник.
+	Dir     string `yaml:"dir" env:"CONTENT_DIR"`   // Папка с шаблонами текстов (welcome.ru.html и т.д.). Пусто - вшитые в бинарник.
+}
+
+// Secrets значения секретов для маскировки в логах: токены, ключи и пароли
+func (c *Config) Secrets() []string {
+	secrets := []string{
+		c.Remnawave.SecretURLToken,
+		c.Remnawave.Password,
+		c.Remnawave.Token,
+		c.Telegram.Token,
+		c.Telegram.WebhookSecret,
+		c.Platega.APIKey,
+		c.CryptoPay.Token,
+		c.Web.SessionSecret,
+		c.SMTP.Password,
+	}
+	for _, key := range c.AdminAPI.Keys {
+		secrets = append(secrets, key)
+	}
+	if u, err := url.Parse(c.Database.URL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
+	}
+
+	return secrets
+}
+
+// validate правила, которые связывают несколько полей
+func (c *Config) validate() []string {
+	var problems []string
+
+	if c.Telegram.Mode == "webhook" {
+		if u, err := url.Parse(c.Telegram.WebhookURL); err != nil || u.Scheme != "https" {
+			problems = append(problems, "TELEGRAM_WEBHOOK_URL (telegram.webhook_url): для режима webhook нужен https адрес")
+		}
+		if c.Telegram.WebhookListen == "" {
+			problems = append(problems, "TELEGRAM_WEBHOOK_LISTEN (telegram.webhook_listen): обязателен для режима webhook")
+		}
+		if c.Telegram.WebhookSecret == "" {
+			problems = append(problems, "TELEGRAM_WEBHOOK_SECRET (telegram.webhook_secret): обязателен для режима webhook")
+		}
+	}
+
+	if c.Platega.APIKey != "" && c.Platega.MerchantID == "" {
+		problems = append(problems, "PLATEGA_MERCHANT_ID (platega.merchant_id): обязателен вместе с PLATEGA_API_KEY")
+	}
+
+	if c.CryptoPay.Token != "" && c.CryptoPay.RateSource == "fixed" && c.CryptoPay.RubRate <= 0 {
+		problems = append(problems, "CRYPTOPAY_RUB_RATE (cryptopay.rub_rate): для CRYPTOPAY_RATE_SOURCE=fixed нужен курс больше нуля")
+	}
+
+	problems = append(problems, c.Stars.validate("STARS_", "stars.")...)
+	problems = append(problems, c.CryptoPay.validate("CRYPTOPAY_", "cryptopay.")...)
+
+	if c.SMTP.Host != "" && c.SMTP.From == "" {
+		problems = append(problems, "SMTP_FROM (smtp.from): обязателен вместе с SMTP_HOST")
+	}
+
+	if c.AdminAPI.Listen != "" && len(c.AdminAPI.Keys) == 0 {
+		problems = append(problems, "ADMIN_API_KEYS (admin_api.keys): нужен хотя бы один ключ, иначе admin API открыт всем")
+	}
+
+	if c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "TRACING_SAMPLE_RATIO (tracing.sample_ratio): должен быть от 0 до 1")
+	}
+
+	return problems
+}
+
+// validate лимиты пополнения не противоречат друг другу
+func (o ProviderOptions) validate(envPrefix, yamlPrefix string) []string {
+	if o.MinAmount > 0 && o.MaxAmount > 0 && o.MinAmount > o.MaxAmount {
+		return []string{envPrefix + "MIN_AMOUNT (" + yamlPrefix + "min_amount): больше, чем " + envPrefix + "MAX_AMOUNT"}
+	}
+
+	return nil
 }
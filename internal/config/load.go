@@ -0,0 +1,355 @@
+// Package config загрузка настроек: значения по умолчанию, YAML файл,
+// env поверх него и проверка. Все проблемы собираются в одну ошибку,
+// чтобы не чинить их по одной за каждый рестарт.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFile файл настроек, если CONFIG_FILE не задан. Его может и не быть
+const defaultFile = "config.yaml"
+
+// Error все проблемы конфигурации сразу
+type Error struct {
+	Problems []string
+}
+
+// Error список проблем, по одной на строку
+func (e *Error) Error() string {
+	return "некорректная конфигурация:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// New загружает и проверяет конфигурацию. При любой проблеме возвращает *Error
+func New() (*Config, error) {
+	// Загружаем переменные окружения из файла .env. В docker их обычно
+	// передают через environment, поэтому отсутствие файла не ошибка
+	cfg := &Config{EnvFileLoaded: godotenv.Load() == nil}
+
+	fields := collectFields(reflect.ValueOf(cfg).Elem(), "", "")
+	var problems []string
+
+	// 1. Значения по умолчанию
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := setFromString(f.value, f.def); err != nil {
+			// Ошибка в теге - ошибка в коде, но сообщаем как остальные
+			problems = append(problems, fmt.Sprintf("%s: неверное значение по умолчанию: %v", f.name(), err))
+		}
+	}
+
+	// 2. YAML файл
+	file, err := readFile(cfg)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	cfg.File = file
+
+	// 3. env поверх файла. Пустая переменная считается незаданной:
+	// docker compose передает пустые строки для неуказанных значений
+	for _, f := range fields {
+		raw, ok := os.LookupEnv(f.env)
+		if !ok || strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if err := setFromString(f.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.name(), err))
+		}
+	}
+
+	// 4. Обязательные значения и формат
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			problems = append(problems, f.name()+": обязательное значение не задано")
+			continue
+		}
+		if problem := validateField(f); problem != "" {
+			problems = append(problems, f.name()+": "+problem)
+		}
+	}
+
+	// 5. Правила на несколько полей
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// readFile читает YAML в cfg. Возвращает путь прочитанного файла, пусто - файла нет
+func readFile(cfg *Config) (string, error) {
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit || path == "" {
+		path = defaultFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// config.yaml по умолчанию необязателен, а явно указанный файл - да
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("CONFIG_FILE: не удалось прочитать %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	// Опечатка в ключе молча превратилась бы в значение по умолчанию
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("CONFIG_FILE: ошибка разбора %s: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// field одно значение конфига
+type field struct {
+	value    reflect.Value
+	env      string
+	path     string
+	def      string
+	required bool
+	validate string
+}
+
+// name как поле называть в ошибках: переменная и ключ в YAML
+func (f field) name() string {
+	return fmt.Sprintf("%s (%s)", f.env, f.path)
+}
+
+// collectFields обходит структуру и собирает поля с тегом env.
+// envPrefix и path - префиксы вложенных структур
+func collectFields(v reflect.Value, envPrefix, path string) []field {
+	var fields []field
+
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		env := sf.Tag.Get("env")
+		if env == "-" || !sf.IsExported() {
+			continue
+		}
+
+		yamlName, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		fieldPath := path
+		if yamlName != "" {
+			fieldPath = path + yamlName
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			// Встроенная структура (inline) лежит на том же уровне YAML
+			nestedPath := fieldPath
+			if yamlName != "" {
+				nestedPath += "."
+			}
+			fields = append(fields, collectFields(v.Field(i), envPrefix+env, nestedPath)...)
+			continue
+		}
+
+		fields = append(fields, field{
+			value:    v.Field(i),
+			env:      envPrefix + env,
+			path:     fieldPath,
+			def:      sf.Tag.Get("default"),
+			required: sf.Tag.Get("required") == "true",
+			validate: sf.Tag.Get("validate"),
+		})
+	}
+
+	return fields
+}
+
+// durationType тип time.Duration: по Kind он int64, разбирать его нужно иначе
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setFromString записывает в v значение из строки env или тега default
+func setFromString(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("неверная длительность %q, нужно например 10s или 1m30s", raw)
+		}
+		if d < 0 {
+			return fmt.Errorf("длительность не может быть отрицательной: %s", raw)
+		}
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := parseInt(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := parseFloat(raw)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("ожидается true или false, получено %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		ids, err := parseIDs(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(ids))
+	case reflect.Map:
+		keys, err := parseAPIKeys(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(keys))
+	default:
+		return fmt.Errorf("тип %s не поддерживается", v.Type())
+	}
+
+	return nil
+}
+
+// validateField проверяет формат значения по тегу validate. Пустое значение
+// не проверяется: обязательность проверяет required
+func validateField(f field) string {
+	// env проверяется при разборе, а из YAML отрицательное число приходит как есть
+	switch f.value.Kind() {
+	case reflect.Int, reflect.Int64:
+		if f.value.Int() < 0 {
+			return "не может быть отрицательным"
+		}
+	case reflect.Float64:
+		if f.value.Float() < 0 {
+			return "не может быть отрицательным"
+		}
+	}
+
+	if f.validate == "" || f.value.IsZero() {
+		return ""
+	}
+
+	value := fmt.Sprint(f.value.Interface())
+	switch {
+	case f.validate == "url":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("%q не похож на http(s) адрес", value)
+		}
+	case strings.HasPrefix(f.validate, "oneof="):
+		allowed := strings.Split(strings.TrimPrefix(f.validate, "oneof="), "|")
+		for _, a := range allowed {
+			if value == a {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("%q, допустимо: %s", value, strings.Join(allowed, ", "))
+	}
+
+	return ""
+}
+
+// parseIDs разбирает список id через запятую: "123,456"
+func parseIDs(raw string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверный id %q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// parseAPIKeys разбирает ключи API "имя:ключ,имя2:ключ2"
+func parseAPIKeys(raw string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, key, ok := strings.Cut(part, ":")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("ожидается имя:ключ, получено %q", part)
+		}
+		if _, exists := keys[name]; exists {
+			return nil, fmt.Errorf("ключ %q указан дважды", name)
+		}
+		keys[name] = key
+	}
+
+	return keys, nil
+}
+
+// parseFloat разбирает дробное число, пустая строка - 0
+func parseFloat(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("неверное число %q: %w", raw, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("число не может быть отрицательным: %v", v)
+	}
+
+	return v, nil
+}
+
+// parseInt разбирает целое число, пустая строка - 0
+func parseInt(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("неверное число %q: %w", raw, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("число не может быть отрицательным: %d", v)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,97 @@
+// Package database for working with database
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// BalanceAdjustmentStorage structure for working with balance_adjustments table
+type BalanceAdjustmentStorage struct {
+	db     *sqlx.DB
+	logger logger.Logger
+}
+
+// NewBalanceAdjustmentStorage is constructor for BalanceAdjustmentStorage struct
+func NewBalanceAdjustmentStorage(db *sqlx.DB, l logger.Logger) *BalanceAdjustmentStorage {
+	return &BalanceAdjustmentStorage{
+		db:     db,
+		logger: l,
+	}
+}
+
+// ApplyAdjustment в одной DB транзакции записывает ручное изменение баланса
+// в журнал и меняет баланс на delta. Баланс меняется относительно текущего
+// и не уходит в минус, иначе не пишется и запись в журнал
+func (s *BalanceAdjustmentStorage) ApplyAdjustment(data models.CreateBalanceAdjustmentDTO) (*models.UserTG, error) {
+	var user models.UserTG
+
+	err := inTx(context.Background(), s.db, func(tx *sqlx.Tx) error {
+		query := `
+		UPDATE users
+		SET balance = COALESCE(balance, 0) + $2
+		WHERE id = $1 AND COALESCE(balance, 0) + $2 >= 0
+		RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+		`
+
+		err := tx.QueryRowx(query, data.UserID, data.Delta).StructScan(&user)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrInsufficientFunds
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+		INSERT INTO balance_adjustments (id, user_id, delta, reason, actor)
+		VALUES ($1, $2, $3, $4, $5)
+		`, data.ID, data.UserID, data.Delta, data.Reason, data.Actor); err != nil {
+			return fmt.Errorf("failed to create balance adjustment: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrInsufficientFunds) {
+			s.logger.Error("failed to apply balance adjustment",
+				logger.Field{Key: "user_id", Value: data.UserID},
+				logger.Field{Key: "error", Value: err},
+			)
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetAdjustmentsByUser возвращает изменения баланса пользователя, новые первыми
+func (s *BalanceAdjustmentStorage) GetAdjustmentsByUser(userID string) ([]models.BalanceAdjustment, error) {
+	adjustments := []models.BalanceAdjustment{}
+
+	query := `
+	SELECT id, user_id, delta, reason, actor, created_at
+	FROM balance_adjustments
+	WHERE user_id = $1
+	ORDER BY created_at DESC
+	`
+
+	if err := s.db.Select(&adjustments, query, userID); err != nil {
+		s.logger.Error("failed to get balance adjustments",
+			logger.Field{Key: "user_id", Value: userID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to get balance adjustments: %w", err)
+	}
+
+	return adjustments, nil
+}
@@ -0,0 +1,131 @@
+// Package database for working with database
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lastUpdateIDKey ключ в bot_state для последнего обработанного update_id
+const lastUpdateIDKey = "last_update_id"
+
+// BotStateStorage structure for working with bot_state and processed_callbacks tables
+type BotStateStorage struct {
+	db     *sqlx.DB
+	logger logger.Logger
+}
+
+// NewBotStateStorage is constructor for BotStateStorage struct
+func NewBotStateStorage(db *sqlx.DB, l logger.Logger) *BotStateStorage {
+	return &BotStateStorage{
+		db:     db,
+		logger: l,
+	}
+}
+
+// GetLastUpdateID возвращает последний обработанный update_id, 0 если его еще нет
+func (s *BotStateStorage) GetLastUpdateID() (int, error) {
+	var updateID int
+
+	query := `
+	SELECT value
+	FROM bot_state
+	WHERE key = $1
+	`
+
+	if err := s.db.Get(&updateID, query, lastUpdateIDKey); err != nil {
+		// Бот еще ни разу не запускался
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		s.logger.Error("failed to get last update id",
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return 0, fmt.Errorf("failed to get last update id: %w", err)
+	}
+
+	return updateID, nil
+}
+
+// SaveLastUpdateID сохраняет последний обработанный update_id
+func (s *BotStateStorage) SaveLastUpdateID(updateID int) error {
+	// GREATEST защищает от отката назад, если обновления пришли не по порядку
+	query := `
+	INSERT INTO bot_state (key, value, updated_at)
+	VALUES ($1, $2, CURRENT_TIMESTAMP)
+	ON CONFLICT (key) DO UPDATE
+	SET value = GREATEST(bot_state.value, EXCLUDED.value), updated_at = CURRENT_TIMESTAMP
+	`
+
+	if _, err := s.db.Exec(query, lastUpdateIDKey, updateID); err != nil {
+		s.logger.Error("failed to save last update id",
+			logger.Field{Key: "update_id", Value: updateID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return fmt.Errorf("failed to save last update id: %w", err)
+	}
+
+	return nil
+}
+
+// MarkCallbackProcessed записывает id callback query.
+// Возвращает false, если такой callback уже был записан раньше
+func (s *BotStateStorage) MarkCallbackProcessed(callbackID, userID string) (bool, error) {
+	// ON CONFLICT DO NOTHING делает проверку и запись одной атомарной операцией,
+	// поэтому два одинаковых callback одновременно не пройдут оба
+	query := `
+	INSERT INTO processed_callbacks (callback_id, user_id)
+	VALUES ($1, $2)
+	ON CONFLICT (callback_id) DO NOTHING
+	`
+
+	result, err := s.db.Exec(query, callbackID, userID)
+	if err != nil {
+		s.logger.Error("failed to mark callback processed",
+			logger.Field{Key: "callback_id", Value: callbackID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to mark callback processed: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DeleteProcessedCallbacks удаляет ключи идемпотентности старше olderThan.
+// Время считает DB, как и created_at
+func (s *BotStateStorage) DeleteProcessedCallbacks(olderThan time.Duration) (int64, error) {
+	query := `
+	DELETE FROM processed_callbacks
+	WHERE created_at < CURRENT_TIMESTAMP - $1::float8 * INTERVAL '1 second'
+	`
+
+	result, err := s.db.Exec(query, olderThan.Seconds())
+	if err != nil {
+		s.logger.Error("failed to delete processed callbacks",
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return 0, fmt.Errorf("failed to delete processed callbacks: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
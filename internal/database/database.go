@@ -1,20 +1,21 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
+
+	"ProxyMaster_v2/pkg/logger"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" //драйвер постгреса
 )
 
 // Connect is function for database connection
-func Connect(databaseURL string) (*sqlx.DB, error) {
+func Connect(databaseURL string, l logger.Logger) (*sqlx.DB, error) {
 	db, err := sqlx.Connect("postgres", databaseURL)
 	if err != nil {
-		slog.Warn(
-			"Failed db connection",
-			"error_message", err,
+		l.Warn("failed db connection",
+			logger.Field{Key: "error", Value: err},
 		)
 
 		return nil, fmt.Errorf("failed database connection: %w", err)
@@ -25,6 +26,28 @@ func Connect(databaseURL string) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// inTx выполняет fn в одной транзакции DB: ошибка fn откатывает
+// все ее запросы, иначе транзакция фиксируется
+func inTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		// Ошибка отката ничего не добавит к ошибке fn
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 // func Connect(databaseURL string) (*sqlx.DB, error) {
 // 	db, err := sqlx.Connect("postgres", databaseURL)
 // 	if err != nil {
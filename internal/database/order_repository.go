@@ -0,0 +1,114 @@
+// Package database for working with database
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OrderStorage structure for working with orders table
+type OrderStorage struct {
+	db     *sqlx.DB
+	logger logger.Logger
+}
+
+// NewOrderStorage is constructor for OrderStorage struct
+func NewOrderStorage(db *sqlx.DB, l logger.Logger) *OrderStorage {
+	return &OrderStorage{
+		db:     db,
+		logger: l,
+	}
+}
+
+// CreateOrder создает заказ в DB
+func (s *OrderStorage) CreateOrder(data models.CreateOrderDTO) (*models.Order, error) {
+	var order models.Order
+
+	query := `
+	INSERT INTO orders (id, user_id, months, price, transaction_id, payment_url, status)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, user_id, months, price, transaction_id, payment_url, status, created_at, updated_at
+	`
+
+	err := s.db.QueryRowx(
+		query,
+		data.ID,
+		data.UserID,
+		data.Months,
+		data.Price,
+		data.TransactionID,
+		data.PaymentURL,
+		data.Status,
+	).StructScan(&order)
+	if err != nil {
+		s.logger.Error("failed to create order",
+			logger.Field{Key: "user_id", Value: data.UserID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return &order, nil
+}
+
+// GetOrderByID возвращает заказ по id
+func (s *OrderStorage) GetOrderByID(id string) (*models.Order, error) {
+	var order models.Order
+
+	query := `
+	SELECT id, user_id, months, price, transaction_id, payment_url, status, created_at, updated_at
+	FROM orders
+	WHERE id = $1
+	`
+
+	if err := s.db.Get(&order, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrOrderNotFound
+		}
+		s.logger.Error("failed to get order",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	return &order, nil
+}
+
+// SetOrderStatus меняет статус заказа, только если сейчас он равен from
+func (s *OrderStorage) SetOrderStatus(id string, from, to domain.OrderStatus) (bool, error) {
+	// Как и у транзакций: условие на старый статус не даст активировать
+	// подписку дважды, если страницу заказа открыли в двух вкладках
+	query := `
+	UPDATE orders
+	SET status = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $2
+	`
+
+	result, err := s.db.Exec(query, id, string(from), string(to))
+	if err != nil {
+		s.logger.Error("failed to set order status",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "status", Value: to},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to set order status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,96 @@
+// Package database for working with database
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SettingsStorage structure for working with settings table
+type SettingsStorage struct {
+	db     *sqlx.DB
+	logger logger.Logger
+}
+
+// NewSettingsStorage is constructor for SettingsStorage struct
+func NewSettingsStorage(db *sqlx.DB, l logger.Logger) *SettingsStorage {
+	return &SettingsStorage{
+		db:     db,
+		logger: l,
+	}
+}
+
+// GetAllSettings возвращает все измененные настройки
+func (s *SettingsStorage) GetAllSettings(ctx context.Context) (_ []models.Setting, err error) {
+	ctx, span := startSpan(ctx, "SettingsStorage.GetAllSettings", "SELECT", "settings")
+	defer func() { endSpan(span, err) }()
+
+	settings := []models.Setting{}
+
+	query := `
+	SELECT key, value, updated_by, updated_at
+	FROM settings
+	ORDER BY key
+	`
+
+	if err := s.db.SelectContext(ctx, &settings, query); err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to get settings",
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// SaveSetting создает или перезаписывает настройку
+func (s *SettingsStorage) SaveSetting(ctx context.Context, setting models.Setting) (err error) {
+	ctx, span := startSpan(ctx, "SettingsStorage.SaveSetting", "INSERT", "settings")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	INSERT INTO settings (key, value, updated_by, updated_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+	ON CONFLICT (key) DO UPDATE
+	SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = CURRENT_TIMESTAMP
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, setting.Key, setting.Value, setting.UpdatedBy); err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to save setting",
+			logger.Field{Key: "key", Value: setting.Key},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return fmt.Errorf("failed to save setting: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSetting удаляет настройку, дальше действует значение по умолчанию
+func (s *SettingsStorage) DeleteSetting(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "SettingsStorage.DeleteSetting", "DELETE", "settings")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	DELETE FROM settings
+	WHERE key = $1
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, key); err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to delete setting",
+			logger.Field{Key: "key", Value: key},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return fmt.Errorf("failed to delete setting: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package database спаны запросов к DB для трейсинга
+package database
+
+import (
+	"context"
+	"errors"
+
+	"ProxyMaster_v2/internal/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer спаны запросов к postgres
+var tracer = otel.Tracer("ProxyMaster_v2/internal/database")
+
+// startSpan открывает спан запроса: operation - SELECT/INSERT/UPDATE, table - таблица
+func startSpan(ctx context.Context, name, operation, table string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+		),
+	)
+}
+
+// endSpan закрывает спан. "Не найдено" не ошибка запроса, такие спаны не красим
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,302 @@
+// Package database for working with database
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// TransactionStorage structure for working with transactions table
+type TransactionStorage struct {
+	db     *sqlx.DB
+	logger logger.Logger
+}
+
+// NewTransactionStorage is constructor for TransactionStorage struct
+func NewTransactionStorage(db *sqlx.DB, l logger.Logger) *TransactionStorage {
+	return &TransactionStorage{
+		db:     db,
+		logger: l,
+	}
+}
+
+// CreateTransaction создает транзакцию в DB
+func (s *TransactionStorage) CreateTransaction(data models.CreateTransactionDTO) (*models.Transaction, error) {
+	var tx models.Transaction
+
+	query := `
+	INSERT INTO transactions (id, user_id, amount, fee, status, provider)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, user_id, amount, fee, status, provider, external_id, created_at, updated_at
+	`
+
+	err := s.db.QueryRowx(
+		query,
+		data.ID,
+		data.UserID,
+		data.Amount,
+		data.Fee,
+		data.Status,
+		data.Provider,
+	).StructScan(&tx)
+	if err != nil {
+		s.logger.Error("failed to create transaction",
+			logger.Field{Key: "user_id", Value: data.UserID},
+			logger.Field{Key: "provider", Value: data.Provider},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// GetTransactionByID возвращает транзакцию по id
+func (s *TransactionStorage) GetTransactionByID(id string) (*models.Transaction, error) {
+	var tx models.Transaction
+
+	query := `
+	SELECT id, user_id, amount, fee, status, provider, external_id, created_at, updated_at
+	FROM transactions
+	WHERE id = $1
+	`
+
+	if err := s.db.Get(&tx, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrTransactionNotFound
+		}
+		s.logger.Error("failed to get transaction",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// SetTransactionStatus меняет статус транзакции, только если сейчас он равен from
+func (s *TransactionStorage) SetTransactionStatus(id string, from, to domain.PaymentStatus, externalID string) (bool, error) {
+	// Условие на старый статус делает проверку и запись одной операцией,
+	// поэтому одна оплата не зачислится дважды даже при параллельных обновлениях
+	query := `
+	UPDATE transactions
+	SET status = $3, external_id = COALESCE(NULLIF($4, ''), external_id), updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND status = $2
+	`
+
+	result, err := s.db.Exec(query, id, string(from), string(to), externalID)
+	if err != nil {
+		s.logger.Error("failed to set transaction status",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "status", Value: to},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to set transaction status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// CompleteTransaction зачисляет транзакцию: статус и баланс меняются вместе,
+// поэтому зачисленная транзакция не останется без денег на балансе
+func (s *TransactionStorage) CompleteTransaction(id, externalID string) (bool, error) {
+	changed, err := s.moveWithBalance(id, []domain.PaymentStatus{domain.PaymentStatusPending, domain.PaymentStatusPaid}, domain.PaymentStatusSuccess, externalID, 1)
+	if err != nil {
+		s.logger.Error("failed to complete transaction",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to complete transaction: %w", err)
+	}
+
+	return changed, nil
+}
+
+// RefundTransaction отмечает возврат и списывает сумму транзакции с баланса
+func (s *TransactionStorage) RefundTransaction(id string) (bool, error) {
+	changed, err := s.moveWithBalance(id, []domain.PaymentStatus{domain.PaymentStatusSuccess}, domain.PaymentStatusRefunded, "", -1)
+	if err != nil && !errors.Is(err, domain.ErrInsufficientFunds) {
+		s.logger.Error("failed to refund transaction",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to refund transaction: %w", err)
+	}
+
+	return changed, err
+}
+
+// RevertRefund возвращает транзакцию из refunded в success вместе с деньгами на балансе
+func (s *TransactionStorage) RevertRefund(id string) (bool, error) {
+	changed, err := s.moveWithBalance(id, []domain.PaymentStatus{domain.PaymentStatusRefunded}, domain.PaymentStatusSuccess, "", 1)
+	if err != nil {
+		s.logger.Error("failed to revert refund",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to revert refund: %w", err)
+	}
+
+	return changed, nil
+}
+
+// moveWithBalance в одной DB транзакции переводит транзакцию из любого статуса
+// from в to и меняет баланс ее пользователя на sign * amount. Баланс меняется
+// относительно текущего и не уходит в минус, иначе откатывается и статус
+func (s *TransactionStorage) moveWithBalance(id string, from []domain.PaymentStatus, to domain.PaymentStatus, externalID string, sign int) (bool, error) {
+	statuses := make([]string, 0, len(from))
+	for _, status := range from {
+		statuses = append(statuses, string(status))
+	}
+
+	changed := false
+	err := inTx(context.Background(), s.db, func(tx *sqlx.Tx) error {
+		var moved struct {
+			UserID string `db:"user_id"`
+			Amount int    `db:"amount"`
+		}
+
+		query := `
+		UPDATE transactions
+		SET status = $3, external_id = COALESCE(NULLIF($4, ''), external_id), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = ANY($2)
+		RETURNING user_id, amount
+		`
+
+		err := tx.QueryRowx(query, id, pq.Array(statuses), string(to), externalID).StructScan(&moved)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Транзакция уже не в статусе from: ее обработал кто-то другой
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set transaction status: %w", err)
+		}
+
+		delta := sign * moved.Amount
+		result, err := tx.Exec(`
+		UPDATE users
+		SET balance = COALESCE(balance, 0) + $2
+		WHERE id = $1 AND COALESCE(balance, 0) + $2 >= 0
+		`, moved.UserID, delta)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rows != 1 {
+			if delta < 0 {
+				return domain.ErrInsufficientFunds
+			}
+
+			return domain.ErrUserNotFound
+		}
+
+		changed = true
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// GetTransactionsByStatus возвращает транзакции в статусе status, старые первыми
+func (s *TransactionStorage) GetTransactionsByStatus(status domain.PaymentStatus) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+
+	query := `
+	SELECT id, user_id, amount, fee, status, provider, external_id, created_at, updated_at
+	FROM transactions
+	WHERE status = $1
+	ORDER BY created_at
+	`
+
+	if err := s.db.Select(&transactions, query, string(status)); err != nil {
+		s.logger.Error("failed to get transactions by status",
+			logger.Field{Key: "status", Value: status},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to get transactions by status: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// SearchTransactions ищет транзакции по фильтру, новые первыми
+func (s *TransactionStorage) SearchTransactions(filter domain.TransactionFilter) ([]models.Transaction, error) {
+	// Условия собираем только из заданных полей, значения идут параметрами
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, value any) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.UserID != "" {
+		add("user_id = $%d", filter.UserID)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.Provider != "" {
+		add("provider = $%d", filter.Provider)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+
+	query := `
+	SELECT id, user_id, amount, fee, status, provider, external_id, created_at, updated_at
+	FROM transactions
+	`
+	if len(where) > 0 {
+		query += "WHERE " + strings.Join(where, " AND ") + "\n"
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf("ORDER BY created_at DESC\nLIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	transactions := []models.Transaction{}
+	if err := s.db.Select(&transactions, query, args...); err != nil {
+		s.logger.Error("failed to search transactions",
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+
+	return transactions, nil
+}
@@ -2,52 +2,59 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log/slog"
 	"time"
 
 	"ProxyMaster_v2/internal/domain"
 	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
 
 	"github.com/jmoiron/sqlx"
 )
 
 // UserStorage structure for working with users table
 type UserStorage struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	logger logger.Logger
 }
 
 // NewUserStorage is constructor for UserStorage struct
-func NewUserStorage(db *sqlx.DB) *UserStorage {
+func NewUserStorage(db *sqlx.DB, l logger.Logger) *UserStorage {
 	return &UserStorage{
-		db: db,
+		db:     db,
+		logger: l,
 	}
 }
 
 // CreateUser создает пользователя в DB
-func (s *UserStorage) CreateUser(userData models.CreateUserTGDTO) (*models.UserTG, error) {
+func (s *UserStorage) CreateUser(ctx context.Context, userData models.CreateUserTGDTO) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.CreateUser", "INSERT", "users")
+	defer func() { endSpan(span, err) }()
+
 	var user models.UserTG
 
 	query := `
-	INSERT INTO users (id, balance, trial, created_at)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, balance, trial, created_at
+	INSERT INTO users (id, balance, trial, email, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
 	`
 
 	now := time.Now()
-	err := s.db.QueryRowx(
+	err = s.db.QueryRowxContext(
+		ctx,
 		query,
 		userData.ID,
 		userData.Balance,
 		userData.Trial,
+		userData.Email,
 		now,
 	).StructScan(&user)
 	if err != nil {
-		slog.Error(
-			"failed to create user",
-			"error_message", err,
+		logger.FromContext(ctx, s.logger).Error("failed to create user",
+			logger.Field{Key: "error", Value: err},
 		)
 
 		return nil, fmt.Errorf("failed to scan struct: %w", err)
@@ -57,7 +64,10 @@ func (s *UserStorage) CreateUser(userData models.CreateUserTGDTO) (*models.UserT
 }
 
 // GetAllUsers is method for getting all users
-func (s *UserStorage) GetAllUsers() ([]models.UserTG, error) {
+func (s *UserStorage) GetAllUsers(ctx context.Context) (_ []models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.GetAllUsers", "SELECT", "users")
+	defer func() { endSpan(span, err) }()
+
 	var users []models.UserTG
 
 	query := `
@@ -66,10 +76,9 @@ func (s *UserStorage) GetAllUsers() ([]models.UserTG, error) {
 	ORDER BY created_at DESC
 	`
 
-	if err := s.db.Select(&users, query); err != nil {
-		slog.Error(
-			"failed to get users",
-			"error_message", err,
+	if err := s.db.SelectContext(ctx, &users, query); err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to get users",
+			logger.Field{Key: "error", Value: err},
 		)
 
 		return nil, fmt.Errorf("failed to get all users: %w", err)
@@ -79,68 +88,97 @@ func (s *UserStorage) GetAllUsers() ([]models.UserTG, error) {
 }
 
 // GetUserByID is methos for getting user by id
-func (s *UserStorage) GetUserByID(id string) (*models.UserTG, error) {
+func (s *UserStorage) GetUserByID(ctx context.Context, id string) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.GetUserByID", "SELECT", "users")
+	defer func() { endSpan(span, err) }()
+
 	var user models.UserTG
 	query := `
-	SELECT id, balance, trial, created_at
+	SELECT id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
 	FROM users
 	WHERE id = $1
 	`
 
-	if err := s.db.Get(&user, query, id); err != nil {
+	if err := s.db.GetContext(ctx, &user, query, id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			slog.Error(
-				"user not found",
-				"id", id,
-				"error_message", err,
+			// Обычная ситуация для нового пользователя, не ошибка
+			logger.FromContext(ctx, s.logger).Debug("user not found",
+				logger.Field{Key: "id", Value: id},
 			)
 
 			// Возвращем ошибку о том что пользователя нет в DB
 			return nil, domain.ErrUserNotFound
 		}
-		slog.Error(
-			"failed to get user",
-			"id", id,
-			"error_message", err,
+		logger.FromContext(ctx, s.logger).Error("failed to get user",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
 		)
+
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	return &user, nil
 }
 
-// UpdateUser обновляет юзера.
-func (s *UserStorage) UpdateUser(id string, updateData models.UpdateUserTGDTO) (*models.UserTG, error) {
-	user, err := s.GetUserByID(id)
-	if err != nil {
-		return nil, err
-	}
+// GetUserByEmail возвращает пользователя сайта по email
+func (s *UserStorage) GetUserByEmail(ctx context.Context, email string) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.GetUserByEmail", "SELECT", "users")
+	defer func() { endSpan(span, err) }()
 
-	if updateData.Balance != nil {
-		user.Balance = *updateData.Balance
-	}
+	var user models.UserTG
+	query := `
+	SELECT id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	FROM users
+	WHERE email = $1
+	`
+
+	if err := s.db.GetContext(ctx, &user, query, email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to get user by email",
+			logger.Field{Key: "error", Value: err},
+		)
 
-	if updateData.Trial != nil {
-		user.Trial = *updateData.Trial
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
+	return &user, nil
+}
+
+// UpdateUser обновляет юзера. Меняются только заданные поля, остальные
+// остаются как в DB: запись по старому снимку стерла бы параллельные изменения
+func (s *UserStorage) UpdateUser(ctx context.Context, id string, updateData models.UpdateUserTGDTO) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.UpdateUser", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
 	query := `
 	UPDATE users
-	SET balance = $1, trial = $2
-	WHERE id = $3
-	RETURNING id, balance, trial, created_at
+	SET trial = COALESCE($1, trial), banned = COALESCE($2, banned),
+		language = COALESCE($3, language), region = COALESCE($4, region),
+		extra_devices = COALESCE($5, extra_devices), traffic_notified = COALESCE($6, traffic_notified)
+	WHERE id = $7
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
 	`
 
 	var updatedUser models.UserTG
-	if err := s.db.QueryRowx(
+	if err := s.db.QueryRowxContext(
+		ctx,
 		query,
-		user.Balance,
-		user.Trial,
+		updateData.Trial,
+		updateData.Banned,
+		updateData.Language,
+		updateData.Region,
+		updateData.ExtraDevices,
+		updateData.TrafficNotified,
 		id,
 	).StructScan(&updatedUser); err != nil {
-		slog.Error(
-			"failed to update user",
-			"updateData", updateData,
-			"error_message", err,
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to update user",
+			logger.Field{Key: "updateData", Value: updateData},
+			logger.Field{Key: "error", Value: err},
 		)
 
 		return nil, fmt.Errorf("failed to scan struct: %w", err)
@@ -148,3 +186,229 @@ func (s *UserStorage) UpdateUser(id string, updateData models.UpdateUserTGDTO) (
 
 	return &updatedUser, nil
 }
+
+// AddBalance меняет баланс на delta относительно текущего значения в DB.
+// Списание проходит, только если баланса хватает
+func (s *UserStorage) AddBalance(ctx context.Context, id string, delta int) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.AddBalance", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET balance = COALESCE(balance, 0) + $2
+	WHERE id = $1 AND COALESCE(balance, 0) + $2 >= 0
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	`
+
+	var user models.UserTG
+	if err := s.db.QueryRowxContext(ctx, query, id, delta).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Строки нет либо из-за условия на баланс, либо пользователя нет вовсе
+			if _, getErr := s.GetUserByID(ctx, id); getErr != nil {
+				return nil, getErr
+			}
+
+			return nil, domain.ErrInsufficientFunds
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to add balance",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "delta", Value: delta},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to add balance: %w", err)
+	}
+
+	return &user, nil
+}
+
+// AddExtraDevice списывает price и добавляет докупленное устройство, если
+// баланса хватает и докуплено меньше maxExtra. Проверки и запись - один запрос,
+// поэтому два нажатия подряд не купят лишнее устройство
+func (s *UserStorage) AddExtraDevice(ctx context.Context, id string, price, maxExtra int) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.AddExtraDevice", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET balance = COALESCE(balance, 0) - $2, extra_devices = extra_devices + 1
+	WHERE id = $1 AND COALESCE(balance, 0) >= $2 AND extra_devices < $3
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	`
+
+	var user models.UserTG
+	if err := s.db.QueryRowxContext(ctx, query, id, price, maxExtra).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Разбираемся, какое из условий не выполнилось
+			current, getErr := s.GetUserByID(ctx, id)
+			if getErr != nil {
+				return nil, getErr
+			}
+			if current.ExtraDevices >= maxExtra {
+				return nil, domain.ErrDeviceLimit
+			}
+
+			return nil, domain.ErrInsufficientFunds
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to add extra device",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to add extra device: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CancelExtraDevice возвращает price на баланс и убирает докупленное устройство
+func (s *UserStorage) CancelExtraDevice(ctx context.Context, id string, price int) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.CancelExtraDevice", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET balance = COALESCE(balance, 0) + $2, extra_devices = GREATEST(extra_devices - 1, 0)
+	WHERE id = $1
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	`
+
+	var user models.UserTG
+	if err := s.db.QueryRowxContext(ctx, query, id, price).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to cancel extra device",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to cancel extra device: %w", err)
+	}
+
+	return &user, nil
+}
+
+// AddTrafficPack списывает price и добавляет пакет на gb гигабайт, если
+// баланса хватает. Время первого пакета периода нужно, чтобы убрать пакеты
+// после сброса трафика
+func (s *UserStorage) AddTrafficPack(ctx context.Context, id string, gb, price int) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.AddTrafficPack", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET balance = COALESCE(balance, 0) - $3, traffic_packs_gb = traffic_packs_gb + $2,
+		traffic_packs_at = COALESCE(traffic_packs_at, CURRENT_TIMESTAMP)
+	WHERE id = $1 AND COALESCE(balance, 0) >= $3
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	`
+
+	var user models.UserTG
+	if err := s.db.QueryRowxContext(ctx, query, id, gb, price).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, getErr := s.GetUserByID(ctx, id); getErr != nil {
+				return nil, getErr
+			}
+
+			return nil, domain.ErrInsufficientFunds
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to add traffic pack",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to add traffic pack: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CancelTrafficPack возвращает price на баланс и убирает пакет на gb гигабайт
+func (s *UserStorage) CancelTrafficPack(ctx context.Context, id string, gb, price int) (_ *models.UserTG, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.CancelTrafficPack", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET balance = COALESCE(balance, 0) + $3, traffic_packs_gb = GREATEST(traffic_packs_gb - $2, 0),
+		traffic_packs_at = CASE WHEN traffic_packs_gb - $2 > 0 THEN traffic_packs_at END
+	WHERE id = $1
+	RETURNING id, balance, trial, banned, language, region, extra_devices, traffic_notified, traffic_packs_gb, traffic_packs_at, email, created_at
+	`
+
+	var user models.UserTG
+	if err := s.db.QueryRowxContext(ctx, query, id, gb, price).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		logger.FromContext(ctx, s.logger).Error("failed to cancel traffic pack",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return nil, fmt.Errorf("failed to cancel traffic pack: %w", err)
+	}
+
+	return &user, nil
+}
+
+// ResetTrafficPacks убирает пакеты, если первый из них куплен до resetAt.
+// Пакет, купленный уже после сброса, условие не тронет
+func (s *UserStorage) ResetTrafficPacks(ctx context.Context, id string, resetAt time.Time) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.ResetTrafficPacks", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET traffic_packs_gb = 0, traffic_packs_at = NULL
+	WHERE id = $1 AND traffic_packs_at < $2
+	`
+
+	result, err := s.db.ExecContext(ctx, query, id, resetAt)
+	if err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to reset traffic packs",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to reset traffic packs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// ClaimTrial отмечает пробный период использованным. Условие в WHERE
+// не дает выдать его дважды при параллельных нажатиях
+func (s *UserStorage) ClaimTrial(ctx context.Context, id string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "UserStorage.ClaimTrial", "UPDATE", "users")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	UPDATE users
+	SET trial = TRUE
+	WHERE id = $1 AND trial = FALSE
+	`
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		logger.FromContext(ctx, s.logger).Error("failed to claim trial",
+			logger.Field{Key: "id", Value: id},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return false, fmt.Errorf("failed to claim trial: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,522 @@
+// Package http admin API для операторов: JSON поверх AdminService.
+// Доступ по ключу в заголовке X-API-Key или Authorization: Bearer,
+// имя ключа попадает в журнал изменений баланса.
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+const (
+	// adminPrefix общий префикс маршрутов admin API
+	adminPrefix = "/api/admin"
+	// defaultPageSize и maxPageSize размер страницы списков
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// maxAdminBody тела запросов маленькие, больше не читаем
+	maxAdminBody = 1 << 16
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// actorKey ключ контекста с именем ключа API
+type actorKey struct{}
+
+// AdminHandler admin API
+type AdminHandler struct {
+	admin domain.AdminService
+	// keys имя ключа -> ключ
+	keys map[string]string
+	// logLevels уровни логов, nil - маршруты /log-levels не регистрируются
+	logLevels LogLevels
+	logger    logger.Logger
+}
+
+// LogLevels уровни логов, которые можно менять на ходу (logger.Levels)
+type LogLevels interface {
+	// Snapshot текущие уровни: "" - по умолчанию, остальные ключи - модули
+	Snapshot() map[string]string
+	// Set меняет уровень модуля, пустой module - уровень по умолчанию
+	Set(module, level string) error
+	// Reset возвращает уровни из конфига
+	Reset()
+}
+
+// NewAdminHandler конструктор. Без ключей API не запустится:
+// открытый admin API хуже, чем никакого
+func NewAdminHandler(admin domain.AdminService, keys map[string]string, l logger.Logger) (*AdminHandler, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("admin API: не задано ни одного ключа")
+	}
+
+	return &AdminHandler{admin: admin, keys: keys, logger: l}, nil
+}
+
+// SetLogLevels включает просмотр и смену уровней логов через API
+func (h *AdminHandler) SetLogLevels(levels LogLevels) {
+	h.logLevels = levels
+}
+
+// Register регистрирует маршруты admin API
+func (h *AdminHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+adminPrefix+"/openapi.yaml", h.openAPI)
+	mux.Handle("GET "+adminPrefix+"/users", h.auth(h.listUsers))
+	mux.Handle("GET "+adminPrefix+"/users/{id}", h.auth(h.getUser))
+	mux.Handle("POST "+adminPrefix+"/users/{id}/balance", h.auth(h.adjustBalance))
+	mux.Handle("POST "+adminPrefix+"/users/{id}/subscription/extend", h.auth(h.extendSubscription))
+	mux.Handle("POST "+adminPrefix+"/users/{id}/subscription/enable", h.auth(h.enableSubscription))
+	mux.Handle("POST "+adminPrefix+"/users/{id}/subscription/disable", h.auth(h.disableSubscription))
+	mux.Handle("GET "+adminPrefix+"/transactions", h.auth(h.searchTransactions))
+	mux.Handle("POST "+adminPrefix+"/transactions/{id}/refund", h.auth(h.refund))
+
+	if h.logLevels != nil {
+		mux.Handle("GET "+adminPrefix+"/log-levels", h.auth(h.getLogLevels))
+		mux.Handle("PUT "+adminPrefix+"/log-levels", h.auth(h.setLogLevel))
+		mux.Handle("DELETE "+adminPrefix+"/log-levels", h.auth(h.resetLogLevels))
+	}
+}
+
+// userJSON пользователь в ответах API
+type userJSON struct {
+	ID        string    `json:"id"`
+	Balance   int       `json:"balance"`
+	Trial     bool      `json:"trial"`
+	Banned    bool      `json:"banned"`
+	Language  string    `json:"language"`
+	Email     *string   `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// remnawaveJSON подписка в панели
+type remnawaveJSON struct {
+	UUID              string    `json:"uuid"`
+	Status            string    `json:"status"`
+	ExpireAt          time.Time `json:"expire_at"`
+	SubscriptionURL   string    `json:"subscription_url"`
+	TrafficLimitBytes int       `json:"traffic_limit_bytes"`
+	UsedTrafficBytes  uint64    `json:"used_traffic_bytes"`
+	OnlineAt          time.Time `json:"online_at"`
+}
+
+// adjustmentJSON запись журнала баланса
+type adjustmentJSON struct {
+	ID        string    `json:"id"`
+	Delta     int       `json:"delta"`
+	Reason    string    `json:"reason"`
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// transactionJSON транзакция
+type transactionJSON struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Amount     int       `json:"amount"`
+	Fee        int       `json:"fee"`
+	Status     string    `json:"status"`
+	Provider   string    `json:"provider"`
+	ExternalID *string   `json:"external_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// openAPI отдает спецификацию API. Без ключа: в ней нет ничего секретного
+func (h *AdminHandler) openAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPISpec)
+}
+
+// listUsers GET /users?limit=&offset=
+func (h *AdminHandler) listUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, total, err := h.admin.ListUsers(r.Context(), limit, offset)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	items := make([]userJSON, 0, len(users))
+	for _, u := range users {
+		items = append(items, toUserJSON(u))
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// getUser GET /users/{id}
+func (h *AdminHandler) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.admin.GetUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	adjustments := make([]adjustmentJSON, 0, len(user.Adjustments))
+	for _, a := range user.Adjustments {
+		adjustments = append(adjustments, adjustmentJSON{ID: a.ID, Delta: a.Delta, Reason: a.Reason, Actor: a.Actor, CreatedAt: a.CreatedAt})
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"user":                toUserJSON(user.User),
+		"remnawave":           toRemnawaveJSON(user.Remnawave),
+		"remnawave_error":     user.RemnawaveError,
+		"balance_adjustments": adjustments,
+	})
+}
+
+// adjustBalance POST /users/{id}/balance {"delta": 100, "reason": "..."}
+func (h *AdminHandler) adjustBalance(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Delta  int    `json:"delta"`
+		Reason string `json:"reason"`
+	}
+	if !h.readJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.admin.AdjustBalance(r.Context(), r.PathValue("id"), req.Delta, req.Reason, actor(r.Context()))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, toUserJSON(*user))
+}
+
+// extendSubscription POST /users/{id}/subscription/extend {"days": 30}
+func (h *AdminHandler) extendSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Days int `json:"days"`
+	}
+	if !h.readJSON(w, r, &req) {
+		return
+	}
+	if req.Days <= 0 {
+		h.writeError(w, http.StatusBadRequest, "days должно быть больше нуля")
+		return
+	}
+
+	state, err := h.admin.ExtendSubscription(r.Context(), r.PathValue("id"), req.Days)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, toRemnawaveJSON(state))
+}
+
+// enableSubscription POST /users/{id}/subscription/enable
+func (h *AdminHandler) enableSubscription(w http.ResponseWriter, r *http.Request) {
+	h.setSubscriptionEnabled(w, r, true)
+}
+
+// disableSubscription POST /users/{id}/subscription/disable
+func (h *AdminHandler) disableSubscription(w http.ResponseWriter, r *http.Request) {
+	h.setSubscriptionEnabled(w, r, false)
+}
+
+// setSubscriptionEnabled общая часть enable/disable
+func (h *AdminHandler) setSubscriptionEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	state, err := h.admin.SetSubscriptionEnabled(r.Context(), r.PathValue("id"), enabled)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, toRemnawaveJSON(state))
+}
+
+// searchTransactions GET /transactions?user_id=&status=&provider=&from=&to=&limit=&offset=
+func (h *AdminHandler) searchTransactions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	filter := domain.TransactionFilter{
+		UserID:   q.Get("user_id"),
+		Status:   q.Get("status"),
+		Provider: q.Get("provider"),
+		Limit:    limit,
+		Offset:   offset,
+	}
+	if filter.From, err = parseTime(q.Get("from")); err != nil {
+		h.writeError(w, http.StatusBadRequest, "from: "+err.Error())
+		return
+	}
+	if filter.To, err = parseTime(q.Get("to")); err != nil {
+		h.writeError(w, http.StatusBadRequest, "to: "+err.Error())
+		return
+	}
+
+	transactions, err := h.admin.SearchTransactions(r.Context(), filter)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	items := make([]transactionJSON, 0, len(transactions))
+	for _, tx := range transactions {
+		items = append(items, toTransactionJSON(tx))
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"items": items, "limit": limit, "offset": offset})
+}
+
+// refund POST /transactions/{id}/refund
+func (h *AdminHandler) refund(w http.ResponseWriter, r *http.Request) {
+	tx, err := h.admin.Refund(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.logger.Info("возврат через admin API",
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+		logger.Field{Key: "actor", Value: actor(r.Context())},
+	)
+
+	h.writeJSON(w, http.StatusOK, toTransactionJSON(*tx))
+}
+
+// getLogLevels GET /log-levels
+func (h *AdminHandler) getLogLevels(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, logLevelsJSON(h.logLevels.Snapshot()))
+}
+
+// setLogLevel PUT /log-levels {"module": "remnawave", "level": "debug"}.
+// Без module меняется уровень по умолчанию
+func (h *AdminHandler) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Module string `json:"module"`
+		Level  string `json:"level"`
+	}
+	if !h.readJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.logLevels.Set(req.Module, req.Level); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.logger.Warn("уровень логов изменен через API",
+		logger.Field{Key: "actor", Value: actor(r.Context())},
+		logger.Field{Key: "module", Value: req.Module},
+		logger.Field{Key: "level", Value: req.Level},
+	)
+
+	h.writeJSON(w, http.StatusOK, logLevelsJSON(h.logLevels.Snapshot()))
+}
+
+// resetLogLevels DELETE /log-levels - вернуть уровни из конфига
+func (h *AdminHandler) resetLogLevels(w http.ResponseWriter, r *http.Request) {
+	h.logLevels.Reset()
+	h.logger.Warn("уровни логов сброшены через API", logger.Field{Key: "actor", Value: actor(r.Context())})
+
+	h.writeJSON(w, http.StatusOK, logLevelsJSON(h.logLevels.Snapshot()))
+}
+
+// auth пускает только с известным ключом и кладет его имя в контекст
+func (h *AdminHandler) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+
+		name, ok := h.keyName(key)
+		if !ok {
+			h.logger.Warn("запрос к admin API без верного ключа", logger.Field{Key: "remote_addr", Value: r.RemoteAddr})
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, name)))
+	})
+}
+
+// keyName имя ключа. Сравниваем со всеми ключами за одинаковое время,
+// чтобы по времени ответа нельзя было подбирать ключ
+func (h *AdminHandler) keyName(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	found := ""
+	for name, k := range h.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = name
+		}
+	}
+
+	return found, found != ""
+}
+
+// actor имя ключа из контекста запроса
+func actor(ctx context.Context) string {
+	name, _ := ctx.Value(actorKey{}).(string)
+
+	return name
+}
+
+// readJSON читает тело запроса. false - ответ с ошибкой уже отправлен
+func (h *AdminHandler) readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBody))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		h.writeError(w, http.StatusBadRequest, "неверный JSON: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+// writeServiceError переводит ошибку сервиса в HTTP статус
+func (h *AdminHandler) writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrTransactionNotFound),
+		errors.Is(err, domain.ErrSubscriptionNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidAdjustment):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		h.writeError(w, http.StatusConflict, err.Error())
+	default:
+		// API только для операторов, текст ошибки им полезнее, чем "internal error".
+		// Но без секретов: в ошибках клиентов бывают адреса с токенами
+		h.logger.Error("ошибка admin API", logger.Field{Key: "error", Value: err})
+		h.writeError(w, http.StatusInternalServerError, logger.Redact(err.Error()))
+	}
+}
+
+// writeError ответ с ошибкой {"error": "..."}
+func (h *AdminHandler) writeError(w http.ResponseWriter, status int, msg string) {
+	h.writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeJSON ответ в JSON
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("ошибка записи ответа admin API", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// pageParams limit и offset из запроса
+func pageParams(r *http.Request) (int, int, error) {
+	q := r.URL.Query()
+
+	limit := defaultPageSize
+	if raw := q.Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxPageSize {
+			return 0, 0, errors.New("limit должен быть от 1 до " + strconv.Itoa(maxPageSize))
+		}
+		limit = v
+	}
+
+	offset := 0
+	if raw := q.Get("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return 0, 0, errors.New("offset должен быть неотрицательным числом")
+		}
+		offset = v
+	}
+
+	return limit, offset, nil
+}
+
+// parseTime время в RFC 3339 или дата 2006-01-02. Пусто - нулевое время
+func parseTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, raw)
+}
+
+// logLevelsJSON ответ /log-levels: {"default": "info", "modules": {"remnawave": "debug"}}
+func logLevelsJSON(snapshot map[string]string) map[string]any {
+	modules := make(map[string]string, len(snapshot))
+	for name, level := range snapshot {
+		if name != "" {
+			modules[name] = level
+		}
+	}
+
+	return map[string]any{"default": snapshot[""], "modules": modules}
+}
+
+// toUserJSON пользователь для ответа
+func toUserJSON(u models.UserTG) userJSON {
+	return userJSON{
+		ID:        u.ID,
+		Balance:   u.Balance,
+		Trial:     u.Trial,
+		Banned:    u.Banned,
+		Language:  u.Language,
+		Email:     u.Email,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// toRemnawaveJSON подписка для ответа, nil - null
+func toRemnawaveJSON(s *domain.RemnawaveState) *remnawaveJSON {
+	if s == nil {
+		return nil
+	}
+
+	return &remnawaveJSON{
+		UUID:              s.UUID,
+		Status:            s.Status,
+		ExpireAt:          s.ExpireAt,
+		SubscriptionURL:   s.SubscriptionURL,
+		TrafficLimitBytes: s.TrafficLimitBytes,
+		UsedTrafficBytes:  s.UsedTrafficBytes,
+		OnlineAt:          s.OnlineAt,
+	}
+}
+
+// toTransactionJSON транзакция для ответа
+func toTransactionJSON(tx models.Transaction) transactionJSON {
+	return transactionJSON{
+		ID:         tx.ID,
+		UserID:     tx.UserID,
+		Amount:     tx.Amount,
+		Fee:        tx.Fee,
+		Status:     tx.Status,
+		Provider:   tx.Provider,
+		ExternalID: tx.ExternalID,
+		CreatedAt:  tx.CreatedAt,
+		UpdatedAt:  tx.UpdatedAt,
+	}
+}
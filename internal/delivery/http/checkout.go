@@ -0,0 +1,287 @@
+// Package http страницы покупки подписки: вход по Telegram ID или email
+// с одноразовым кодом, выбор тарифа и платежки, оплата и ссылка на подписку.
+// Вся логика в WebAuthService и CheckoutService, тут только HTML.
+package http
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// sessionCookie имя cookie с токеном сессии
+const sessionCookie = "pm_session"
+
+// orderRefresh как часто страница заказа сама проверяет оплату
+const orderRefresh = 10 * time.Second
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+// checkoutPage данные для шаблонов. Каждая страница берет нужные поля
+type checkoutPage struct {
+	Error     string
+	Info      string
+	Login     string
+	Tariffs   []domain.Tariff
+	Providers []domain.PaymentProvider
+	Order     *models.Order
+	// PaymentURL ссылка на оплату заказа, SubscriptionURL - на подписку
+	PaymentURL      string
+	SubscriptionURL string
+	// Refresh секунд до автообновления страницы, 0 - без него
+	Refresh int
+}
+
+// CheckoutHandler сайт покупки подписки
+type CheckoutHandler struct {
+	auth     domain.WebAuthService
+	checkout domain.CheckoutService
+	pages    *template.Template
+	logger   logger.Logger
+}
+
+// NewCheckoutHandler конструктор. Шаблоны вшиты в бинарник и
+// разбираются сразу, ошибка в них - ошибка запуска
+func NewCheckoutHandler(auth domain.WebAuthService, checkout domain.CheckoutService, l logger.Logger) (*CheckoutHandler, error) {
+	pages, err := template.ParseFS(templatesFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckoutHandler{
+		auth:     auth,
+		checkout: checkout,
+		pages:    pages,
+		logger:   l,
+	}, nil
+}
+
+// Register регистрирует страницы сайта
+func (h *CheckoutHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", h.index)
+	mux.HandleFunc("GET /login", h.loginForm)
+	mux.HandleFunc("POST /login", h.requestCode)
+	mux.HandleFunc("GET /login/code", h.codeForm)
+	mux.HandleFunc("POST /login/code", h.verifyCode)
+	mux.HandleFunc("POST /logout", h.logout)
+	mux.HandleFunc("GET /tariffs", h.requireUser(h.tariffs))
+	mux.HandleFunc("POST /orders", h.requireUser(h.createOrder))
+	mux.HandleFunc("GET /orders/{id}", h.requireUser(h.order))
+}
+
+// index без входа ведет на вход, со входом - к тарифам
+func (h *CheckoutHandler) index(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.sessionUser(r); ok {
+		http.Redirect(w, r, "/tariffs", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// loginForm форма ввода Telegram ID или email
+func (h *CheckoutHandler) loginForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, http.StatusOK, "login.html", checkoutPage{})
+}
+
+// requestCode отправляет код и ведет на форму ввода кода
+func (h *CheckoutHandler) requestCode(w http.ResponseWriter, r *http.Request) {
+	login := r.PostFormValue("login")
+
+	err := h.auth.RequestCode(r.Context(), login)
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrCodeRequestedRecently):
+		// Код уже отправлен, просто даем его ввести
+	case errors.Is(err, domain.ErrInvalidLogin):
+		h.render(w, http.StatusBadRequest, "login.html", checkoutPage{Login: login, Error: "Введите Telegram ID (только цифры) или email"})
+		return
+	default:
+		h.logger.Warn("код входа не отправлен", logger.Field{Key: "error", Value: err})
+		h.render(w, http.StatusBadGateway, "login.html", checkoutPage{
+			Login: login,
+			Error: "Не удалось отправить код. Если входите по Telegram ID, сначала запустите бота командой /start",
+		})
+		return
+	}
+
+	http.Redirect(w, r, "/login/code?login="+url.QueryEscape(login), http.StatusSeeOther)
+}
+
+// codeForm форма ввода кода
+func (h *CheckoutHandler) codeForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, http.StatusOK, "code.html", checkoutPage{Login: r.URL.Query().Get("login")})
+}
+
+// verifyCode проверяет код и ставит cookie сессии
+func (h *CheckoutHandler) verifyCode(w http.ResponseWriter, r *http.Request) {
+	login := r.PostFormValue("login")
+
+	userID, err := h.auth.VerifyCode(r.Context(), login, r.PostFormValue("code"))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCode) || errors.Is(err, domain.ErrInvalidLogin) {
+			h.render(w, http.StatusUnauthorized, "code.html", checkoutPage{Login: login, Error: "Неверный или устаревший код"})
+			return
+		}
+
+		h.serverError(w, err)
+		return
+	}
+
+	token, expiresAt := h.auth.NewSession(userID)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		// Lax: cookie не уходит с POST запросами с чужих сайтов, это и есть защита от CSRF
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/tariffs", http.StatusSeeOther)
+}
+
+// logout удаляет cookie сессии
+func (h *CheckoutHandler) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// tariffs выбор срока подписки и платежки
+func (h *CheckoutHandler) tariffs(w http.ResponseWriter, _ *http.Request, _ string) {
+	h.render(w, http.StatusOK, "tariffs.html", checkoutPage{
+		Tariffs:   h.checkout.Tariffs(),
+		Providers: h.checkout.Providers(),
+	})
+}
+
+// createOrder создает заказ и ведет на его страницу
+func (h *CheckoutHandler) createOrder(w http.ResponseWriter, r *http.Request, userID string) {
+	months, err := strconv.Atoi(r.PostFormValue("months"))
+	if err != nil {
+		h.renderTariffsError(w, http.StatusBadRequest, "Выберите тариф")
+		return
+	}
+
+	order, _, err := h.checkout.CreateOrder(r.Context(), userID, months, r.PostFormValue("provider"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnknownTariff), errors.Is(err, domain.ErrUnknownProvider):
+			h.renderTariffsError(w, http.StatusBadRequest, "Выберите тариф и способ оплаты")
+		case errors.Is(err, domain.ErrAmountOutOfRange):
+			h.renderTariffsError(w, http.StatusBadRequest, "Сумма вне лимитов этого способа оплаты, выберите другой")
+		case errors.Is(err, domain.ErrMaintenance):
+			h.renderTariffsError(w, http.StatusServiceUnavailable, "Идут технические работы, покупка временно недоступна. Попробуйте позже")
+		default:
+			h.logger.Error("ошибка создания заказа", logger.Field{Key: "user_id", Value: userID}, logger.Field{Key: "error", Value: err})
+			h.renderTariffsError(w, http.StatusBadGateway, "Не удалось создать счет, попробуйте позже")
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/orders/"+url.PathEscape(order.ID), http.StatusSeeOther)
+}
+
+// order страница заказа: ссылка на оплату, пока не оплачен, и ссылка на подписку после
+func (h *CheckoutHandler) order(w http.ResponseWriter, r *http.Request, userID string) {
+	order, subscriptionURL, err := h.checkout.CheckOrder(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrOrderNotFound):
+			http.NotFound(w, r)
+		case errors.Is(err, domain.ErrInsufficientFunds):
+			h.renderTariffsError(w, http.StatusConflict, "Оплата получена, но баланса не хватает на тариф. Деньги на балансе, выберите тариф еще раз")
+		default:
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	page := checkoutPage{Order: order, SubscriptionURL: subscriptionURL}
+	if order.Status == string(domain.OrderStatusPending) {
+		page.PaymentURL = order.PaymentURL
+		page.Refresh = int(orderRefresh.Seconds())
+	}
+
+	h.render(w, http.StatusOK, "order.html", page)
+}
+
+// renderTariffsError страница тарифов с ошибкой
+func (h *CheckoutHandler) renderTariffsError(w http.ResponseWriter, status int, msg string) {
+	h.render(w, status, "tariffs.html", checkoutPage{
+		Error:     msg,
+		Tariffs:   h.checkout.Tariffs(),
+		Providers: h.checkout.Providers(),
+	})
+}
+
+// requireUser пускает только с действующей сессией, остальных ведет на вход
+func (h *CheckoutHandler) requireUser(next func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.sessionUser(r)
+		if !ok {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		next(w, r, userID)
+	}
+}
+
+// sessionUser id пользователя из cookie сессии
+func (h *CheckoutHandler) sessionUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+
+	userID, err := h.auth.SessionUser(cookie.Value)
+	if err != nil {
+		return "", false
+	}
+
+	return userID, true
+}
+
+// render отдает страницу из шаблона name
+func (h *CheckoutHandler) render(w http.ResponseWriter, status int, name string, page checkoutPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' 'unsafe-inline'")
+	w.WriteHeader(status)
+
+	if err := h.pages.ExecuteTemplate(w, name, page); err != nil {
+		h.logger.Error("ошибка отрисовки страницы", logger.Field{Key: "page", Value: name}, logger.Field{Key: "error", Value: err})
+	}
+}
+
+// serverError логирует ошибку, а пользователю показывает общую страницу
+func (h *CheckoutHandler) serverError(w http.ResponseWriter, err error) {
+	h.logger.Error("ошибка сайта", logger.Field{Key: "error", Value: err})
+	h.render(w, http.StatusInternalServerError, "error.html", checkoutPage{Error: "Что-то пошло не так, попробуйте позже"})
+}
+
+// isHTTPS пришел ли запрос по https. За reverse proxy смотрим X-Forwarded-Proto
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,128 @@
+// Package http проверки здоровья для docker/k8s: /healthz (живо ли
+// приложение, можно ли его не перезапускать) и /readyz (готово ли работать:
+// доступны все внешние зависимости). Ответ - JSON со статусом и временем
+// каждой проверки.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// healthCheckTimeout сколько ждем одну зависимость
+const healthCheckTimeout = 3 * time.Second
+
+// HealthCheck проверка одной зависимости
+type HealthCheck struct {
+	// Name имя в ответе: postgres, remnawave, telegram
+	Name string
+	// Check возвращает ошибку, если зависимость недоступна
+	Check func(ctx context.Context) error
+	// Liveness ошибка этой проверки валит и /healthz, то есть перезапуск
+	// может помочь. Для удаленных сервисов (панель) перезапуск бесполезен,
+	// они влияют только на /readyz
+	Liveness bool
+}
+
+// HealthHandler /healthz и /readyz
+type HealthHandler struct {
+	checks []HealthCheck
+	logger logger.Logger
+}
+
+// NewHealthHandler конструктор
+func NewHealthHandler(checks []HealthCheck, l logger.Logger) *HealthHandler {
+	return &HealthHandler{checks: checks, logger: l}
+}
+
+// healthResult результат одной проверки
+type healthResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthReport ответ /healthz и /readyz
+type healthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]healthResult `json:"checks"`
+}
+
+// Register регистрирует /healthz и /readyz
+func (h *HealthHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /readyz", h.readyz)
+}
+
+// healthz 503, если упала хотя бы одна Liveness проверка
+func (h *HealthHandler) healthz(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, func(check HealthCheck) bool { return check.Liveness })
+}
+
+// readyz 503, если упала любая проверка
+func (h *HealthHandler) readyz(w http.ResponseWriter, r *http.Request) {
+	h.report(w, r, func(HealthCheck) bool { return true })
+}
+
+// report запускает все проверки параллельно и отдает отчет. critical решает,
+// какие упавшие проверки делают ответ 503. Остальные видны в отчете, но на код не влияют
+func (h *HealthHandler) report(w http.ResponseWriter, r *http.Request, critical func(HealthCheck) bool) {
+	results := make([]healthResult, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(r.Context(), check)
+		}()
+	}
+	wg.Wait()
+
+	report := healthReport{Status: "ok", Checks: make(map[string]healthResult, len(h.checks))}
+	status := http.StatusOK
+	for i, check := range h.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+
+		if result.Status == "ok" {
+			continue
+		}
+		h.logger.Warn("проверка здоровья не прошла",
+			logger.Field{Key: "check", Value: check.Name},
+			logger.Field{Key: "error", Value: result.Error},
+		)
+		if critical(check) {
+			report.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.Error("ошибка отправки отчета о здоровье", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// runHealthCheck выполняет проверку с таймаутом и замеряет время
+func runHealthCheck(ctx context.Context, check HealthCheck) healthResult {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	result := healthResult{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,31 @@
+// Package http HTTP сервер приложения: сайт для покупки подписки без
+// телеграма и admin API. Каждая часть регистрирует свои маршруты на общем mux,
+// как экраны бота регистрируют маршруты в CallbackRouter.
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// Handler часть HTTP сервера со своими маршрутами
+type Handler interface {
+	Register(mux *http.ServeMux)
+}
+
+// NewServer собирает HTTP сервер на addr из частей
+func NewServer(addr string, handlers ...Handler) *http.Server {
+	mux := http.NewServeMux()
+	for _, h := range handlers {
+		h.Register(mux)
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
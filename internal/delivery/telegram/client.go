@@ -2,12 +2,27 @@
 package telegram
 
 import (
-	"fmt"
-	"log/slog"
+	"context"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
+// paymentRetryDelays паузы перед повторами оплаты, которую не удалось обработать.
+// Вместе укладываются в 10 секунд, которые телеграм ждет ответа на pre_checkout_query
+var paymentRetryDelays = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+const (
+	// callbackRetention сколько хранить ключи идемпотентности callback. Телеграм
+	// хранит необработанные обновления сутки, старше повторно не придут
+	callbackRetention = 48 * time.Hour
+	// callbackCleanupInterval как часто удалять старые ключи
+	callbackCleanupInterval = time.Hour
+)
+
 // Command - интерфейс для всех команд бота, /start /help и прочих
 // нужен, чтобы следовать принципам SOLID. Закрыт для изменений
 // добавлять будем через мапу, так минимальные шансы что-то
@@ -19,112 +34,283 @@ type Command interface {
 	// Execute что делаем со строкой
 	// 1. tgbotapi. Update - внутри Update лежит все что прислал пользователь
 	// текст сообщения ("Привет", "/start"), кто он (ChatID, UserID), имя и т.д.
-	// 2. tgbotapi. BotAPI - делает запросы в телеграм. Send, DeleteMessage, KickChatMember (выгнать) и т.д.
-	Execute(update tgbotapi.Update, bot *tgbotapi.BotAPI) error
+	// 2. Messenger - через него отвечаем пользователю. Send, Edit, AnswerCallback и т.д.
+	Execute(ctx context.Context, update tgbotapi.Update, messenger Messenger) error
 }
 
 // Client - зависимости для телеграм
 type Client struct {
-	// Само апи телеграмма
+	// Само апи телеграмма, нужно только для получения обновлений
 	bot *tgbotapi.BotAPI
+	// Через него команды и кнопки отвечают пользователю
+	messenger Messenger
 	// Команды которые бот должен обработать. /start /help и т.д.
 	commands map[string]Command
 	// Обработчик кнопок
-	callbackHandler func(tgbotapi.Update, *tgbotapi.BotAPI) error
+	callbackHandler HandlerFunc
+	// Обработчики оплаты счетов: pre_checkout_query и successful_payment
+	preCheckoutHandler HandlerFunc
+	paymentHandler     HandlerFunc
+	// Откуда берем обновления: long polling или webhook
+	source UpdateSource
+	// Где храним последний обработанный update_id, чтобы после рестарта
+	// не обрабатывать старые обновления. Может быть nil
+	state domain.BotStateRepository
+	// Общие обертки вокруг команд и кнопок (логи, лимиты, доступ)
+	middlewares []Middleware
+
+	logger logger.Logger
 }
 
-// NewClient - экземпляр бота
-func NewClient(bot *tgbotapi.BotAPI) *Client {
-	fmt.Println("Создан экземпляр TelegramClient")
+// NewClient - экземпляр бота. По умолчанию обновления получаем через long polling
+func NewClient(bot *tgbotapi.BotAPI, l logger.Logger) *Client {
+	l.Info("Создан экземпляр TelegramClient")
 	return &Client{
-		bot:      bot,
-		commands: make(map[string]Command),
+		bot:       bot,
+		messenger: NewBotMessenger(bot),
+		commands:  make(map[string]Command),
+		source:    NewPollingSource(bot, l),
+		logger:    l,
 	}
 }
 
+// Use добавляет middleware. Порядок важен: первый добавленный - самый внешний
+func (c *Client) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// SetStateRepository включает сохранение offset обновлений в DB
+func (c *Client) SetStateRepository(state domain.BotStateRepository) {
+	c.state = state
+}
+
+// SetUpdateSource меняет источник обновлений (например на webhook)
+func (c *Client) SetUpdateSource(source UpdateSource) {
+	c.source = source
+}
+
 // SetCallbackHandler устанавливает обработчик кнопок
-func (c *Client) SetCallbackHandler(handler func(tgbotapi.Update, *tgbotapi.BotAPI) error) {
+func (c *Client) SetCallbackHandler(handler HandlerFunc) {
 	c.callbackHandler = handler
 }
 
+// SetPreCheckoutHandler устанавливает обработчик pre_checkout_query:
+// последняя проверка перед списанием денег, ответить нужно за 10 секунд
+func (c *Client) SetPreCheckoutHandler(handler HandlerFunc) {
+	c.preCheckoutHandler = handler
+}
+
+// SetPaymentHandler устанавливает обработчик сообщений successful_payment
+func (c *Client) SetPaymentHandler(handler HandlerFunc) {
+	c.paymentHandler = handler
+}
+
 // RegisterCommand - занимается регистрацией команд в боте
 func (c *Client) RegisterCommand(cmd Command) {
 	c.commands[cmd.Name()] = cmd
 }
 
-// Run - запуск цикла получения сообщения
-func (c *Client) Run() {
-	// получаем канал обновлений
-	updates, err := c.initUpdatesChannel()
+// Ping проверяет токен и связь с Bot API через getMe. Сама библиотека
+// не принимает контекст, поэтому по его отмене просто перестаем ждать
+func (c *Client) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.bot.GetMe()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run - запуск цикла получения сообщения. Работает пока не отменят ctx
+// или пока источник не закроет канал
+func (c *Client) Run(ctx context.Context) {
+	// получаем канал обновлений. Это как уши: благодаря ему
+	// программа ждет сообщение и не завершается
+	offset := c.loadOffset()
+	updates, err := c.source.Start(offset)
 	if err != nil {
-		slog.Error("ошибка при запуске прослушивания", "error", err)
+		c.logger.Error("ошибка при запуске прослушивания", logger.Field{Key: "error", Value: err})
 		return
 	}
 
+	// При выходе останавливаем источник (для webhook это снимет регистрацию)
+	defer c.stopSource()
+
+	// Ключи идемпотентности чистим в том же цикле, что сохраняет offset
+	cleanup := time.NewTicker(callbackCleanupInterval)
+	defer cleanup.Stop()
+
 	// читаем сообщения из канала в бесконечном цикле
-	for update := range updates {
-		// Если пришла команда, обрабатываем ее
-		if update.Message != nil {
-			fmt.Println("telegram message:", update.Message.From.ID, update.Message.Text)
-			if update.Message.IsCommand() {
-				c.handleUpdate(update)
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			c.deleteOldCallbacks()
+			continue
+		case u, ok := <-updates:
+			if !ok {
+				return
 			}
+			update = u
 		}
 
-		// Если пришел callback (кнопка), обрабатываем ее
-		if update.CallbackQuery != nil {
-			fmt.Println("telegram callback:", update.CallbackQuery.From.ID, update.CallbackQuery.Data)
-			c.handleCallback(update)
+		// Уже обработано до рестарта (webhook может прислать повторно)
+		if update.UpdateID < offset {
 			continue
 		}
+
+		if err := c.dispatch(ctx, update); err != nil && isPayment(update) {
+			c.retryPayment(ctx, update)
+		}
+
+		// Сохраняем только после обработки: если упадем посередине,
+		// обновление придет еще раз, а от двойной покупки защищает ключ идемпотентности
+		offset = update.UpdateID + 1
+		c.saveOffset(update.UpdateID)
 	}
 }
 
-func (c *Client) handleCallback(update tgbotapi.Update) {
-	if c.callbackHandler != nil {
-		if err := c.callbackHandler(update, c.bot); err != nil {
-			slog.Error("ошибка в callback handler", "error", err)
+// retryPayment повторяет обработку оплаты. Телеграм не пришлет ее второй
+// раз, поэтому повторяем сами, пока не сохранили offset. Повтор безопасен:
+// оплата зачисляется только при переходе транзакции в success
+func (c *Client) retryPayment(ctx context.Context, update tgbotapi.Update) {
+	for _, delay := range paymentRetryDelays {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if err := c.dispatch(ctx, update); err == nil {
+			return
 		}
 	}
 }
 
-// HandleCommands роутинг команды. Принимает сообщение которое
-// пришло в Run() и решает что дальше с ним делать
-func (c *Client) handleUpdate(update tgbotapi.Update) {
-	cmdName := update.Message.Command()
+// loadOffset возвращает update_id, с которого нужно продолжить
+func (c *Client) loadOffset() int {
+	if c.state == nil {
+		return 0
+	}
+
+	lastID, err := c.state.GetLastUpdateID()
+	if err != nil {
+		c.logger.Error("не удалось получить последний update_id, начинаем с начала", logger.Field{Key: "error", Value: err})
+		return 0
+	}
+	if lastID == 0 {
+		return 0
+	}
 
-	command, exists := c.commands[cmdName]
+	return lastID + 1
+}
 
-	if exists {
-		if err := command.Execute(update, c.bot); err != nil {
-			slog.Error("пользователь существует", "command", cmdName, "error", err)
-		}
+// saveOffset запоминает последний обработанный update_id
+func (c *Client) saveOffset(updateID int) {
+	if c.state == nil {
+		return
+	}
+
+	if err := c.state.SaveLastUpdateID(updateID); err != nil {
+		c.logger.Error("не удалось сохранить update_id",
+			logger.Field{Key: "update_id", Value: updateID},
+			logger.Field{Key: "error", Value: err},
+		)
 	}
 }
 
-// initUpdatesChannel - это как уши. Он слушает что приходит
-// благодаря этому методу программа ждет сообщение и не завершается
-// Есть два подхода
-// ---
-// 1. обычный подход Short Polling: бегаешь к почтовому ящику каждые 5 секунд,
-// открываешь его и проверяешь. Пусто. Бежишь назад. Через 5 секунд снова
-// Это лишние обращения к процессору и серверам телеги
-// ---
-// 2. подход Long Polling: подходишь к почтовому ящику, открываешь
-// его. Стоишь и ждешь так 60 секунд если письмо есть,
-// берем. Если не нет, то закрываем ящик, а
-// потом опять открываем и ждем 60 секунд
-func (c *Client) initUpdatesChannel() (tgbotapi.UpdatesChannel, error) {
-	// Это настройка запроса. 0 - дает все с самого
-	// начала и то что еще не обработал
-	u := tgbotapi.NewUpdate(0)
-
-	// сколько ждем
-	u.Timeout = 60
-
-	updates, err := c.bot.GetUpdatesChan(u)
+// deleteOldCallbacks удаляет ключи идемпотентности, для которых телеграм
+// уже не пришлет повтор
+func (c *Client) deleteOldCallbacks() {
+	if c.state == nil {
+		return
+	}
+
+	deleted, err := c.state.DeleteProcessedCallbacks(callbackRetention)
+	if err != nil {
+		c.logger.Error("не удалось удалить старые ключи идемпотентности", logger.Field{Key: "error", Value: err})
+		return
+	}
+	if deleted > 0 {
+		c.logger.Info("удалены старые ключи идемпотентности", logger.Field{Key: "count", Value: deleted})
+	}
+}
+
+// stopSource останавливает источник обновлений, давая ему немного времени
+func (c *Client) stopSource() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.source.Stop(ctx); err != nil {
+		c.logger.Error("ошибка остановки источника обновлений", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// dispatch решает, кому отдать обновление: команде или обработчику кнопок,
+// и пропускает его через middleware. Ошибку обработчика логирует и возвращает
+func (c *Client) dispatch(ctx context.Context, update tgbotapi.Update) error {
+	handler := c.route(update)
+	if handler == nil {
+		return nil
+	}
+
+	// Остановка бота не должна обрывать начатую обработку: покупка, у которой
+	// уже списан баланс, должна дойти до панели. Отмену не наследуем, спаны и значения - да
+	ctx = context.WithoutCancel(ctx)
+
+	// Эти поля попадут во все логи обработки через logger.FromContext
+	ctx = logger.WithContext(ctx, logger.Field{Key: "update_id", Value: update.UpdateID})
+	if user := updateUser(update); user != nil {
+		ctx = logger.WithContext(ctx, logger.Field{Key: "user_id", Value: user.ID})
+	}
+
+	err := chain(handler, c.middlewares)(ctx, update, c.messenger)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка получения канала обновлений: %w", err)
+		logger.FromContext(ctx, c.logger).Error("ошибка обработки обновления",
+			logger.Field{Key: "route", Value: updateRoute(update)},
+			logger.Field{Key: "error", Value: err},
+		)
+	}
+
+	return err
+}
+
+// route находит обработчик обновления. nil если обрабатывать нечего
+func (c *Client) route(update tgbotapi.Update) HandlerFunc {
+	// Если пришел callback (кнопка), обрабатываем ее
+	if update.CallbackQuery != nil {
+		if c.callbackHandler == nil {
+			return nil
+		}
+
+		return c.callbackHandler
 	}
-	return updates, nil
+
+	// Оплата счета: сначала pre_checkout_query, после списания - successful_payment
+	if update.PreCheckoutQuery != nil {
+		return c.preCheckoutHandler
+	}
+	if update.Message != nil && update.Message.SuccessfulPayment != nil {
+		return c.paymentHandler
+	}
+
+	// Если пришла команда, ищем ее среди зарегистрированных
+	if update.Message != nil && update.Message.IsCommand() {
+		command, exists := c.commands[update.Message.Command()]
+		if !exists {
+			return nil
+		}
+
+		return command.Execute
+	}
+
+	return nil
 }
@@ -2,6 +2,10 @@
 package telegram
 
 import (
+	"fmt"
+
+	"ProxyMaster_v2/internal/domain"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
@@ -23,82 +27,247 @@ func (k *KeyboardBuilder) buildFromSlice(options []string) tgbotapi.InlineKeyboa
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
-// NewMainMenuKeyboard создает главное меню
-func NewMainMenuKeyboard(telegramSupport, subscriptionURL string) tgbotapi.InlineKeyboardMarkup {
+// NewMainMenuKeyboard создает главное меню. trialDays - доступный пробный
+// период в днях, 0 - кнопки пробного периода нет
+func NewMainMenuKeyboard(loc domain.Localizer, telegramSupport, subscriptionURL string, trialDays int) tgbotapi.InlineKeyboardMarkup {
 	// Если подписки нет (URL пустой), показываем предложение купить
 	if subscriptionURL == "" {
-		return tgbotapi.NewInlineKeyboardMarkup(
+		var rows [][]tgbotapi.InlineKeyboardButton
+		if trialDays > 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.trial", trialDays), RouteTrial.Data()),
+			))
+		}
+		rows = append(rows,
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("📦 Оформить подписку", "tariffs"),
+				tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.subscribe"), RouteTariffs.Data()),
 			),
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonURL("🆘 Поддержка", telegramSupport),
+				tgbotapi.NewInlineKeyboardButtonURL(loc.T("btn.support"), telegramSupport),
 			),
 		)
+
+		return tgbotapi.NewInlineKeyboardMarkup(rows...)
 	}
 
 	// Если есть подписка, показываем полное меню
-	connectBtn := tgbotapi.NewInlineKeyboardButtonURL("🔗 Подключить", subscriptionURL)
+	connectBtn := tgbotapi.NewInlineKeyboardButtonURL(loc.T("btn.connect"), subscriptionURL)
 
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			connectBtn,
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📦 Продлить подписку", "tariffs"),
-			tgbotapi.NewInlineKeyboardButtonData("👤 Личный кабинет", "profile"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.extend"), RouteTariffs.Data()),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.profile"), RouteProfile.Data()),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.devices"), RouteDevices.Data()),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.traffic"), RouteTraffic.Data()),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.revoke"), RouteRevoke.Data()),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL("🆘 Поддержка", telegramSupport),
-			tgbotapi.NewInlineKeyboardButtonData("ℹ️ Инфо", "info"),
+			tgbotapi.NewInlineKeyboardButtonURL(loc.T("btn.support"), telegramSupport),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.info"), RouteInfo.Data()),
 		),
 	)
 }
 
-// NewTariffsKeyboard создает клавиатуру с выбором тарифов
-func NewTariffsKeyboard() tgbotapi.InlineKeyboardMarkup {
+// NewTariffsKeyboard создает клавиатуру с выбором тарифов. region - название
+// выбранного региона, пусто - выбирать не из чего и кнопки смены нет
+func NewTariffsKeyboard(loc domain.Localizer, region string) tgbotapi.InlineKeyboardMarkup {
+	// Название тарифа берем из каталога: tariffs.months_N
+	tariffs := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.TariffMonths))
+	for _, months := range domain.TariffMonths {
+		label := loc.T(fmt.Sprintf("tariffs.months_%d", months))
+		tariffs = append(tariffs, tgbotapi.NewInlineKeyboardButtonData(label, RouteTariff.Data(fmt.Sprint(months))))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardRow(tariffs...)}
+	if region != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.region_current", region), RouteRegions.Data(RouteTariffs.Name())),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// NewProfileKeyboard создает клавиатуру личного кабинета
+func NewProfileKeyboard(loc domain.Localizer) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("1 месяц", "create_user_1"),
-			tgbotapi.NewInlineKeyboardButtonData("2 месяца", "create_user_2"),
-			tgbotapi.NewInlineKeyboardButtonData("3 месяца", "create_user_3"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.topup"), RouteTopupBalance.Data()),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.region"), RouteRegions.Data(RouteProfile.Name())),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.language"), RouteLanguage.Data()),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
 		),
 	)
 }
 
-// NewProfileKeyboard создает клавиатуру личного кабинета
-func NewProfileKeyboard() tgbotapi.InlineKeyboardMarkup {
+// NewDevicesKeyboard создает клавиатуру устройств: отвязка каждого и
+// покупка дополнительного. price 0 - докупить больше нельзя
+func NewDevicesKeyboard(loc domain.Localizer, devices []domain.Device, price int) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(devices)+2)
+	for i, d := range devices {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.device_unlink", i+1), RouteDeviceUnlink.Data(d.Key())),
+		))
+	}
+	if price > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.device_buy", price), RouteDeviceBuy.Data()),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// NewTrafficKeyboard создает клавиатуру покупки пакетов трафика
+func NewTrafficKeyboard(loc domain.Localizer, packs []domain.TrafficPack) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(packs)+1)
+	for _, p := range packs {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.traffic_pack", p.GB, p.Price), RouteTrafficBuy.Data(fmt.Sprint(p.GB))),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// NewRevokeKeyboard создает клавиатуру подтверждения перевыпуска ссылки
+func NewRevokeKeyboard(loc domain.Localizer) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("💰 Пополнить баланс", "topup_balance"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.revoke_confirm"), RouteRevokeConfirm.Data()),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.cancel"), RouteMainMenu.Data()),
+		),
+	)
+}
+
+// NewRegionsKeyboard создает клавиатуру выбора региона. current - uuid
+// выбранного сквада, next - экран, на который вернуться после выбора
+func NewRegionsKeyboard(loc domain.Localizer, regions []domain.Region, current, next string) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(regions)+1)
+	for _, r := range regions {
+		text := r.Name
+		if r.ID == current {
+			text = loc.T("btn.region_selected", r.Name)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, RouteSetRegion.Data(r.ID, next)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// TopupAmount сумма пополнения в рублях и комиссия платежной системы сверху
+type TopupAmount struct {
+	Amount int
+	Fee    int
+}
+
+// NewTopupProvidersKeyboard создает клавиатуру выбора платежной системы
+func NewTopupProvidersKeyboard(loc domain.Localizer, providers []domain.PaymentProvider) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(providers)+1)
+	for _, p := range providers {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(p.Name, RouteTopupProvider.Data(p.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// NewTopupAmountsKeyboard создает клавиатуру выбора суммы для платежной системы providerID
+func NewTopupAmountsKeyboard(loc domain.Localizer, providerID string, amounts []TopupAmount) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(amounts)+1)
+	for _, a := range amounts {
+		text := loc.T("btn.topup_amount", a.Amount)
+		if a.Fee > 0 {
+			text = loc.T("btn.topup_amount_fee", a.Amount, a.Fee)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, RouteTopupPay.Data(providerID, fmt.Sprint(a.Amount))),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.topup_back"), RouteTopupBalance.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// NewInvoiceKeyboard создает клавиатуру счета: ссылка на оплату и проверка оплаты
+func NewInvoiceKeyboard(loc domain.Localizer, paymentURL, transactionID string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(loc.T("btn.pay"), paymentURL),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.check_payment"), RouteTopupCheck.Data(transactionID)),
 		),
 	)
 }
 
 // NewInfoKeyboard создает клавиатуру раздела информации
-func NewInfoKeyboard() tgbotapi.InlineKeyboardMarkup {
+func NewInfoKeyboard(loc domain.Localizer) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📜 Пользовательское соглашение", "agreement"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.agreement"), RouteAgreement.Data()),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
 		),
 	)
 }
 
 // NewBackToMenuKeyboard создает клавиатуру с кнопкой возврата в меню
-func NewBackToMenuKeyboard() tgbotapi.InlineKeyboardMarkup {
+func NewBackToMenuKeyboard(loc domain.Localizer) tgbotapi.InlineKeyboardMarkup {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
 		),
 	)
 	return keyboard
 }
+
+// NewLanguageKeyboard создает клавиатуру выбора языка.
+// Каждый язык подписан на самом себе, чтобы его нашел любой пользователь
+func NewLanguageKeyboard(loc domain.Localizer, tr domain.Translator) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(tr.Languages())+1)
+	for _, lang := range tr.Languages() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr.For(lang).T("language.name"), RouteSetLanguage.Data(lang)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(loc.T("btn.main_menu"), RouteMainMenu.Data()),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
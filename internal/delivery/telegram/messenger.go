@@ -0,0 +1,173 @@
+// Package telegram транспорт бота. Messenger - узкий интерфейс отправки
+// сообщений, чтобы обработчики не зависели напрямую от *tgbotapi.BotAPI.
+package telegram
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Messenger то через что обработчики общаются с пользователем.
+// Обработчики зависят только от этого интерфейса, поэтому их можно
+// проверить без сети, подставив фейк из пакета telegramtest.
+type Messenger interface {
+	// SendMessage отправляет новое сообщение в чат. keyboard может быть nil
+	SendMessage(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+
+	// EditMessage меняет текст и клавиатуру уже отправленного сообщения
+	EditMessage(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+
+	// AnswerCallback отвечает телеграму на нажатие кнопки (чтобы пропали часики).
+	// text показывается пользователю всплывающим уведомлением, можно пустой
+	AnswerCallback(callbackID, text string) error
+
+	// SendPhoto отправляет картинку (например QR код) с подписью
+	SendPhoto(chatID int64, photo tgbotapi.FileBytes, caption string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+
+	// SendFormatted как SendMessage, но с разметкой parseMode ("HTML", "Markdown"
+	// или пусто для обычного текста). Нужен для текстов из шаблонов
+	SendFormatted(chatID int64, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+
+	// EditFormatted как EditMessage, но с разметкой parseMode
+	EditFormatted(chatID int64, messageID int, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error
+
+	// SendInvoice отправляет счет на оплату прямо в чат
+	SendInvoice(chatID int64, invoice Invoice) error
+
+	// AnswerPreCheckout подтверждает (ok=true) или отклоняет оплату перед списанием.
+	// Телеграм ждет ответ не дольше 10 секунд. errorMessage видит пользователь при отказе
+	AnswerPreCheckout(queryID string, ok bool, errorMessage string) error
+
+	// RefundStarPayment возвращает пользователю оплату в Telegram Stars
+	RefundStarPayment(userID int64, chargeID string) error
+}
+
+// Invoice счет на оплату из одной позиции
+type Invoice struct {
+	Title       string
+	Description string
+	// Payload наш id транзакции, телеграм вернет его при оплате
+	Payload string
+	// Currency код валюты, для Telegram Stars - XTR
+	Currency string
+	// Amount сумма в минимальных единицах валюты (для XTR - в звездах)
+	Amount int
+}
+
+// botMessenger реализация Messenger поверх настоящего Bot API.
+type botMessenger struct {
+	bot *tgbotapi.BotAPI
+}
+
+// NewBotMessenger конструктор адаптера над tgbotapi.
+func NewBotMessenger(bot *tgbotapi.BotAPI) Messenger {
+	return &botMessenger{bot: bot}
+}
+
+// SendMessage отправляет новое сообщение.
+func (m *botMessenger) SendMessage(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.SendFormatted(chatID, text, "", keyboard)
+}
+
+// SendFormatted отправляет новое сообщение с разметкой.
+func (m *botMessenger) SendFormatted(chatID int64, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = parseMode
+	// ReplyMarkup это interface{}, поэтому nil указатель туда не кладем,
+	// иначе в телеграм уйдет "null"
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+
+	if _, err := m.bot.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %w", err)
+	}
+
+	return nil
+}
+
+// EditMessage редактирует сообщение.
+func (m *botMessenger) EditMessage(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.EditFormatted(chatID, messageID, text, "", keyboard)
+}
+
+// EditFormatted редактирует сообщение с разметкой.
+func (m *botMessenger) EditFormatted(chatID int64, messageID int, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	msg.ParseMode = parseMode
+	msg.ReplyMarkup = keyboard
+
+	if _, err := m.bot.Send(msg); err != nil {
+		return fmt.Errorf("ошибка редактирования сообщения: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback отвечает на callback.
+func (m *botMessenger) AnswerCallback(callbackID, text string) error {
+	if _, err := m.bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		return fmt.Errorf("ошибка ответа на callback: %w", err)
+	}
+
+	return nil
+}
+
+// SendPhoto отправляет картинку.
+func (m *botMessenger) SendPhoto(chatID int64, photo tgbotapi.FileBytes, caption string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewPhotoUpload(chatID, photo)
+	msg.Caption = caption
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+
+	if _, err := m.bot.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки фото: %w", err)
+	}
+
+	return nil
+}
+
+// SendInvoice отправляет счет.
+func (m *botMessenger) SendInvoice(chatID int64, invoice Invoice) error {
+	prices := []tgbotapi.LabeledPrice{{Label: invoice.Title, Amount: invoice.Amount}}
+	// provider_token пустой: для Telegram Stars он не нужен
+	msg := tgbotapi.NewInvoice(chatID, invoice.Title, invoice.Description, invoice.Payload, "", "", invoice.Currency, &prices)
+
+	if _, err := m.bot.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки счета: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerPreCheckout отвечает на pre_checkout_query.
+func (m *botMessenger) AnswerPreCheckout(queryID string, ok bool, errorMessage string) error {
+	_, err := m.bot.AnswerPreCheckoutQuery(tgbotapi.PreCheckoutConfig{
+		PreCheckoutQueryID: queryID,
+		OK:                 ok,
+		ErrorMessage:       errorMessage,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка ответа на pre_checkout_query: %w", err)
+	}
+
+	return nil
+}
+
+// RefundStarPayment возвращает оплату в звездах. В tgbotapi v4 такого метода
+// нет, поэтому вызываем Bot API напрямую
+func (m *botMessenger) RefundStarPayment(userID int64, chargeID string) error {
+	params := url.Values{}
+	params.Set("user_id", strconv.FormatInt(userID, 10))
+	params.Set("telegram_payment_charge_id", chargeID)
+
+	if _, err := m.bot.MakeRequest("refundStarPayment", params); err != nil {
+		return fmt.Errorf("ошибка возврата звезд: %w", err)
+	}
+
+	return nil
+}
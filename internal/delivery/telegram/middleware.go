@@ -0,0 +1,400 @@
+// Package telegram middleware для команд и кнопок. Общие вещи (логирование,
+// паники, лимиты, доступ) оборачивают обработчик, а не копируются в каждый.
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName имя трейсера обработки обновлений
+const tracerName = "ProxyMaster_v2/internal/delivery/telegram"
+
+// HandlerFunc общий вид обработчика команды или кнопки
+type HandlerFunc func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error
+
+// Middleware оборачивает обработчик. Вызывает next, если обновление можно пропускать дальше
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chain собирает цепочку: первый middleware в списке - самый внешний
+func chain(handler HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// updateUser кто прислал обновление. nil для обновлений без пользователя
+func updateUser(update tgbotapi.Update) *tgbotapi.User {
+	switch {
+	case update.Message != nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From
+	case update.PreCheckoutQuery != nil:
+		return update.PreCheckoutQuery.From
+	}
+
+	return nil
+}
+
+// isPayment обновление про оплату счета. Такие обновления нельзя терять:
+// деньги уже списаны или вот-вот спишутся
+func isPayment(update tgbotapi.Update) bool {
+	return update.PreCheckoutQuery != nil ||
+		(update.Message != nil && update.Message.SuccessfulPayment != nil)
+}
+
+// updateKind тип обновления для логов
+func updateKind(update tgbotapi.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		return "callback"
+	case update.PreCheckoutQuery != nil:
+		return "pre_checkout"
+	case update.Message != nil && update.Message.SuccessfulPayment != nil:
+		return "payment"
+	case update.Message != nil && update.Message.IsCommand():
+		return "command"
+	case update.Message != nil:
+		return "message"
+	}
+
+	return "other"
+}
+
+// updateRoute имя команды или маршрута кнопки (без параметров)
+func updateRoute(update tgbotapi.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		name, _, _ := strings.Cut(update.CallbackQuery.Data, routeSeparator)
+		return name
+	case update.Message != nil:
+		return update.Message.Command()
+	}
+
+	return ""
+}
+
+// updateLocalizer переводчик на язык из телеграма. Для коротких уведомлений
+// middleware этого хватает, ходить в DB за выбранным языком не нужно
+func updateLocalizer(tr domain.Translator, update tgbotapi.Update) domain.Localizer {
+	code := ""
+	if user := updateUser(update); user != nil {
+		code = user.LanguageCode
+	}
+
+	return tr.For(tr.Resolve(code))
+}
+
+// deny сообщает пользователю, почему обновление не обработано.
+// Для кнопки всплывающее уведомление, для команды - сообщение
+func deny(update tgbotapi.Update, messenger Messenger, text string) error {
+	if update.CallbackQuery != nil {
+		return messenger.AnswerCallback(update.CallbackQuery.ID, text)
+	}
+	if update.Message != nil {
+		return messenger.SendMessage(update.Message.Chat.ID, text, nil)
+	}
+
+	return nil
+}
+
+// Recover ловит панику в обработчике, чтобы один сломанный экран не ронял весь бот
+func Recover(l logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.FromContext(ctx, l).Error("паника в обработчике",
+						logger.Field{Key: "panic", Value: fmt.Sprint(r)},
+						logger.Field{Key: "route", Value: updateRoute(update)},
+						logger.Field{Key: "stack", Value: string(debug.Stack())},
+					)
+					err = fmt.Errorf("паника в обработчике: %v", r)
+				}
+			}()
+
+			return next(ctx, update, messenger)
+		}
+	}
+}
+
+// Logging пишет структурированный лог по каждому обновлению: кто, что и сколько заняло
+func Logging(l logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			start := time.Now()
+			err := next(ctx, update, messenger)
+
+			// update_id и user_id уже в ctx, см. Client.dispatch
+			logger.FromContext(ctx, l).Info("обновление обработано",
+				logger.Field{Key: "kind", Value: updateKind(update)},
+				logger.Field{Key: "route", Value: updateRoute(update)},
+				logger.Field{Key: "latency", Value: time.Since(start)},
+				logger.Field{Key: "ok", Value: err == nil},
+			)
+
+			return err
+		}
+	}
+}
+
+// Tracing открывает корневой спан на каждое обновление. Сервисы и клиенты
+// внутри обработчика получают его через ctx и вешают на него свои спаны.
+// Должен быть самым внешним, чтобы в спан попадали паники и время всех middleware
+func Tracing() Middleware {
+	tracer := otel.Tracer(tracerName)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			kind, route := updateKind(update), updateRoute(update)
+
+			name := "telegram " + kind
+			if route != "" {
+				name += " " + route
+			}
+
+			attrs := []attribute.KeyValue{
+				attribute.Int("telegram.update_id", update.UpdateID),
+				attribute.String("telegram.kind", kind),
+				attribute.String("telegram.route", route),
+			}
+			if user := updateUser(update); user != nil {
+				attrs = append(attrs, attribute.Int("telegram.user_id", user.ID))
+			}
+
+			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			err := next(ctx, update, messenger)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err
+		}
+	}
+}
+
+// Metrics считает обновления по типу и маршруту и замеряет время обработки
+func Metrics(m domain.Metrics) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			start := time.Now()
+			err := next(ctx, update, messenger)
+			m.ObserveUpdate(updateKind(update), updateRoute(update), err == nil, time.Since(start))
+
+			return err
+		}
+	}
+}
+
+// RateLimit ограничивает кол-во обновлений от одного пользователя:
+// в среднем perSecond в секунду, но не больше burst подряд (token bucket).
+// Оплату не ограничивает: звезды за отправленный счет уже списаны
+func RateLimit(tr domain.Translator, perSecond float64, burst int) Middleware {
+	limiter := newRateLimiter(perSecond, burst)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			if isPayment(update) {
+				return next(ctx, update, messenger)
+			}
+
+			user := updateUser(update)
+			if user != nil && !limiter.allow(user.ID, time.Now()) {
+				return deny(update, messenger, updateLocalizer(tr, update).T("error.rate_limited"))
+			}
+
+			return next(ctx, update, messenger)
+		}
+	}
+}
+
+// AdminOnly пускает к перечисленным командам и маршрутам только админов.
+// Список админов берется из настроек на каждое обновление, поэтому его можно
+// менять без рестарта. Остальные обновления проходят без проверки
+func AdminOnly(tr domain.Translator, settings domain.Settings, protected ...string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			if !slices.Contains(protected, updateRoute(update)) {
+				return next(ctx, update, messenger)
+			}
+
+			user := updateUser(update)
+			if user == nil || !settings.IsAdmin(int64(user.ID)) {
+				return deny(update, messenger, updateLocalizer(tr, update).T("error.forbidden"))
+			}
+
+			return next(ctx, update, messenger)
+		}
+	}
+}
+
+// Maintenance во время техработ отвечает на перечисленные маршруты
+// уведомлением вместо обработки: покупки и пополнения ждут окончания работ,
+// а меню, профиль и поддержка работают. Админов пропускает, чтобы они
+// могли проверить покупку до выключения режима. Оплату уже выставленного
+// счета тоже пропускает, иначе звезды спишутся без зачисления
+func Maintenance(tr domain.Translator, settings domain.Settings, protected ...string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			if isPayment(update) || !settings.Maintenance() || !slices.Contains(protected, updateRoute(update)) {
+				return next(ctx, update, messenger)
+			}
+
+			user := updateUser(update)
+			if user != nil && settings.IsAdmin(int64(user.ID)) {
+				return next(ctx, update, messenger)
+			}
+
+			text := settings.MaintenanceMessage()
+			if text == "" {
+				text = updateLocalizer(tr, update).T("maintenance.notice")
+			}
+
+			return deny(update, messenger, text)
+		}
+	}
+}
+
+// RegisterUser создает пользователя в DB при первом обращении к боту
+func RegisterUser(users domain.UserRepository, l logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			user := updateUser(update)
+			if user == nil {
+				return next(ctx, update, messenger)
+			}
+
+			id := strconv.Itoa(user.ID)
+			_, err := users.GetUserByID(ctx, id)
+			switch {
+			case errors.Is(err, domain.ErrUserNotFound):
+				if _, createErr := users.CreateUser(ctx, models.CreateUserTGDTO{ID: id}); createErr != nil {
+					// Ошибка регистрации не повод не отвечать пользователю
+					l.Error("не удалось зарегистрировать пользователя",
+						logger.Field{Key: "user_id", Value: id},
+						logger.Field{Key: "error", Value: createErr},
+					)
+				} else {
+					l.Info("зарегистрирован новый пользователь", logger.Field{Key: "user_id", Value: id})
+				}
+			case err != nil:
+				l.Error("ошибка поиска пользователя", logger.Field{Key: "user_id", Value: id}, logger.Field{Key: "error", Value: err})
+			}
+
+			return next(ctx, update, messenger)
+		}
+	}
+}
+
+// BanCheck молча отбрасывает обновления от забаненных пользователей.
+// Оплату пропускает: деньги за уже отправленный счет все равно надо зачислить
+func BanCheck(users domain.UserRepository, l logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+			user := updateUser(update)
+			if user == nil || isPayment(update) {
+				return next(ctx, update, messenger)
+			}
+
+			dbUser, err := users.GetUserByID(ctx, strconv.Itoa(user.ID))
+			if err == nil && dbUser.Banned {
+				l.Info("обновление от забаненного пользователя пропущено", logger.Field{Key: "user_id", Value: user.ID})
+
+				// Для кнопки все равно отвечаем, чтобы не крутились часики
+				if update.CallbackQuery != nil {
+					return messenger.AnswerCallback(update.CallbackQuery.ID, "")
+				}
+
+				return nil
+			}
+
+			return next(ctx, update, messenger)
+		}
+	}
+}
+
+// rateLimiter token bucket на каждого пользователя
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[int]*bucket
+	rate    float64
+	burst   float64
+	calls   int
+}
+
+// bucket сколько токенов осталось у пользователя и когда пополняли
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter конструктор.
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[int]*bucket),
+		rate:    perSecond,
+		burst:   float64(burst),
+	}
+}
+
+// allow тратит один токен пользователя, false если токенов нет
+func (r *rateLimiter) allow(userID int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cleanup(now)
+
+	b, ok := r.buckets[userID]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[userID] = b
+	}
+
+	// Пополняем токены за прошедшее время, но не больше burst
+	b.tokens = min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// cleanup иногда удаляет давно молчащих пользователей, чтобы map не рос бесконечно
+func (r *rateLimiter) cleanup(now time.Time) {
+	r.calls++
+	if r.calls%1000 != 0 {
+		return
+	}
+
+	for id, b := range r.buckets {
+		if now.Sub(b.last) > 10*time.Minute {
+			delete(r.buckets, id)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package telegram роутер callback кнопок. Как RegisterCommand для команд,
+// только для inline кнопок: каждый экран регистрирует свой маршрут,
+// а клавиатуры собирают callback data из тех же маршрутов.
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// MaxCallbackDataLen телеграм не принимает callback data длиннее 64 байт
+const MaxCallbackDataLen = 64
+
+// routeSeparator разделяет имя маршрута и параметры: "tariff:3"
+const routeSeparator = ":"
+
+// Route описание callback маршрута: имя экрана и имена его параметров.
+// Например NewRoute("tariff", "months") дает данные вида "tariff:3"
+type Route struct {
+	name   string
+	params []string
+}
+
+// NewRoute создает маршрут. Имя и параметры не должны содержать ":"
+func NewRoute(name string, params ...string) Route {
+	return Route{name: name, params: params}
+}
+
+// Name имя маршрута
+func (r Route) Name() string {
+	return r.name
+}
+
+// Data собирает callback data для кнопки из значений параметров по порядку.
+// Неверное кол-во параметров или слишком длинные данные - ошибка программиста,
+// поэтому тут паника, а не error (как regexp.MustCompile)
+func (r Route) Data(values ...string) string {
+	if len(values) != len(r.params) {
+		panic(fmt.Sprintf("маршрут %s: ожидалось %d параметров, передано %d", r.name, len(r.params), len(values)))
+	}
+
+	parts := make([]string, 0, len(values)+1)
+	parts = append(parts, r.name)
+	for i, v := range values {
+		if v == "" || strings.Contains(v, routeSeparator) {
+			panic(fmt.Sprintf("маршрут %s: недопустимое значение параметра %s: %q", r.name, r.params[i], v))
+		}
+		parts = append(parts, v)
+	}
+
+	data := strings.Join(parts, routeSeparator)
+	if len(data) > MaxCallbackDataLen {
+		panic(fmt.Sprintf("маршрут %s: callback data длиннее %d байт: %q", r.name, MaxCallbackDataLen, data))
+	}
+
+	return data
+}
+
+// CallbackParams разобранные параметры маршрута по именам.
+type CallbackParams map[string]string
+
+// Int возвращает параметр как число
+func (p CallbackParams) Int(name string) (int, error) {
+	v, ok := p[name]
+	if !ok {
+		return 0, fmt.Errorf("параметр %s не найден", name)
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("параметр %s не число: %s", name, v)
+	}
+
+	return n, nil
+}
+
+// CallbackHandlerFunc обработчик одного маршрута
+type CallbackHandlerFunc func(ctx context.Context, update tgbotapi.Update, messenger Messenger, params CallbackParams) error
+
+// registeredRoute маршрут вместе с обработчиком
+type registeredRoute struct {
+	route   Route
+	handler CallbackHandlerFunc
+}
+
+// CallbackRouter находит обработчик по callback data
+type CallbackRouter struct {
+	routes map[string]registeredRoute
+	// tr нужен для ответа на неизвестную кнопку
+	tr     domain.Translator
+	logger logger.Logger
+}
+
+// NewCallbackRouter конструктор.
+func NewCallbackRouter(tr domain.Translator, l logger.Logger) *CallbackRouter {
+	return &CallbackRouter{
+		routes: make(map[string]registeredRoute),
+		tr:     tr,
+		logger: l,
+	}
+}
+
+// Register регистрирует обработчик маршрута. Повторная регистрация заменяет старый
+func (r *CallbackRouter) Register(route Route, handler CallbackHandlerFunc) {
+	r.routes[route.name] = registeredRoute{route: route, handler: handler}
+}
+
+// Handle обработка входящего callback. Подходит для Client.SetCallbackHandler
+func (r *CallbackRouter) Handle(ctx context.Context, update tgbotapi.Update, messenger Messenger) error {
+	query := update.CallbackQuery
+	rr, params, ok := r.match(query.Data)
+
+	// Неизвестную кнопку (например из старого сообщения) логируем
+	// и все равно отвечаем, чтобы у пользователя пропали часики
+	if !ok {
+		r.logger.Warn("неизвестный callback",
+			logger.Field{Key: "user_id", Value: query.From.ID},
+			logger.Field{Key: "data", Value: query.Data},
+		)
+
+		if err := messenger.AnswerCallback(query.ID, updateLocalizer(r.tr, update).T("error.unknown_button")); err != nil {
+			return fmt.Errorf("ошибка ответа на неизвестный callback: %w", err)
+		}
+
+		return nil
+	}
+
+	// Отвечаем телеграму, что мы получили callback (чтобы часики пропали)
+	if err := messenger.AnswerCallback(query.ID, ""); err != nil {
+		return fmt.Errorf("ошибка ответа на callback: %w", err)
+	}
+
+	if err := rr.handler(ctx, update, messenger, params); err != nil {
+		return fmt.Errorf("маршрут %s: %w", rr.route.name, err)
+	}
+
+	return nil
+}
+
+// match разбирает callback data на маршрут и параметры
+func (r *CallbackRouter) match(data string) (registeredRoute, CallbackParams, bool) {
+	parts := strings.Split(data, routeSeparator)
+
+	rr, ok := r.routes[parts[0]]
+	if !ok {
+		return registeredRoute{}, nil, false
+	}
+
+	values := parts[1:]
+	if len(values) != len(rr.route.params) {
+		return registeredRoute{}, nil, false
+	}
+
+	params := make(CallbackParams, len(values))
+	for i, name := range rr.route.params {
+		params[name] = values[i]
+	}
+
+	return rr, params, true
+}
@@ -0,0 +1,53 @@
+// Package telegram маршруты callback кнопок. Одни и те же определения
+// используют клавиатуры (собирают data) и обработчики (регистрируются в роутере).
+package telegram
+
+// Маршруты экранов бота
+var (
+	// RouteMainMenu главное меню
+	RouteMainMenu = NewRoute("main_menu")
+	// RouteTariffs выбор срока подписки
+	RouteTariffs = NewRoute("tariffs")
+	// RouteTariff покупка подписки на months месяцев
+	RouteTariff = NewRoute("tariff", "months")
+	// RouteTrial пробный период
+	RouteTrial = NewRoute("trial")
+	// RouteProfile личный кабинет
+	RouteProfile = NewRoute("profile")
+	// RouteSupport поддержка
+	RouteSupport = NewRoute("support")
+	// RouteInfo информация о сервисе
+	RouteInfo = NewRoute("info")
+	// RouteAgreement пользовательское соглашение
+	RouteAgreement = NewRoute("agreement")
+	// RouteTopupBalance пополнение баланса
+	RouteTopupBalance = NewRoute("topup_balance")
+	// RouteTopupProvider выбор суммы пополнения через платежную систему provider
+	RouteTopupProvider = NewRoute("topup_provider", "provider")
+	// RouteTopupPay пополнение баланса на amount рублей через платежную систему provider
+	RouteTopupPay = NewRoute("topup_pay", "provider", "amount")
+	// RouteTopupCheck проверка оплаты транзакции tx (если уведомление не пришло)
+	RouteTopupCheck = NewRoute("topup_check", "tx")
+	// RouteLanguage выбор языка
+	RouteLanguage = NewRoute("language")
+	// RouteSetLanguage сохранение выбранного языка lang
+	RouteSetLanguage = NewRoute("set_lang", "lang")
+	// RouteRegions выбор региона, next - имя маршрута экрана после выбора (тарифы или профиль)
+	RouteRegions = NewRoute("regions", "next")
+	// RouteSetRegion сохранение региона region (uuid сквада) и переход на экран next
+	RouteSetRegion = NewRoute("set_region", "region", "next")
+	// RouteDevices мои устройства
+	RouteDevices = NewRoute("devices")
+	// RouteDeviceUnlink отвязка устройства device (domain.Device.Key)
+	RouteDeviceUnlink = NewRoute("device_unlink", "device")
+	// RouteDeviceBuy покупка устройства сверх подписки
+	RouteDeviceBuy = NewRoute("device_buy")
+	// RouteTraffic трафик подписки и пакеты
+	RouteTraffic = NewRoute("traffic")
+	// RouteTrafficBuy покупка пакета трафика на gb гигабайт
+	RouteTrafficBuy = NewRoute("traffic_buy", "gb")
+	// RouteRevoke подтверждение перевыпуска ссылки подписки
+	RouteRevoke = NewRoute("revoke")
+	// RouteRevokeConfirm перевыпуск ссылки подписки после подтверждения
+	RouteRevokeConfirm = NewRoute("revoke_confirm")
+)
@@ -0,0 +1,169 @@
+// Package telegramtest фейки телеграм транспорта для тестов.
+// Messenger запоминает все вызовы, вместо того чтобы ходить в Bot API.
+package telegramtest
+
+import (
+	"sync"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Названия методов, которые записываются в Call.Method
+const (
+	MethodSendMessage       = "SendMessage"
+	MethodEditMessage       = "EditMessage"
+	MethodAnswerCallback    = "AnswerCallback"
+	MethodSendPhoto         = "SendPhoto"
+	MethodSendFormatted     = "SendFormatted"
+	MethodEditFormatted     = "EditFormatted"
+	MethodSendInvoice       = "SendInvoice"
+	MethodAnswerPreCheckout = "AnswerPreCheckout"
+	MethodRefundStarPayment = "RefundStarPayment"
+)
+
+// Call один записанный вызов Messenger.
+type Call struct {
+	Method     string
+	ChatID     int64
+	MessageID  int
+	CallbackID string
+	// Text текст сообщения, подпись к фото или текст ответа на callback
+	Text string
+	// ParseMode режим разметки для SendFormatted и EditFormatted
+	ParseMode string
+	Keyboard  *tgbotapi.InlineKeyboardMarkup
+	Photo     tgbotapi.FileBytes
+	// Invoice счет из SendInvoice
+	Invoice telegram.Invoice
+	// QueryID и OK из AnswerPreCheckout (текст отказа - в Text)
+	QueryID string
+	OK      bool
+	// UserID и ChargeID из RefundStarPayment
+	UserID   int64
+	ChargeID string
+}
+
+// Messenger записывающий фейк telegram.Messenger.
+// Безопасен для вызова из нескольких горутин.
+type Messenger struct {
+	mu    sync.Mutex
+	calls []Call
+
+	// Err если задан, возвращается из каждого метода (вызов все равно записывается)
+	Err error
+}
+
+// Проверяем на этапе компиляции, что фейк реализует интерфейс
+var _ telegram.Messenger = (*Messenger)(nil)
+
+// NewMessenger конструктор фейка.
+func NewMessenger() *Messenger {
+	return &Messenger{}
+}
+
+// record сохраняет вызов и возвращает заданную ошибку.
+func (m *Messenger) record(c Call) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, c)
+
+	return m.Err
+}
+
+// SendMessage записывает отправку сообщения.
+func (m *Messenger) SendMessage(chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.record(Call{Method: MethodSendMessage, ChatID: chatID, Text: text, Keyboard: keyboard})
+}
+
+// EditMessage записывает редактирование сообщения.
+func (m *Messenger) EditMessage(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.record(Call{Method: MethodEditMessage, ChatID: chatID, MessageID: messageID, Text: text, Keyboard: keyboard})
+}
+
+// AnswerCallback записывает ответ на callback.
+func (m *Messenger) AnswerCallback(callbackID, text string) error {
+	return m.record(Call{Method: MethodAnswerCallback, CallbackID: callbackID, Text: text})
+}
+
+// SendPhoto записывает отправку фото.
+func (m *Messenger) SendPhoto(chatID int64, photo tgbotapi.FileBytes, caption string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.record(Call{Method: MethodSendPhoto, ChatID: chatID, Text: caption, Keyboard: keyboard, Photo: photo})
+}
+
+// SendFormatted записывает отправку сообщения с разметкой.
+func (m *Messenger) SendFormatted(chatID int64, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.record(Call{Method: MethodSendFormatted, ChatID: chatID, Text: text, ParseMode: parseMode, Keyboard: keyboard})
+}
+
+// EditFormatted записывает редактирование сообщения с разметкой.
+func (m *Messenger) EditFormatted(chatID int64, messageID int, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	return m.record(Call{
+		Method:    MethodEditFormatted,
+		ChatID:    chatID,
+		MessageID: messageID,
+		Text:      text,
+		ParseMode: parseMode,
+		Keyboard:  keyboard,
+	})
+}
+
+// SendInvoice записывает отправку счета.
+func (m *Messenger) SendInvoice(chatID int64, invoice telegram.Invoice) error {
+	return m.record(Call{Method: MethodSendInvoice, ChatID: chatID, Invoice: invoice})
+}
+
+// AnswerPreCheckout записывает ответ на pre_checkout_query.
+func (m *Messenger) AnswerPreCheckout(queryID string, ok bool, errorMessage string) error {
+	return m.record(Call{Method: MethodAnswerPreCheckout, QueryID: queryID, OK: ok, Text: errorMessage})
+}
+
+// RefundStarPayment записывает возврат звезд.
+func (m *Messenger) RefundStarPayment(userID int64, chargeID string) error {
+	return m.record(Call{Method: MethodRefundStarPayment, UserID: userID, ChargeID: chargeID})
+}
+
+// Calls возвращает копию всех записанных вызовов по порядку.
+func (m *Messenger) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Call, len(m.calls))
+	copy(out, m.calls)
+
+	return out
+}
+
+// CallsByMethod возвращает только вызовы указанного метода.
+func (m *Messenger) CallsByMethod(method string) []Call {
+	var out []Call
+	for _, c := range m.Calls() {
+		if c.Method == method {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// Last возвращает последний вызов. ok=false если вызовов не было.
+func (m *Messenger) Last() (Call, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.calls) == 0 {
+		return Call{}, false
+	}
+
+	return m.calls[len(m.calls)-1], true
+}
+
+// Reset очищает записанные вызовы.
+func (m *Messenger) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = nil
+}
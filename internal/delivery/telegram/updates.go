@@ -0,0 +1,127 @@
+// Package telegram источники обновлений бота. UpdateSource отдает канал
+// обновлений, а Client дальше разбирает их одинаково, откуда бы они ни пришли.
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Режимы получения обновлений
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// UpdateSource откуда приходят обновления от телеграма.
+// Сейчас есть long polling (для разработки) и webhook (для прода).
+type UpdateSource interface {
+	// Start начинает получать обновления начиная с offset (update_id).
+	// Для webhook offset не нужен, телеграм сам помнит что доставил.
+	// Канал закрывается после Stop
+	Start(offset int) (tgbotapi.UpdatesChannel, error)
+
+	// Stop перестает получать обновления и освобождает ресурсы
+	Stop(ctx context.Context) error
+}
+
+// pollingSource получает обновления через Long Polling.
+// ---
+// Есть два подхода
+// 1. обычный подход Short Polling: бегаешь к почтовому ящику каждые 5 секунд,
+// открываешь его и проверяешь. Пусто. Бежишь назад. Через 5 секунд снова
+// Это лишние обращения к процессору и серверам телеги
+// ---
+// 2. подход Long Polling: подходишь к почтовому ящику, открываешь
+// его. Стоишь и ждешь так 60 секунд если письмо есть,
+// берем. Если не нет, то закрываем ящик, а
+// потом опять открываем и ждем 60 секунд
+type pollingSource struct {
+	bot    *tgbotapi.BotAPI
+	logger logger.Logger
+
+	// сколько секунд ждем в одном запросе getUpdates
+	timeout int
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewPollingSource конструктор long polling источника.
+func NewPollingSource(bot *tgbotapi.BotAPI, l logger.Logger) UpdateSource {
+	return &pollingSource{
+		bot:     bot,
+		logger:  l,
+		timeout: 60,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start запускает цикл getUpdates в отдельной горутине.
+func (p *pollingSource) Start(offset int) (tgbotapi.UpdatesChannel, error) {
+	// На случай если раньше стоял webhook: пока он установлен,
+	// getUpdates ничего не вернет
+	if _, err := p.bot.RemoveWebhook(); err != nil {
+		p.logger.Warn("не удалось снять webhook перед polling", logger.Field{Key: "error", Value: err})
+	}
+
+	ch := make(chan tgbotapi.Update, p.bot.Buffer)
+
+	go func() {
+		defer close(ch)
+
+		// Начинаем с сохраненного offset. 0 - дает все с самого начала и то что еще не обработал
+		cfg := tgbotapi.NewUpdate(offset)
+		cfg.Timeout = p.timeout
+
+		for {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+
+			updates, err := p.bot.GetUpdates(cfg)
+			if err != nil {
+				p.logger.Error("ошибка получения обновлений, повтор через 3 секунды", logger.Field{Key: "error", Value: err})
+
+				select {
+				case <-p.done:
+					return
+				case <-time.After(3 * time.Second):
+				}
+
+				continue
+			}
+
+			for _, update := range updates {
+				if update.UpdateID < cfg.Offset {
+					continue
+				}
+				// Следующий запрос начнется со следующего обновления
+				cfg.Offset = update.UpdateID + 1
+
+				select {
+				case ch <- update:
+				case <-p.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Stop останавливает цикл. Текущий запрос getUpdates может дожидаться таймаута,
+// поэтому канал закрывается не мгновенно.
+func (p *pollingSource) Stop(_ context.Context) error {
+	p.stopOnce.Do(func() { close(p.done) })
+
+	return nil
+}
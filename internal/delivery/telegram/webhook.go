@@ -0,0 +1,176 @@
+// Package telegram webhook режим. Телеграм сам присылает обновления
+// на наш HTTP сервер (обычно за reverse proxy), а мы кладем их в тот же канал,
+// что и при long polling.
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// secretTokenHeader заголовок, в котором телеграм присылает secret_token из setWebhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxUpdateSize ограничение тела запроса. Обновления телеграма намного меньше
+const maxUpdateSize = 1 << 20
+
+// WebhookConfig настройки webhook режима.
+type WebhookConfig struct {
+	// URL публичный https адрес, на который телеграм шлет обновления.
+	// Путь из него же используется для HTTP обработчика
+	URL string
+	// Listen адрес, на котором поднимается HTTP сервер (например ":8443")
+	Listen string
+	// Secret то что телеграм будет присылать в X-Telegram-Bot-Api-Secret-Token
+	Secret string
+}
+
+// webhookSource получает обновления через webhook.
+type webhookSource struct {
+	bot    *tgbotapi.BotAPI
+	cfg    WebhookConfig
+	logger logger.Logger
+
+	server  *http.Server
+	updates chan tgbotapi.Update
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewWebhookSource конструктор webhook источника.
+func NewWebhookSource(bot *tgbotapi.BotAPI, cfg WebhookConfig, l logger.Logger) UpdateSource {
+	return &webhookSource{
+		bot:    bot,
+		cfg:    cfg,
+		logger: l,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start регистрирует webhook в телеграме и поднимает HTTP сервер.
+func (w *webhookSource) Start(_ int) (tgbotapi.UpdatesChannel, error) {
+	hookURL, err := url.Parse(w.cfg.URL)
+	if err != nil || hookURL.Scheme != "https" || hookURL.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес webhook %q: нужен https URL", w.cfg.URL)
+	}
+	if w.cfg.Secret == "" {
+		return nil, errors.New("не задан секрет webhook")
+	}
+	if w.cfg.Listen == "" {
+		return nil, errors.New("не задан адрес HTTP сервера webhook")
+	}
+
+	path := hookURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	w.updates = make(chan tgbotapi.Update, w.bot.Buffer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, w.handle)
+
+	w.server = &http.Server{
+		Addr:              w.cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := w.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			w.logger.Error("webhook сервер остановился с ошибкой", logger.Field{Key: "error", Value: err})
+		}
+	}()
+
+	// Регистрируем webhook только после запуска сервера, чтобы первые обновления не потерялись.
+	// tgbotapi v4 не умеет secret_token, поэтому собираем запрос сами
+	params := url.Values{}
+	params.Add("url", hookURL.String())
+	params.Add("secret_token", w.cfg.Secret)
+	if _, err := w.bot.MakeRequest("setWebhook", params); err != nil {
+		_ = w.server.Close()
+
+		return nil, fmt.Errorf("ошибка регистрации webhook: %w", err)
+	}
+
+	w.logger.Info("webhook зарегистрирован",
+		logger.Field{Key: "path", Value: path},
+		logger.Field{Key: "listen", Value: w.cfg.Listen},
+	)
+
+	return w.updates, nil
+}
+
+// handle принимает одно обновление от телеграма.
+func (w *webhookSource) handle(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	// Сравниваем за постоянное время, чтобы секрет нельзя было подобрать по таймингу
+	got := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(w.cfg.Secret)) != 1 {
+		w.logger.Warn("запрос на webhook с неверным секретом", logger.Field{Key: "remote_addr", Value: r.RemoteAddr})
+		rw.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxUpdateSize)).Decode(&update); err != nil {
+		w.logger.Warn("не удалось разобрать обновление", logger.Field{Key: "error", Value: err})
+		rw.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	select {
+	case w.updates <- update:
+		rw.WriteHeader(http.StatusOK)
+	case <-w.done:
+		// Останавливаемся: просим телеграм прислать обновление позже
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Stop снимает webhook, гасит сервер и закрывает канал.
+func (w *webhookSource) Stop(ctx context.Context) error {
+	var stopErr error
+
+	w.stopOnce.Do(func() {
+		close(w.done)
+
+		if _, err := w.bot.MakeRequest("deleteWebhook", url.Values{}); err != nil {
+			stopErr = fmt.Errorf("ошибка удаления webhook: %w", err)
+		}
+
+		if w.server != nil {
+			// Shutdown дожидается завершения активных handle, после этого в канал
+			// никто не пишет и его можно закрыть
+			if err := w.server.Shutdown(ctx); err != nil {
+				stopErr = errors.Join(stopErr, fmt.Errorf("ошибка остановки webhook сервера: %w", err))
+
+				return
+			}
+			close(w.updates)
+		}
+	})
+
+	return stopErr
+}
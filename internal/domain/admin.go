@@ -0,0 +1,84 @@
+// Package domain описание контрактов для admin API: просмотр пользователей
+// и их подписок, ручные изменения баланса, управление подпиской и платежами
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ProxyMaster_v2/internal/models"
+)
+
+var (
+	// ErrInvalidAdjustment изменение баланса без причины, нулевое или уводит баланс в минус
+	ErrInvalidAdjustment = errors.New("invalid balance adjustment")
+	// ErrSubscriptionNotFound у пользователя нет подписки в remnawave
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// TransactionFilter условия поиска транзакций. Пустые поля не фильтруют
+type TransactionFilter struct {
+	UserID   string
+	Status   string
+	Provider string
+	// From и To границы created_at, нулевое время - без границы
+	From time.Time
+	To   time.Time
+	// Limit сколько вернуть, Offset сколько пропустить (новые первыми)
+	Limit  int
+	Offset int
+}
+
+// TransactionSearcher поиск транзакций для операторов
+type TransactionSearcher interface {
+	SearchTransactions(filter TransactionFilter) ([]models.Transaction, error)
+}
+
+// BalanceAdjustmentRepository журнал ручных изменений баланса (таблица balance_adjustments)
+type BalanceAdjustmentRepository interface {
+	// ApplyAdjustment одной DB транзакцией пишет запись в журнал и меняет баланс
+	// на ее delta. ErrInsufficientFunds если баланс стал бы отрицательным
+	ApplyAdjustment(models.CreateBalanceAdjustmentDTO) (*models.UserTG, error)
+	// GetAdjustmentsByUser изменения баланса пользователя, новые первыми
+	GetAdjustmentsByUser(userID string) ([]models.BalanceAdjustment, error)
+}
+
+// RemnawaveState подписка пользователя в панели
+type RemnawaveState struct {
+	UUID              string
+	Status            string
+	ExpireAt          time.Time
+	SubscriptionURL   string
+	TrafficLimitBytes int
+	UsedTrafficBytes  uint64
+	OnlineAt          time.Time
+}
+
+// AdminUser пользователь со всем, что нужно оператору
+type AdminUser struct {
+	User models.UserTG
+	// Remnawave nil, если подписки в панели нет или панель не ответила
+	Remnawave *RemnawaveState
+	// RemnawaveError почему не удалось получить подписку (пусто, если ее просто нет)
+	RemnawaveError string
+	Adjustments    []models.BalanceAdjustment
+}
+
+// AdminService действия операторов поверх тех же сервисов и репозиториев, что у бота
+type AdminService interface {
+	// ListUsers пользователи, новые первыми, и их общее количество
+	ListUsers(ctx context.Context, limit, offset int) ([]models.UserTG, int, error)
+	// GetUser пользователь с подпиской и журналом изменений баланса
+	GetUser(ctx context.Context, userID string) (*AdminUser, error)
+	// AdjustBalance меняет баланс на delta с обязательной причиной. actor - кто меняет
+	AdjustBalance(ctx context.Context, userID string, delta int, reason, actor string) (*models.UserTG, error)
+	// ExtendSubscription продлевает подписку на days дней, создает, если ее нет
+	ExtendSubscription(ctx context.Context, userID string, days int) (*RemnawaveState, error)
+	// SetSubscriptionEnabled включает или выключает подписку в панели
+	SetSubscriptionEnabled(ctx context.Context, userID string, enabled bool) (*RemnawaveState, error)
+	// SearchTransactions поиск транзакций
+	SearchTransactions(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error)
+	// Refund возвращает оплату транзакции
+	Refund(ctx context.Context, transactionID string) (*models.Transaction, error)
+}
@@ -0,0 +1,82 @@
+// Package domain описание контрактов для покупки подписки через сайт:
+// вход по одноразовому коду и заказ тарифа с оплатой через любую платежку
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ProxyMaster_v2/internal/models"
+)
+
+var (
+	// ErrOrderNotFound заказа нет или он чужой
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrUnknownTariff такого срока подписки нет в TariffMonths
+	ErrUnknownTariff = errors.New("unknown tariff")
+	// ErrInvalidLogin логин не похож ни на Telegram ID, ни на email
+	ErrInvalidLogin = errors.New("invalid login")
+	// ErrInvalidCode код не совпал, истек или его не запрашивали
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrCodeRequestedRecently новый код можно запросить чуть позже
+	ErrCodeRequestedRecently = errors.New("code requested recently")
+	// ErrInvalidSession сессия подделана или истекла
+	ErrInvalidSession = errors.New("invalid session")
+)
+
+type OrderStatus string
+
+const (
+	// OrderStatusPending ждем оплату транзакции
+	OrderStatusPending OrderStatus = "pending"
+	// OrderStatusPaid деньги на балансе, подписка еще не активирована
+	OrderStatusPaid OrderStatus = "paid"
+	// OrderStatusActivated подписка активирована, цена списана с баланса
+	OrderStatusActivated OrderStatus = "activated"
+	// OrderStatusFailed оплата не прошла
+	OrderStatusFailed OrderStatus = "failed"
+)
+
+// OrderRepository хранение заказов с сайта (таблица orders)
+type OrderRepository interface {
+	CreateOrder(models.CreateOrderDTO) (*models.Order, error)
+	GetOrderByID(id string) (*models.Order, error)
+	// SetOrderStatus переводит заказ из статуса from в to одной атомарной
+	// операцией. false если заказ уже не в статусе from
+	SetOrderStatus(id string, from, to OrderStatus) (bool, error)
+}
+
+// LoginCodeSender доставляет одноразовый код входа на сайт
+// (в телеграм или на почту)
+type LoginCodeSender interface {
+	SendLoginCode(ctx context.Context, to, code string) error
+}
+
+// WebAuthService вход на сайт по Telegram ID или email и одноразовому коду
+type WebAuthService interface {
+	// RequestCode отправляет код на login (Telegram ID или email)
+	RequestCode(ctx context.Context, login string) error
+	// VerifyCode проверяет код и возвращает id пользователя. Пользователя
+	// без аккаунта создает
+	VerifyCode(ctx context.Context, login, code string) (string, error)
+	// NewSession подписанный токен сессии для cookie
+	NewSession(userID string) (token string, expiresAt time.Time)
+	// SessionUser id пользователя из токена сессии
+	SessionUser(token string) (string, error)
+}
+
+// CheckoutService покупка подписки с сайта. Деньги идут через баланс:
+// пополнение на недостающую сумму, затем та же активация, что в боте
+type CheckoutService interface {
+	// Tariffs тарифы с ценами
+	Tariffs() []Tariff
+	// Providers платежки, которые умеют оплату по ссылке
+	Providers() []PaymentProvider
+	// CreateOrder создает заказ и счет на недостающую сумму. Возвращает
+	// ссылку на оплату, пустую если хватило баланса и подписка уже активирована
+	CreateOrder(ctx context.Context, userID string, months int, providerID string) (*models.Order, string, error)
+	// CheckOrder сверяет оплату заказа и, если она прошла, активирует подписку.
+	// Возвращает ссылку на подписку, когда заказ активирован
+	CheckOrder(ctx context.Context, userID, orderID string) (*models.Order, string, error)
+}
@@ -0,0 +1,39 @@
+// Package domain описание контрактов для редактируемых текстов бота
+// (приветствие, информация, соглашение)
+package domain
+
+// Страницы, тексты которых можно менять без релиза
+const (
+	PageWelcome   = "welcome"
+	PageInfo      = "info"
+	PageAgreement = "agreement"
+)
+
+// ContentData переменные, доступные в шаблонах страниц: {{.Balance}}, {{.Support}} и т.д.
+type ContentData struct {
+	UserID          int
+	FirstName       string
+	Balance         int
+	Support         string
+	SubscriptionURL string
+	// ExpireAt дата окончания подписки строкой, пусто если подписки нет
+	ExpireAt        string
+	HasSubscription bool
+	// TrialDays сколько дней пробного периода доступно, 0 - недоступен
+	TrialDays int
+}
+
+// RenderedContent готовый текст страницы и режим разметки телеграма
+type RenderedContent struct {
+	Text string
+	// ParseMode "HTML", "Markdown" или пусто для обычного текста
+	ParseMode string
+}
+
+// ContentStore хранилище шаблонов страниц
+type ContentStore interface {
+	// Render собирает страницу на языке lang (или на основном, если перевода нет)
+	Render(page, lang string, data ContentData) (RenderedContent, error)
+	// Reload перечитывает шаблоны. Если новые шаблоны с ошибкой - остаются старые
+	Reload() error
+}
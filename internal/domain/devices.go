@@ -0,0 +1,56 @@
+// Package domain устройства подписки: лимит из тарифа плюс докупленные
+// устройства и список подключавшихся устройств из панели.
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNoSubscription у пользователя нет подписки в панели
+	ErrNoSubscription = errors.New("no subscription")
+	// ErrDeviceNotFound такого устройства у пользователя нет
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceLimit докуплено максимальное число устройств
+	ErrDeviceLimit = errors.New("device limit reached")
+)
+
+// Device устройство, подключавшееся по ссылке подписки
+type Device struct {
+	HWID      string
+	Platform  string
+	OSVersion string
+	Model     string
+	CreatedAt time.Time
+}
+
+// Key короткий id устройства для callback data: HWID бывает длиннее,
+// чем телеграм разрешает в кнопке
+func (d Device) Key() string {
+	sum := sha256.Sum256([]byte(d.HWID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// DevicesInfo устройства пользователя и его лимит
+type DevicesInfo struct {
+	Devices []Device
+	// Limit сколько устройств можно подключить
+	Limit int
+	// Extra сколько устройств докуплено сверх подписки
+	Extra int
+}
+
+// DeviceService устройства подписки
+type DeviceService interface {
+	// Devices устройства и лимит. ErrNoSubscription если подписки нет
+	Devices(ctx context.Context, userID string) (DevicesInfo, error)
+	// UnlinkDevice отвязывает устройство по Device.Key
+	UnlinkDevice(ctx context.Context, userID, key string) error
+	// BuyExtraDevice списывает цену устройства с баланса и возвращает новый лимит.
+	// ErrInsufficientFunds или ErrDeviceLimit, если купить нельзя
+	BuyExtraDevice(ctx context.Context, userID string) (int, error)
+}
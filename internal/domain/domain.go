@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"errors"
+	"time"
 
 	"ProxyMaster_v2/internal/models"
 )
@@ -11,24 +12,83 @@ var (
 	// ErrInsufficientFunds ошибка недостаточного баланса
 	ErrInsufficientFunds = errors.New("insufficient funds")
 	ErrUserNotFound      = errors.New("user not found")
+	// ErrNotRefunded покупка (подписка, устройство, трафик) не применилась
+	// в панели, а списанную цену вернуть не удалось
+	ErrNotRefunded = errors.New("purchase failed and payment not refunded")
+	// ErrTrialUsed пробный период уже был или у пользователя уже была подписка
+	ErrTrialUsed = errors.New("trial already used")
+	// ErrTrialDisabled пробный период выключен настройкой trial_days
+	ErrTrialDisabled = errors.New("trial disabled")
 )
 
 // RemnawaveClient - то как мы хотим получать информацию
 type RemnawaveClient interface {
 	Login(ctx context.Context, username string, password string) error
-	GetUUIDByUsername(username string) (string, error)
-	CreateUser(username string, days int) error
-	ExtendClientSubscription(userUUID string, username string, days int) error
-	EnableClient(userUUID string) error
-	DisableClient(userUUID string) error
-	GetUserInfo(uuid string) (models.GetUserInfoResponse, error)
+	GetUUIDByUsername(ctx context.Context, username string) (string, error)
+	// CreateUser создает пользователя с лимитами и сквадом тарифа plan
+	CreateUser(ctx context.Context, username string, days int, plan models.UserPlan) error
+	ExtendClientSubscription(ctx context.Context, userUUID string, username string, days int) error
+	EnableClient(ctx context.Context, userUUID string) error
+	DisableClient(ctx context.Context, userUUID string) error
+	GetUserInfo(ctx context.Context, uuid string) (models.GetUserInfoResponse, error)
+	// GetInternalSquads внутренние сквады панели (регионы)
+	GetInternalSquads(ctx context.Context) ([]models.InternalSquad, error)
+	// SetUserSquads заменяет сквады пользователя userUUID
+	SetUserSquads(ctx context.Context, userUUID string, squads []string) error
+	// SetDevices задает лимит устройств пользователя
+	SetDevices(ctx context.Context, username string, devices int) error
+	// GetUserDevices устройства (HWID), подключавшиеся по подписке
+	GetUserDevices(ctx context.Context, userUUID string) ([]models.HWIDDevice, error)
+	// DeleteUserDevice отвязывает устройство hwid от пользователя
+	DeleteUserDevice(ctx context.Context, userUUID, hwid string) error
+	// SetTrafficLimit задает лимит трафика пользователя в байтах
+	SetTrafficLimit(ctx context.Context, userUUID string, limitBytes int64) error
+	// GetUsers страница пользователей панели: size штук, начиная со start
+	GetUsers(ctx context.Context, start, size int) (models.UsersResponse, error)
+	// RevokeSubscription перевыпускает ссылку подписки, возвращает новую
+	RevokeSubscription(ctx context.Context, userUUID string) (string, error)
 }
 
 type UserRepository interface {
-	CreateUser(models.CreateUserTGDTO) (*models.UserTG, error)
-	GetAllUsers() ([]models.UserTG, error)
-	GetUserByID(string) (*models.UserTG, error)
-	UpdateUser(string, models.UpdateUserTGDTO) (*models.UserTG, error)
+	CreateUser(context.Context, models.CreateUserTGDTO) (*models.UserTG, error)
+	GetAllUsers(ctx context.Context) ([]models.UserTG, error)
+	GetUserByID(ctx context.Context, id string) (*models.UserTG, error)
+	// GetUserByEmail пользователь сайта по email. ErrUserNotFound если такого нет
+	GetUserByEmail(ctx context.Context, email string) (*models.UserTG, error)
+	UpdateUser(ctx context.Context, id string, data models.UpdateUserTGDTO) (*models.UserTG, error)
+	// AddBalance меняет баланс на delta одним запросом, без чтения старого
+	// значения. ErrInsufficientFunds если баланс стал бы отрицательным
+	AddBalance(ctx context.Context, id string, delta int) (*models.UserTG, error)
+	// AddExtraDevice одним запросом списывает price и добавляет устройство сверх
+	// подписки. ErrDeviceLimit если докуплено maxExtra, ErrInsufficientFunds если не хватает баланса
+	AddExtraDevice(ctx context.Context, id string, price, maxExtra int) (*models.UserTG, error)
+	// CancelExtraDevice отменяет AddExtraDevice: возвращает price и убирает устройство
+	CancelExtraDevice(ctx context.Context, id string, price int) (*models.UserTG, error)
+	// AddTrafficPack одним запросом списывает price и записывает пакет на gb
+	// гигабайт в текущий период. ErrInsufficientFunds если не хватает баланса
+	AddTrafficPack(ctx context.Context, id string, gb, price int) (*models.UserTG, error)
+	// CancelTrafficPack отменяет AddTrafficPack: возвращает price и убирает пакет
+	CancelTrafficPack(ctx context.Context, id string, gb, price int) (*models.UserTG, error)
+	// ResetTrafficPacks убирает пакеты, купленные до resetAt (начала нового
+	// периода). false если таких пакетов нет
+	ResetTrafficPacks(ctx context.Context, id string, resetAt time.Time) (bool, error)
+	// ClaimTrial отмечает пробный период использованным (users.trial).
+	// false, если он уже был отмечен
+	ClaimTrial(ctx context.Context, id string) (bool, error)
+}
+
+// BotStateRepository - состояние бота, которое должно пережить рестарт
+type BotStateRepository interface {
+	// GetLastUpdateID последний обработанный update_id телеграма. 0 если еще не было
+	GetLastUpdateID() (int, error)
+	// SaveLastUpdateID запоминает последний обработанный update_id
+	SaveLastUpdateID(updateID int) error
+	// MarkCallbackProcessed записывает ключ идемпотентности (id callback query).
+	// Возвращает false, если этот callback уже обрабатывали
+	MarkCallbackProcessed(callbackID string, userID string) (bool, error)
+	// DeleteProcessedCallbacks удаляет ключи идемпотентности старше olderThan.
+	// Возвращает сколько удалено
+	DeleteProcessedCallbacks(olderThan time.Duration) (int64, error)
 }
 
 // SubscriptionService - бизнес логика управления подписками
@@ -36,10 +96,15 @@ type SubscriptionService interface {
 	// ActivateSubscriotion обрабатывает логику создания или
 	// продления подписки
 	// принимает телеграм id и на сколько месяцев нужно
-	ActivateSubscription(telegramID int64, months int) (string, error)
+	ActivateSubscription(ctx context.Context, telegramID int64, months int) (string, error)
+	// ActivateForUser то же по id пользователя в DB (покупка с сайта)
+	ActivateForUser(ctx context.Context, userID string, months int) (string, error)
 }
 
 // TrialService - бизнес логика пробного периода
 type TrialService interface {
-	ActivateTrial(telegramID int64) (string, error)
+	// ActivateTrial выдает пробную подписку на settings.TrialDays дней и
+	// возвращает их число. Пробный период дается один раз и только тем,
+	// у кого подписки еще не было
+	ActivateTrial(ctx context.Context, telegramID int64) (int, error)
 }
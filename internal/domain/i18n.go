@@ -0,0 +1,20 @@
+// Package domain описание контрактов для перевода текстов бота
+package domain
+
+// Translator каталоги переводов на все поддерживаемые языки
+type Translator interface {
+	// For возвращает переводчик для конкретного языка
+	For(lang string) Localizer
+	// Resolve превращает LanguageCode телеграма ("en-US", "uk") в поддерживаемый язык
+	Resolve(code string) string
+	// Languages список поддерживаемых языков
+	Languages() []string
+}
+
+// Localizer переводчик, привязанный к одному языку
+type Localizer interface {
+	// T возвращает текст по ключу. args подставляются как в fmt.Sprintf
+	T(key string, args ...any) string
+	// Lang язык переводчика
+	Lang() string
+}
@@ -0,0 +1,50 @@
+// Package domain описание контракта метрик. Сервисы и клиенты сообщают
+// о событиях через Metrics и не знают, куда они уходят (Prometheus или никуда)
+package domain
+
+import "time"
+
+// Metrics события для мониторинга. Реализация должна быть безопасной
+// для вызова из разных горутин
+type Metrics interface {
+	// ObserveUpdate обработано обновление телеграма: тип, команда или маршрут кнопки,
+	// успешно ли и сколько заняло
+	ObserveUpdate(kind, route string, ok bool, duration time.Duration)
+	// ObserveRemnawaveRequest запрос к панели: endpoint без id ("GET /api/users/{uuid}"),
+	// HTTP статус (0 - ответа нет) и время
+	ObserveRemnawaveRequest(endpoint string, status int, duration time.Duration)
+	// PaymentCreated создан счет на пополнение
+	PaymentCreated(provider string)
+	// PaymentSucceeded оплата зачислена на баланс, amount - рубли без комиссии
+	PaymentSucceeded(provider string, amount int)
+	// PaymentFailed счет не создан или платежка отменила оплату
+	PaymentFailed(provider string)
+	// PaymentRefunded оплата возвращена
+	PaymentRefunded(provider string, amount int)
+	// BalanceDebited списание с баланса, reason - за что ("subscription")
+	BalanceDebited(reason string, amount int)
+}
+
+// NopMetrics метрики выключены: все события просто пропускаются
+type NopMetrics struct{}
+
+// ObserveUpdate ничего не делает
+func (NopMetrics) ObserveUpdate(string, string, bool, time.Duration) {}
+
+// ObserveRemnawaveRequest ничего не делает
+func (NopMetrics) ObserveRemnawaveRequest(string, int, time.Duration) {}
+
+// PaymentCreated ничего не делает
+func (NopMetrics) PaymentCreated(string) {}
+
+// PaymentSucceeded ничего не делает
+func (NopMetrics) PaymentSucceeded(string, int) {}
+
+// PaymentFailed ничего не делает
+func (NopMetrics) PaymentFailed(string) {}
+
+// PaymentRefunded ничего не делает
+func (NopMetrics) PaymentRefunded(string, int) {}
+
+// BalanceDebited ничего не делает
+func (NopMetrics) BalanceDebited(string, int) {}
@@ -2,17 +2,37 @@
 // для работы с платежными системами
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"ProxyMaster_v2/internal/models"
+)
+
+var (
+	// ErrTransactionNotFound транзакции с таким id нет
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrUnknownProvider платежная система не найдена или выключена
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrAmountOutOfRange сумма вне лимитов платежной системы
+	ErrAmountOutOfRange = errors.New("amount out of range")
+)
 
 type PaymentStatus string
 
 const (
 	PaymentStatusPending PaymentStatus = "pending"
-	PaymentStatusSuccess PaymentStatus = "success"
-	PaymentStatusFailed  PaymentStatus = "failed"
+	// PaymentStatusPaid деньги получены, но еще не зачислены на баланс.
+	// Такие транзакции зачисляет PaymentService.Watch
+	PaymentStatusPaid     PaymentStatus = "paid"
+	PaymentStatusSuccess  PaymentStatus = "success"
+	PaymentStatusFailed   PaymentStatus = "failed"
+	PaymentStatusRefunded PaymentStatus = "refunded"
 )
 
-// PaymentGateway Общий интерфейс для всех платежных систем
+// PaymentGateway Общий интерфейс для всех платежных систем.
+// transactionID в CheckStatus и GetTransactionInfo - externalID, который вернул
+// CreateTransaction, а если платежка его не вернула - наш orderID
 type PaymentGateway interface {
 	CreateTransaction(ctx context.Context, amount float64, orderID string) (paymentURL, externalID string, err error)
 	CheckStatus(ctx context.Context, transactionID string) (PaymentStatus, error)
@@ -26,3 +46,88 @@ type TransactionInfo interface {
 	GetStatus() string
 	GetRawResponse() any
 }
+
+// Refunder платежная система, которая умеет возвращать деньги.
+// PaymentGateway может дополнительно реализовать этот интерфейс
+type Refunder interface {
+	// Refund возвращает пользователю оплату транзакции transactionID.
+	// Баланс тут не трогается, это делает PaymentService
+	Refund(ctx context.Context, transactionID string) error
+}
+
+// TransactionRepository хранение транзакций (таблица transactions)
+type TransactionRepository interface {
+	CreateTransaction(models.CreateTransactionDTO) (*models.Transaction, error)
+	GetTransactionByID(id string) (*models.Transaction, error)
+	// SetTransactionStatus переводит транзакцию из статуса from в to одной
+	// атомарной операцией. false если транзакция уже не в статусе from
+	// (например ее уже зачислили). Пустой externalID оставляет старый
+	SetTransactionStatus(id string, from, to PaymentStatus, externalID string) (bool, error)
+	// CompleteTransaction одной DB транзакцией переводит транзакцию из pending
+	// или paid в success и зачисляет ее сумму на баланс. false если уже зачислена
+	CompleteTransaction(id, externalID string) (bool, error)
+	// RefundTransaction одной DB транзакцией переводит транзакцию из success в
+	// refunded и списывает ее сумму с баланса. ErrInsufficientFunds если
+	// баланса не хватает, false если транзакция уже не в success
+	RefundTransaction(id string) (bool, error)
+	// RevertRefund отменяет RefundTransaction, если платежка не вернула деньги
+	RevertRefund(id string) (bool, error)
+	// GetTransactionsByStatus транзакции в статусе status, старые первыми
+	GetTransactionsByStatus(status PaymentStatus) ([]models.Transaction, error)
+}
+
+// PaymentProvider включенная платежная система и ее условия для пользователя
+type PaymentProvider struct {
+	// ID ключ провайдера, пишется в transactions.provider
+	ID string
+	// Name название для пользователя
+	Name string
+	// Currencies в чем платит пользователь (XTR, USDT, RUB...)
+	Currencies []string
+	// MinAmount и MaxAmount лимиты пополнения в рублях, 0 - без ограничения
+	MinAmount int
+	MaxAmount int
+	// FeePercent и FeeFixed комиссия сверх суммы пополнения
+	FeePercent float64
+	FeeFixed   int
+	// InChat счет приходит в чат телеграма, а не ссылкой (звезды).
+	// На сайте такой провайдер не показываем
+	InChat  bool
+	Gateway PaymentGateway
+}
+
+// PaymentRegistry включенные платежные системы
+type PaymentRegistry interface {
+	// Providers все включенные провайдеры в порядке показа пользователю
+	Providers() []PaymentProvider
+	// Provider провайдер по ID
+	Provider(id string) (PaymentProvider, bool)
+	// Fee комиссия провайдера в рублях за пополнение на amount
+	Fee(id string, amount int) int
+	// Allows входит ли amount в лимиты провайдера
+	Allows(id string, amount int) bool
+}
+
+// PaymentService бизнес логика пополнения баланса
+type PaymentService interface {
+	// Providers включенные платежные системы для экрана пополнения
+	Providers() []PaymentProvider
+	// Provider включенная платежная система по ID
+	Provider(providerID string) (PaymentProvider, bool)
+	// Fee комиссия провайдера за пополнение на amount рублей
+	Fee(providerID string, amount int) int
+	// Allows можно ли пополнить на amount рублей через провайдера
+	Allows(providerID string, amount int) bool
+	// CreateTopUp создает транзакцию и счет в платежной системе providerID.
+	// Возвращает транзакцию и ссылку на оплату (пусто, если счет пришел прямо в чат)
+	CreateTopUp(ctx context.Context, providerID string, userID string, amount int) (*models.Transaction, string, error)
+	// CompleteTopUp зачисляет оплаченную транзакцию на баланс. Повторный вызов
+	// ничего не зачисляет и возвращает false
+	CompleteTopUp(ctx context.Context, transactionID, externalID string) (*models.Transaction, bool, error)
+	// CheckTopUp спрашивает у платежной системы транзакции статус и, если она
+	// оплачена, зачисляет ее. Для платежек без уведомлений или если уведомление потерялось.
+	// Транзакция другого пользователя - ErrTransactionNotFound
+	CheckTopUp(ctx context.Context, userID, transactionID string) (*models.Transaction, bool, error)
+	// Refund возвращает оплату через платежную систему и списывает сумму с баланса
+	Refund(ctx context.Context, transactionID string) (*models.Transaction, error)
+}
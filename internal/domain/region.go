@@ -0,0 +1,30 @@
+// Package domain регионы подключения. Регион - внутренний сквад панели
+// Remnawave, пользователь выбирает его при покупке и может сменить позже.
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownRegion такого сквада в панели нет
+var ErrUnknownRegion = errors.New("unknown region")
+
+// Region регион подключения
+type Region struct {
+	// ID uuid сквада в панели
+	ID   string
+	Name string
+}
+
+// RegionService выбор региона пользователем
+type RegionService interface {
+	// Regions регионы, которые можно выбрать
+	Regions(ctx context.Context) ([]Region, error)
+	// UserRegion выбранный пользователем регион. false если не выбран
+	// или выбранного сквада больше нет в панели
+	UserRegion(ctx context.Context, userID string) (Region, bool)
+	// SetUserRegion сохраняет выбор и переносит в новый сквад уже
+	// существующую подписку. ErrUnknownRegion если сквада нет
+	SetUserRegion(ctx context.Context, userID, regionID string) error
+}
@@ -0,0 +1,22 @@
+// Package domain перевыпуск ссылки подписки, если ею поделились или она утекла.
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RevokeCooldown как часто пользователь может перевыпускать ссылку
+const RevokeCooldown = time.Hour
+
+// ErrRevokeTooSoon ссылку уже перевыпускали меньше RevokeCooldown назад
+var ErrRevokeTooSoon = errors.New("revoke too soon")
+
+// LinkService ссылка подписки
+type LinkService interface {
+	// RevokeLink перевыпускает ссылку и возвращает новую: старая и
+	// подключения по ней перестают работать. ErrNoSubscription если подписки
+	// нет, ErrRevokeTooSoon и сколько ждать, если перевыпускали недавно
+	RevokeLink(ctx context.Context, userID string) (string, time.Duration, error)
+}
@@ -0,0 +1,96 @@
+// Package domain описание контрактов для настроек, которые админ меняет
+// без рестарта: ссылка на поддержку, цены, пробный период, админы
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ProxyMaster_v2/internal/models"
+)
+
+// Ключи настроек
+const (
+	SettingSupport            = "support"
+	SettingPricePerMonth      = "price_per_month"
+	SettingTrialDays          = "trial_days"
+	SettingDevices            = "devices"
+	SettingPricePerDevice     = "price_per_device"
+	SettingTrafficLimitGB     = "traffic_limit_gb"
+	SettingTrafficReset       = "traffic_reset"
+	SettingPricePerGB         = "price_per_gb"
+	SettingMaintenance        = "maintenance"
+	SettingMaintenanceMessage = "maintenance_message"
+	SettingAdminIDs           = "admin_ids"
+)
+
+var (
+	// ErrUnknownSetting такой настройки нет
+	ErrUnknownSetting = errors.New("unknown setting")
+	// ErrInvalidSetting значение не подходит настройке
+	ErrInvalidSetting = errors.New("invalid setting value")
+	// ErrMaintenance идут техработы, покупки приостановлены
+	ErrMaintenance = errors.New("maintenance")
+)
+
+// SettingsRepository измененные настройки (таблица settings)
+type SettingsRepository interface {
+	GetAllSettings(ctx context.Context) ([]models.Setting, error)
+	// SaveSetting создает или перезаписывает настройку
+	SaveSetting(ctx context.Context, setting models.Setting) error
+	// DeleteSetting возвращает настройке значение по умолчанию
+	DeleteSetting(ctx context.Context, key string) error
+}
+
+// Settings текущие значения настроек. Читать при каждом использовании,
+// а не запоминать: админ может поменять их в любой момент
+type Settings interface {
+	// Support ссылка на поддержку
+	Support() string
+	// PricePerMonth цена месяца подписки в рублях
+	PricePerMonth() int
+	// TrialDays длина пробного периода в днях, 0 - пробного периода нет
+	TrialDays() int
+	// Devices сколько устройств входит в подписку
+	Devices() int
+	// PricePerDevice цена дополнительного устройства в рублях
+	PricePerDevice() int
+	// TrafficLimitGB лимит трафика тарифа в гигабайтах, 0 - безлимит
+	TrafficLimitGB() int
+	// TrafficReset когда панель обнуляет трафик: TrafficResetMonth и т.д.
+	TrafficReset() string
+	// PricePerGB цена гигабайта в пакетах трафика в рублях
+	PricePerGB() int
+	// Maintenance идут ли техработы: покупки и пополнения приостановлены
+	Maintenance() bool
+	// MaintenanceMessage текст для пользователей на время техработ.
+	// Пусто - стандартный текст из переводов
+	MaintenanceMessage() string
+	// IsAdmin есть ли telegram id в списке админов
+	IsAdmin(telegramID int64) bool
+}
+
+// SettingValue настройка для просмотра админом
+type SettingValue struct {
+	Key   string
+	Value string
+	// Overridden значение из DB, а не по умолчанию
+	Overridden bool
+	UpdatedBy  string
+	UpdatedAt  time.Time
+}
+
+// SettingsService чтение и изменение настроек
+type SettingsService interface {
+	Settings
+	// List все настройки в порядке ключей
+	List() []SettingValue
+	// Set проверяет и сохраняет значение. ErrUnknownSetting или ErrInvalidSetting при ошибке
+	Set(ctx context.Context, key, value, actor string) error
+	// Reset возвращает значение по умолчанию
+	Reset(ctx context.Context, key, actor string) error
+	// OnChange вызывает fn после каждого изменения настройки, в том числе
+	// сделанного в другой копии бота и подхваченного при перечитывании
+	OnChange(fn func(key, value string))
+}
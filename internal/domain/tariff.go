@@ -0,0 +1,32 @@
+// Package domain тарифы подписки. Общие для бота и сайта,
+// чтобы цена была одна, где бы ни покупали
+package domain
+
+const (
+	// PricePerMonth цена месяца подписки в рублях по умолчанию,
+	// админ меняет ее настройкой price_per_month
+	PricePerMonth = 100
+	// DaysPerMonth сколько дней подписки дает один месяц
+	DaysPerMonth = 30
+	// TrialDays длина пробного периода в днях по умолчанию (настройка trial_days,
+	// 0 выключает пробный период)
+	TrialDays = 3
+	// DevicesPerSubscription сколько устройств входит в подписку по умолчанию (настройка devices)
+	DevicesPerSubscription = 3
+	// PricePerDevice цена дополнительного устройства по умолчанию (настройка price_per_device)
+	PricePerDevice = 50
+	// MaxExtraDevices сколько устройств можно докупить сверх подписки
+	MaxExtraDevices = 5
+)
+
+// TariffMonths сроки подписки в месяцах, которые можно купить
+var TariffMonths = []int{1, 2, 3}
+
+// Tariff срок подписки и его цена
+type Tariff struct {
+	Months int
+	// Price цена в рублях
+	Price int
+	// Devices сколько устройств входит в подписку
+	Devices int
+}
@@ -3,15 +3,16 @@
 package telegrambot
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log/slog"
+	"slices"
 	"strconv"
-	"strings"
 
 	"ProxyMaster_v2/internal/delivery/telegram"
 	"ProxyMaster_v2/internal/domain"
-	"ProxyMaster_v2/internal/service"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
@@ -19,41 +20,71 @@ import (
 // CallbackHandler то какие сервисы используем
 type CallbackHandler struct {
 	// subService сервис подписки
-	subService      domain.SubscriptionService
-	telegramSupport string
+	subService domain.SubscriptionService
+	// settings ссылка на поддержку, меняется без рестарта
+	settings        domain.Settings
 	remnawaveClient domain.RemnawaveClient
+	// botState хранит ключи идемпотентности callback
+	botState domain.BotStateRepository
+	// users нужен для сохранения выбранного языка и баланса в профиле
+	users domain.UserRepository
+	// regions регионы (сквады панели) и выбор пользователя
+	regions domain.RegionService
+	// devices устройства подписки
+	devices domain.DeviceService
+	// tr каталоги переводов, locales выбирает язык пользователя
+	tr      domain.Translator
+	locales localeResolver
+	// pages редактируемые страницы из шаблонов
+	pages pageRenderer
+
+	logger logger.Logger
 }
 
 // NewCallbackHandler конструктор
 func NewCallbackHandler(
 	subService domain.SubscriptionService,
-	telegramSupport string,
+	settings domain.Settings,
 	remnawaveClient domain.RemnawaveClient,
+	botState domain.BotStateRepository,
+	users domain.UserRepository,
+	regions domain.RegionService,
+	devices domain.DeviceService,
+	tr domain.Translator,
+	content domain.ContentStore,
+	l logger.Logger,
 ) *CallbackHandler {
-	slog.Info("Создан экземпляр подписачного сервиса")
+	l.Info("Создан экземпляр подписачного сервиса")
 
 	return &CallbackHandler{
 		subService:      subService,
-		telegramSupport: telegramSupport,
+		settings:        settings,
 		remnawaveClient: remnawaveClient,
+		botState:        botState,
+		users:           users,
+		regions:         regions,
+		devices:         devices,
+		tr:              tr,
+		locales:         localeResolver{users: users, tr: tr},
+		pages: pageRenderer{
+			content:         content,
+			users:           users,
+			remnawaveClient: remnawaveClient,
+			settings:        settings,
+		},
+		logger: l,
 	}
 }
 
 // mainMenu метод для обработки главного меню
-func (h *CallbackHandler) mainMenu(update tgbotapi.Update, bot *tgbotapi.BotAPI, userID int) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		"Добро пожаловать в ProxyMaster! Выберите раздел:",
-	)
-	// Создаем клавиатуру с ссылкой на поддержку
+func (h *CallbackHandler) mainMenu(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	data := h.pages.data(ctx, update.CallbackQuery.From)
 
-	urlSubscription := service.GetURLSubscription(h.remnawaveClient, strconv.Itoa(userID))
-	keyboard := telegram.NewMainMenuKeyboard(h.telegramSupport, urlSubscription)
-
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
+	// Создаем клавиатуру с ссылкой на поддержку
+	keyboard := telegram.NewMainMenuKeyboard(loc, h.settings.Support(), data.SubscriptionURL, data.TrialDays)
 
+	err := h.pages.edit(messenger, update.CallbackQuery.Message, domain.PageWelcome, loc, data, &keyboard)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -62,15 +93,33 @@ func (h *CallbackHandler) mainMenu(update tgbotapi.Update, bot *tgbotapi.BotAPI,
 }
 
 // tariffs метод для обработки тарифов
-func (h *CallbackHandler) tariffs(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		"Выберите срок подписки:",
+func (h *CallbackHandler) tariffs(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	// Регион спрашиваем, только если есть из чего выбрать. Без списка
+	// регионов тарифы все равно показываем: купить можно в сквад по умолчанию
+	regionName := ""
+	regions, err := h.regions.Regions(ctx)
+	if err != nil {
+		logger.FromContext(ctx, h.logger).Warn("регионы недоступны", logger.Field{Key: "error", Value: err})
+	}
+	if len(regions) > 1 {
+		region, ok := h.regions.UserRegion(ctx, strconv.Itoa(update.CallbackQuery.From.ID))
+		if !ok {
+			// Регион еще не выбран: сначала выбор, после него снова тарифы
+			return h.showRegions(messenger, msg, loc, regions, "", telegram.RouteTariffs.Name())
+		}
+		regionName = region.Name
+	}
+
+	keyboard := telegram.NewTariffsKeyboard(loc, regionName)
+	err = messenger.EditMessage(
+		msg.Chat.ID,
+		msg.MessageID,
+		loc.T("tariffs.title", h.settings.Devices()),
+		&keyboard,
 	)
-	keyboard := telegram.NewTariffsKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
 
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -80,15 +129,24 @@ func (h *CallbackHandler) tariffs(update tgbotapi.Update, bot *tgbotapi.BotAPI)
 }
 
 // profile метод для обработки профиля
-func (h *CallbackHandler) profile(update tgbotapi.Update, bot *tgbotapi.BotAPI, userID int) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		fmt.Sprintf("👤 Личный кабинет\nID: %d\nБаланс: 0.00 ₽", userID),
+func (h *CallbackHandler) profile(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	userID := update.CallbackQuery.From.ID
+
+	// Если DB недоступна, показываем профиль с нулевым балансом
+	balance := 0
+	if user, err := h.users.GetUserByID(ctx, strconv.Itoa(userID)); err == nil {
+		balance = user.Balance
+	}
+
+	msg := update.CallbackQuery.Message
+	keyboard := telegram.NewProfileKeyboard(loc)
+	err := messenger.EditMessage(
+		msg.Chat.ID,
+		msg.MessageID,
+		loc.T("profile.text", userID, balance),
+		&keyboard,
 	)
-	keyboard := telegram.NewProfileKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
 
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -98,16 +156,16 @@ func (h *CallbackHandler) profile(update tgbotapi.Update, bot *tgbotapi.BotAPI,
 }
 
 // support метод для поддержки
-func (h *CallbackHandler) support(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		fmt.Sprintf("🆘 Поддержка\n\nЕсли у вас возникли вопросы, напишите нам: %s",
-			h.telegramSupport),
+func (h *CallbackHandler) support(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+	keyboard := telegram.NewBackToMenuKeyboard(loc)
+	err := messenger.EditMessage(
+		msg.Chat.ID,
+		msg.MessageID,
+		loc.T("support.text", h.settings.Support()),
+		&keyboard,
 	)
-	keyboard := telegram.NewBackToMenuKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
 
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
@@ -117,15 +175,11 @@ func (h *CallbackHandler) support(update tgbotapi.Update, bot *tgbotapi.BotAPI)
 }
 
 // info метод для вывода информации
-func (h *CallbackHandler) info(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.Message.MessageID,
-		"ℹ️ Информация о сервисе\n\nProxyMaster - лучший VPN сервис.",
-	)
-	keyboard := telegram.NewInfoKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
-
+func (h *CallbackHandler) info(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	data := h.pages.data(ctx, update.CallbackQuery.From)
+	keyboard := telegram.NewInfoKeyboard(loc)
+	err := h.pages.edit(messenger, update.CallbackQuery.Message, domain.PageInfo, loc, data, &keyboard)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -133,18 +187,12 @@ func (h *CallbackHandler) info(update tgbotapi.Update, bot *tgbotapi.BotAPI) err
 	return nil
 }
 
-// topupBalance метод заглушка пока что
-func (h *CallbackHandler) topupBalance(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	// Заглушка для пополнения
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		"💳 Выберите способ оплаты (в разработке):",
-	)
-	keyboard := telegram.NewBackToMenuKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
-
+// agreement метод для вывода пользовательского соглашения
+func (h *CallbackHandler) agreement(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	data := h.pages.data(ctx, update.CallbackQuery.From)
+	keyboard := telegram.NewBackToMenuKeyboard(loc)
+	err := h.pages.edit(messenger, update.CallbackQuery.Message, domain.PageAgreement, loc, data, &keyboard)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -152,63 +200,62 @@ func (h *CallbackHandler) topupBalance(update tgbotapi.Update, bot *tgbotapi.Bot
 	return nil
 }
 
-// agreement метод для вывода пользовательского соглашения
-func (h *CallbackHandler) agreement(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	msg := tgbotapi.NewEditMessageText(
-		update.CallbackQuery.Message.Chat.ID,
-		update.CallbackQuery.Message.MessageID,
-		"📜 Пользовательское соглашение\n\n1. Пункт первый\n2. Пункт второй",
-	)
-	keyboard := telegram.NewBackToMenuKeyboard()
-	msg.ReplyMarkup = &keyboard
-	_, err := bot.Send(msg)
+// createUser метод для создания пользователя
+func (h *CallbackHandler) createUser(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	userID := update.CallbackQuery.From.ID
+	callbackID := update.CallbackQuery.ID
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
 
+	months, err := params.Int("months")
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return fmt.Errorf("неверный формат месяцев: %w", err)
 	}
 
-	return nil
-}
-
-// createUser метод для создания пользователя
-func (h *CallbackHandler) createUser(bot *tgbotapi.BotAPI, userID int, data string) error {
-	monthsStr := strings.TrimPrefix(data, "create_user_")
-	months, err := strconv.Atoi(monthsStr)
+	// id callback query - ключ идемпотентности. Если такой callback уже
+	// обрабатывали (например телеграм прислал его повторно после рестарта),
+	// второй раз деньги не списываем
+	first, err := h.botState.MarkCallbackProcessed(callbackID, strconv.Itoa(userID))
 	if err != nil {
-		return fmt.Errorf("неверный формат месяцев: %s", monthsStr)
+		return fmt.Errorf("ошибка проверки ключа идемпотентности: %w", err)
+	}
+	if !first {
+		logger.FromContext(ctx, h.logger).Warn("повторный callback покупки пропущен",
+			logger.Field{Key: "callback_id", Value: callbackID},
+		)
+
+		return nil
 	}
 
 	// Вызываем сервис подписки
-	resultMsg, err := h.subService.ActivateSubscription(int64(userID), months)
+	resultMsg, err := h.subService.ActivateSubscription(ctx, int64(userID), months)
 	if err != nil {
 		if errors.Is(err, domain.ErrInsufficientFunds) {
 			// Если недостаточно средстав, предлагаем пополнить
-			msg := tgbotapi.NewMessage(
-				int64(userID),
-				"❌Пожалуйста, пополните баланс в личном кабинете.",
-			)
-
 			// Добавляем кнопку пополнения
-			keyboard := telegram.NewProfileKeyboard()
-			msg.ReplyMarkup = keyboard
+			keyboard := telegram.NewProfileKeyboard(loc)
 
-			_, err = bot.Send(msg)
+			err = messenger.SendMessage(
+				int64(userID),
+				loc.T("purchase.insufficient_funds"),
+				&keyboard,
+			)
 			if err != nil {
 				return fmt.Errorf("ошибка отправки сообщения о пополнении: %w", err)
 			}
 			return nil
 		}
 
-		slog.Error(
-			"ошибка активации подписки",
-			"err_msg", err,
-		)
-		msg := tgbotapi.NewMessage(
+		logger.FromContext(ctx, h.logger).Error("ошибка активации подписки", logger.Field{Key: "error", Value: err})
+		err = messenger.SendMessage(
 			int64(userID),
-			fmt.Sprintf("Произошла ошибка при обработке заказа, обратитесь в поддержку: %s\n",
-				h.telegramSupport),
+			loc.T("purchase.error", h.settings.Support()),
+			nil,
 		)
-		_, err = bot.Send(msg)
 
 		if err != nil {
 			return fmt.Errorf("failed to send message: %w", err)
@@ -218,76 +265,81 @@ func (h *CallbackHandler) createUser(bot *tgbotapi.BotAPI, userID int, data stri
 		return nil
 	}
 
+	logger.FromContext(ctx, h.logger).Info("подписка активирована", logger.Field{Key: "result", Value: resultMsg})
+
 	// Отправляем успешный ответ пользователю
-	msg := tgbotapi.NewMessage(int64(userID), resultMsg)
-	_, err = bot.Send(msg)
+	err = messenger.SendMessage(int64(userID), loc.T("purchase.success", months), nil)
 	if err != nil {
-		slog.Error(
-			"ошибка отправки сообщения",
-			"err_msg", err,
-		)
+		logger.FromContext(ctx, h.logger).Error("ошибка отправки сообщения", logger.Field{Key: "error", Value: err})
 	}
 
 	return nil
 }
 
-// Handle обработка входящего callback
-func (h *CallbackHandler) Handle(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	data := update.CallbackQuery.Data
-	userID := update.CallbackQuery.From.ID
+// language метод для выбора языка
+func (h *CallbackHandler) language(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+
+	msg := update.CallbackQuery.Message
+	keyboard := telegram.NewLanguageKeyboard(loc, h.tr)
+	err := messenger.EditMessage(
+		msg.Chat.ID,
+		msg.MessageID,
+		loc.T("language.title"),
+		&keyboard,
+	)
 
-	// Отвечаем телеграму, что мы получили callback (чтобы часики пропали)
-	callbackCfg := tgbotapi.NewCallback(update.CallbackQuery.ID, "")
-	if _, err := bot.AnswerCallbackQuery(callbackCfg); err != nil {
-		return fmt.Errorf("ошибка ответа на callback: %w", err)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
 	}
 
-	// Обработка различных действий
-	switch {
-	// === ГЛАВНОЕ МЕНЮ И НАВИГАЦИЯ ===
-	case data == "main_menu":
-		if err := h.mainMenu(update, bot, userID); err != nil {
-			return err
-		}
-
-	case data == "tariffs":
-		if err := h.tariffs(update, bot); err != nil {
-			return err
-		}
+	return nil
+}
 
-	case data == "profile":
-		if err := h.profile(update, bot, userID); err != nil {
-			return err
-		}
+// setLanguage сохраняет выбранный язык в профиле и показывает профиль уже на нем
+func (h *CallbackHandler) setLanguage(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	lang := params["lang"]
+	if !slices.Contains(h.tr.Languages(), lang) {
+		return fmt.Errorf("неподдерживаемый язык: %s", lang)
+	}
 
-	case data == "support":
-		if err := h.support(update, bot); err != nil {
-			return err
-		}
+	userID := strconv.Itoa(update.CallbackQuery.From.ID)
+	if _, err := h.users.UpdateUser(ctx, userID, models.UpdateUserTGDTO{Language: &lang}); err != nil {
+		return fmt.Errorf("ошибка сохранения языка: %w", err)
+	}
 
-	case data == "info":
-		if err := h.info(update, bot); err != nil {
-			return err
-		}
+	// Язык уже сохранен, профиль отрисуется на новом
+	return h.profile(ctx, update, messenger, params)
+}
 
-	case data == "topup_balance":
-		if err := h.topupBalance(update, bot); err != nil {
-			return err
-		}
+// Register регистрирует экраны в роутере кнопок.
+// Новый экран = новый маршрут в telegram/routes.go + строчка тут
+func (h *CallbackHandler) Register(router *telegram.CallbackRouter) {
+	// === ГЛАВНОЕ МЕНЮ И НАВИГАЦИЯ ===
+	router.Register(telegram.RouteMainMenu, h.mainMenu)
+	router.Register(telegram.RouteTariffs, h.tariffs)
+	router.Register(telegram.RouteProfile, h.profile)
+	router.Register(telegram.RouteSupport, h.support)
+	router.Register(telegram.RouteInfo, h.info)
+	router.Register(telegram.RouteLanguage, h.language)
 
 	// === КОНЕЧНЫЕ ДЕЙСТВИЯ ===
-	// 1. Обработка запроса на пользовательское соглашение
-	case data == "agreement":
-		if err := h.agreement(update, bot); err != nil {
-			return err
-		}
-
-	// 2. Логика обработки создания подписки (create_user_{months})
-	case strings.HasPrefix(data, "create_user_"):
-		if err := h.createUser(bot, userID, data); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	// 1. Пользовательское соглашение
+	router.Register(telegram.RouteAgreement, h.agreement)
+	// 2. Создание или продление подписки (tariff:{months})
+	router.Register(telegram.RouteTariff, h.createUser)
+	// 3. Смена языка (set_lang:{lang})
+	router.Register(telegram.RouteSetLanguage, h.setLanguage)
+	// 4. Выбор региона (regions:{next}, set_region:{region}:{next})
+	router.Register(telegram.RouteRegions, h.regionsScreen)
+	router.Register(telegram.RouteSetRegion, h.setRegion)
+	// 5. Устройства (devices, device_unlink:{device}, device_buy)
+	router.Register(telegram.RouteDevices, h.devicesScreen)
+	router.Register(telegram.RouteDeviceUnlink, h.unlinkDevice)
+	router.Register(telegram.RouteDeviceBuy, h.buyDevice)
 }
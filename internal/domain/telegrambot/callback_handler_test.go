@@ -0,0 +1,448 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/delivery/telegram/telegramtest"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/content"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Пользователь и сообщение с кнопками в тестах
+const (
+	testUserID    = 1001
+	testMessageID = 7
+	testSupport   = "https://t.me/proxymaster_support"
+)
+
+// callbackEnv CallbackHandler на фейках, зарегистрированный в настоящем роутере
+type callbackEnv struct {
+	users         *fakeUsers
+	botState      *fakeBotState
+	subscriptions *fakeSubscriptions
+	remnawave     *fakeRemnawave
+	regions       *fakeRegions
+	devices       *fakeDevices
+	messenger     *telegramtest.Messenger
+	router        *telegram.CallbackRouter
+}
+
+// newCallbackEnv окружение с пользователем testUserID без подписки и с балансом 250
+func newCallbackEnv(t *testing.T) *callbackEnv {
+	t.Helper()
+
+	l := newTestLogger(t)
+	tr := newTestTranslator(t)
+
+	settings, err := service.NewSettingsService(nil, map[string]string{domain.SettingSupport: testSupport}, l)
+	if err != nil {
+		t.Fatalf("NewSettingsService: %v", err)
+	}
+	pages, err := content.NewEmbeddedStore()
+	if err != nil {
+		t.Fatalf("content.NewEmbeddedStore: %v", err)
+	}
+
+	e := &callbackEnv{
+		users:         newFakeUsers(models.UserTG{ID: strconv.Itoa(testUserID), Balance: 250}),
+		botState:      &fakeBotState{},
+		subscriptions: &fakeSubscriptions{},
+		remnawave:     &fakeRemnawave{},
+		regions:       &fakeRegions{},
+		devices:       &fakeDevices{},
+		messenger:     telegramtest.NewMessenger(),
+		router:        telegram.NewCallbackRouter(tr, l),
+	}
+
+	handler := NewCallbackHandler(
+		e.subscriptions,
+		settings,
+		e.remnawave,
+		e.botState,
+		e.users,
+		e.regions,
+		e.devices,
+		tr,
+		pages,
+		l,
+	)
+	handler.Register(e.router)
+
+	return e
+}
+
+// press нажатие кнопки с callback data в сообщении testMessageID
+func (e *callbackEnv) press(callbackID, data string) error {
+	update := tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   callbackID,
+			From: &tgbotapi.User{ID: testUserID, FirstName: "Иван", LanguageCode: "ru"},
+			Message: &tgbotapi.Message{
+				MessageID: testMessageID,
+				Chat:      &tgbotapi.Chat{ID: testUserID},
+			},
+			Data: data,
+		},
+	}
+
+	return e.router.Handle(context.Background(), update, e.messenger)
+}
+
+// wantCall ожидаемый вызов Messenger после ответа на callback
+type wantCall struct {
+	method string
+	// text текст целиком. Пусто - не проверяем
+	text string
+	// contains кусок текста, для страниц из шаблонов
+	contains string
+	// button callback data или ссылка, которая должна быть в клавиатуре
+	button string
+}
+
+// check сравнивает вызовы с ожидаемыми. Первым всегда идет ответ на callback
+func (w wantCall) check(t *testing.T, got telegramtest.Call) {
+	t.Helper()
+
+	if got.Method != w.method {
+		t.Fatalf("вызван %s, ожидали %s (текст %q)", got.Method, w.method, got.Text)
+	}
+	if got.ChatID != testUserID {
+		t.Errorf("%s в чат %d, ожидали %d", got.Method, got.ChatID, testUserID)
+	}
+	if w.method == telegramtest.MethodEditMessage || w.method == telegramtest.MethodEditFormatted {
+		if got.MessageID != testMessageID {
+			t.Errorf("%s сообщения %d, ожидали %d", got.Method, got.MessageID, testMessageID)
+		}
+	}
+	if w.text != "" && got.Text != w.text {
+		t.Errorf("%s текст\n%q\nожидали\n%q", got.Method, got.Text, w.text)
+	}
+	if w.contains != "" && !strings.Contains(got.Text, w.contains) {
+		t.Errorf("%s текст %q без %q", got.Method, got.Text, w.contains)
+	}
+	if w.button != "" && !hasButton(got.Keyboard, w.button) {
+		t.Errorf("%s: в клавиатуре нет кнопки %q", got.Method, w.button)
+	}
+}
+
+// hasButton есть ли в клавиатуре кнопка с такой callback data или ссылкой
+func hasButton(keyboard *tgbotapi.InlineKeyboardMarkup, value string) bool {
+	if keyboard == nil {
+		return false
+	}
+
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if (button.CallbackData != nil && *button.CallbackData == value) || (button.URL != nil && *button.URL == value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestCallbackHandlerRoutes(t *testing.T) {
+	ru := newTestTranslator(t).For("ru")
+	en := newTestTranslator(t).For("en")
+
+	regions := []domain.Region{{ID: "squad-de", Name: "Германия"}, {ID: "squad-nl", Name: "Нидерланды"}}
+	device := domain.Device{
+		HWID:      "hwid-1",
+		Platform:  "iOS",
+		OSVersion: "17.4",
+		Model:     "iPhone 15",
+		CreatedAt: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC),
+	}
+	devicesText := ru.T("devices.title", 1, 3) + "\n\n" + ru.T("devices.item", 1, "iPhone 15, iOS 17.4", "02.01.2026")
+
+	var subscription models.GetUserInfoResponse
+	subscription.Response.SubscriptionURL = "https://panel.example.com/api/sub/abc"
+	subscription.Response.ExpireAt = time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		data  string
+		setup func(e *callbackEnv)
+		want  []wantCall
+		// wantErr обработчик должен вернуть ошибку
+		wantErr bool
+		// after дополнительные проверки состояния фейков
+		after func(t *testing.T, e *callbackEnv)
+	}{
+		{
+			name: "главное меню без подписки",
+			data: telegram.RouteMainMenu.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditFormatted, contains: "Добро пожаловать", button: telegram.RouteTariffs.Data()}},
+		},
+		{
+			name:  "главное меню с подпиской",
+			data:  telegram.RouteMainMenu.Data(),
+			setup: func(e *callbackEnv) { e.remnawave.subscription = &subscription },
+			want: []wantCall{{
+				method:   telegramtest.MethodEditFormatted,
+				contains: "01.02.2030",
+				button:   subscription.Response.SubscriptionURL,
+			}},
+		},
+		{
+			name: "тарифы без выбора региона",
+			data: telegram.RouteTariffs.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("tariffs.title", 3), button: telegram.RouteTariff.Data("1")}},
+		},
+		{
+			name:  "тарифы: сначала выбор региона",
+			data:  telegram.RouteTariffs.Data(),
+			setup: func(e *callbackEnv) { e.regions.regions = regions },
+			want: []wantCall{{
+				method: telegramtest.MethodEditMessage,
+				text:   ru.T("region.title"),
+				button: telegram.RouteSetRegion.Data("squad-nl", telegram.RouteTariffs.Name()),
+			}},
+		},
+		{
+			name: "тарифы с выбранным регионом",
+			data: telegram.RouteTariffs.Data(),
+			setup: func(e *callbackEnv) {
+				e.regions.regions = regions
+				e.regions.selected = map[string]string{strconv.Itoa(testUserID): "squad-de"}
+			},
+			want: []wantCall{{
+				method: telegramtest.MethodEditMessage,
+				text:   ru.T("tariffs.title", 3),
+				button: telegram.RouteRegions.Data(telegram.RouteTariffs.Name()),
+			}},
+		},
+		{
+			name: "профиль",
+			data: telegram.RouteProfile.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("profile.text", testUserID, 250), button: telegram.RouteTopupBalance.Data()}},
+		},
+		{
+			name: "поддержка",
+			data: telegram.RouteSupport.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("support.text", testSupport), button: telegram.RouteMainMenu.Data()}},
+		},
+		{
+			name: "информация",
+			data: telegram.RouteInfo.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditFormatted, contains: testSupport, button: telegram.RouteAgreement.Data()}},
+		},
+		{
+			name: "соглашение",
+			data: telegram.RouteAgreement.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditFormatted, contains: "Пользовательское соглашение", button: telegram.RouteMainMenu.Data()}},
+		},
+		{
+			name: "выбор языка",
+			data: telegram.RouteLanguage.Data(),
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("language.title"), button: telegram.RouteSetLanguage.Data("en")}},
+		},
+		{
+			name: "смена языка: профиль уже на новом",
+			data: telegram.RouteSetLanguage.Data("en"),
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: en.T("profile.text", testUserID, 250)}},
+			after: func(t *testing.T, e *callbackEnv) {
+				if lang := e.users.user(t, strconv.Itoa(testUserID)).Language; lang != "en" {
+					t.Errorf("сохранен язык %q, ожидали en", lang)
+				}
+			},
+		},
+		{
+			name:    "смена на неизвестный язык",
+			data:    telegram.RouteSetLanguage.Data("xx"),
+			wantErr: true,
+			after: func(t *testing.T, e *callbackEnv) {
+				if lang := e.users.user(t, strconv.Itoa(testUserID)).Language; lang != "" {
+					t.Errorf("сохранен язык %q", lang)
+				}
+			},
+		},
+		{
+			name: "покупка подписки",
+			data: telegram.RouteTariff.Data("3"),
+			want: []wantCall{{method: telegramtest.MethodSendMessage, text: ru.T("purchase.success", 3)}},
+			after: func(t *testing.T, e *callbackEnv) {
+				if !slices.Equal(e.subscriptions.months, []int{3}) {
+					t.Errorf("куплено %v, ожидали [3]", e.subscriptions.months)
+				}
+			},
+		},
+		{
+			name:  "покупка без денег: предлагаем пополнить",
+			data:  telegram.RouteTariff.Data("1"),
+			setup: func(e *callbackEnv) { e.subscriptions.err = domain.ErrInsufficientFunds },
+			want: []wantCall{{
+				method: telegramtest.MethodSendMessage,
+				text:   ru.T("purchase.insufficient_funds"),
+				button: telegram.RouteTopupBalance.Data(),
+			}},
+		},
+		{
+			name:  "ошибка покупки",
+			data:  telegram.RouteTariff.Data("1"),
+			setup: func(e *callbackEnv) { e.subscriptions.err = errors.New("panel down") },
+			want:  []wantCall{{method: telegramtest.MethodSendMessage, text: ru.T("purchase.error", testSupport)}},
+		},
+		{
+			name: "повторный callback покупки не списывает второй раз",
+			data: telegram.RouteTariff.Data("1"),
+			setup: func(e *callbackEnv) {
+				e.botState.callbacks = map[string]bool{"cb-1": true}
+			},
+			after: func(t *testing.T, e *callbackEnv) {
+				if len(e.subscriptions.months) != 0 {
+					t.Errorf("подписка куплена повторно: %v", e.subscriptions.months)
+				}
+			},
+		},
+		{
+			name:  "регионы из профиля",
+			data:  telegram.RouteRegions.Data(telegram.RouteProfile.Name()),
+			setup: func(e *callbackEnv) { e.regions.regions = regions },
+			want: []wantCall{{
+				method: telegramtest.MethodEditMessage,
+				text:   ru.T("region.title"),
+				button: telegram.RouteSetRegion.Data("squad-de", telegram.RouteProfile.Name()),
+			}},
+		},
+		{
+			name:  "регионы недоступны",
+			data:  telegram.RouteRegions.Data(telegram.RouteProfile.Name()),
+			setup: func(e *callbackEnv) { e.regions.err = errors.New("panel down") },
+			want:  []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("region.unavailable"), button: telegram.RouteMainMenu.Data()}},
+		},
+		{
+			name:  "выбор региона из профиля",
+			data:  telegram.RouteSetRegion.Data("squad-nl", telegram.RouteProfile.Name()),
+			setup: func(e *callbackEnv) { e.regions.regions = regions },
+			want:  []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("profile.text", testUserID, 250)}},
+			after: func(t *testing.T, e *callbackEnv) {
+				if got := e.regions.selected[strconv.Itoa(testUserID)]; got != "squad-nl" {
+					t.Errorf("выбран регион %q, ожидали squad-nl", got)
+				}
+			},
+		},
+		{
+			name:  "выбор региона перед покупкой возвращает к тарифам",
+			data:  telegram.RouteSetRegion.Data("squad-de", telegram.RouteTariffs.Name()),
+			setup: func(e *callbackEnv) { e.regions.regions = regions },
+			want:  []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("tariffs.title", 3), button: telegram.RouteTariff.Data("1")}},
+		},
+		{
+			name:  "регион из старого списка: показываем актуальный",
+			data:  telegram.RouteSetRegion.Data("squad-old", telegram.RouteProfile.Name()),
+			setup: func(e *callbackEnv) { e.regions.regions = regions },
+			want:  []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("region.title")}},
+		},
+		{
+			name: "устройства",
+			data: telegram.RouteDevices.Data(),
+			setup: func(e *callbackEnv) {
+				e.devices.info = domain.DevicesInfo{Devices: []domain.Device{device}, Limit: 3}
+			},
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: devicesText, button: telegram.RouteDeviceUnlink.Data(device.Key())}},
+		},
+		{
+			name:  "устройства без подписки",
+			data:  telegram.RouteDevices.Data(),
+			setup: func(e *callbackEnv) { e.devices.err = domain.ErrNoSubscription },
+			want:  []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("devices.no_subscription")}},
+		},
+		{
+			name: "отвязка устройства",
+			data: telegram.RouteDeviceUnlink.Data(device.Key()),
+			setup: func(e *callbackEnv) {
+				e.devices.info = domain.DevicesInfo{Devices: []domain.Device{device}, Limit: 3}
+			},
+			want: []wantCall{{method: telegramtest.MethodEditMessage, text: ru.T("devices.title", 0, 3) + "\n\n" + ru.T("devices.empty")}},
+			after: func(t *testing.T, e *callbackEnv) {
+				if !slices.Equal(e.devices.unlinked, []string{device.Key()}) {
+					t.Errorf("отвязаны %v", e.devices.unlinked)
+				}
+			},
+		},
+		{
+			name: "покупка устройства",
+			data: telegram.RouteDeviceBuy.Data(),
+			setup: func(e *callbackEnv) {
+				e.devices.info = domain.DevicesInfo{Limit: 3}
+			},
+			want: []wantCall{
+				{method: telegramtest.MethodSendMessage, text: ru.T("devices.buy_success", 4)},
+				{method: telegramtest.MethodEditMessage, text: ru.T("devices.title", 0, 4) + "\n\n" + ru.T("devices.empty")},
+			},
+		},
+		{
+			name:  "покупка устройства сверх максимума",
+			data:  telegram.RouteDeviceBuy.Data(),
+			setup: func(e *callbackEnv) { e.devices.buyErr = domain.ErrDeviceLimit },
+			want:  []wantCall{{method: telegramtest.MethodSendMessage, text: ru.T("devices.limit_reached")}},
+		},
+		{
+			name: "покупка устройства без возврата денег",
+			data: telegram.RouteDeviceBuy.Data(),
+			setup: func(e *callbackEnv) {
+				e.devices.buyErr = fmt.Errorf("%w: %w", domain.ErrNotRefunded, errors.New("panel down"))
+			},
+			want: []wantCall{{method: telegramtest.MethodSendMessage, text: ru.T("devices.not_refunded", testSupport)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newCallbackEnv(t)
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+
+			err := e.press("cb-1", tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Handle: %v, ожидали ошибку: %v", err, tt.wantErr)
+			}
+
+			calls := e.messenger.Calls()
+			if len(calls) == 0 || calls[0].Method != telegramtest.MethodAnswerCallback || calls[0].CallbackID != "cb-1" {
+				t.Fatalf("первым должен быть ответ на callback cb-1, вызовы: %+v", calls)
+			}
+
+			calls = calls[1:]
+			if len(calls) != len(tt.want) {
+				t.Fatalf("вызовов %d, ожидали %d: %+v", len(calls), len(tt.want), calls)
+			}
+			for i, want := range tt.want {
+				want.check(t, calls[i])
+			}
+
+			if tt.after != nil {
+				tt.after(t, e)
+			}
+		})
+	}
+}
+
+func TestCallbackHandlerUnknownButton(t *testing.T) {
+	e := newCallbackEnv(t)
+
+	if err := e.press("cb-1", "removed_screen"); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	calls := e.messenger.Calls()
+	want := newTestTranslator(t).For("ru").T("error.unknown_button")
+	if len(calls) != 1 || calls[0].Method != telegramtest.MethodAnswerCallback || calls[0].Text != want {
+		t.Fatalf("вызовы %+v, ожидали только ответ %q", calls, want)
+	}
+}
@@ -3,13 +3,11 @@
 package telegrambot
 
 import (
-	"log"
-	"log/slog"
-	"strconv"
+	"context"
+	"fmt"
 
 	"ProxyMaster_v2/internal/delivery/telegram"
 	"ProxyMaster_v2/internal/domain"
-	"ProxyMaster_v2/internal/service"
 	"ProxyMaster_v2/pkg/logger"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -18,24 +16,41 @@ import (
 // StartCommand это /start
 type StartCommand struct {
 	kbBuilder *telegram.KeyboardBuilder
-	// Передаем ссылку на телеграмм
-	telegramSupport string
+	// settings ссылка на поддержку, меняется без рестарта
+	settings domain.Settings
 
 	remnawaveClient domain.RemnawaveClient
 
+	// locales выбирает язык пользователя
+	locales localeResolver
+	// pages редактируемые страницы из шаблонов
+	pages pageRenderer
+
 	logger logger.Logger
 }
 
 // NewStartCommand конструктор.
 func NewStartCommand(
 	kb *telegram.KeyboardBuilder,
-	telegramSupport string,
-	remnawaveClient domain.RemnawaveClient) *StartCommand {
+	settings domain.Settings,
+	remnawaveClient domain.RemnawaveClient,
+	users domain.UserRepository,
+	tr domain.Translator,
+	content domain.ContentStore,
+	l logger.Logger) *StartCommand {
 
 	return &StartCommand{
 		kbBuilder:       kb,
-		telegramSupport: telegramSupport,
+		settings:        settings,
 		remnawaveClient: remnawaveClient,
+		locales:         localeResolver{users: users, tr: tr},
+		pages: pageRenderer{
+			content:         content,
+			users:           users,
+			remnawaveClient: remnawaveClient,
+			settings:        settings,
+		},
+		logger: l,
 	}
 }
 
@@ -45,22 +60,106 @@ func (s *StartCommand) Name() string {
 }
 
 // Execute то как идет обработка команд
-func (s *StartCommand) Execute(update tgbotapi.Update, bot *tgbotapi.BotAPI) error {
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Добро пожаловать в ProxyMaster! Выберите раздел:")
+func (s *StartCommand) Execute(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := s.locales.localizer(ctx, update.Message.From)
 
-	urlSubscription := service.GetURLSubscription(s.remnawaveClient, strconv.Itoa(update.Message.From.ID))
+	data := s.pages.data(ctx, update.Message.From)
 
 	// Отправляем клавиатуру с поддержкой
-	msg.ReplyMarkup = telegram.NewMainMenuKeyboard(s.telegramSupport, urlSubscription)
+	keyboard := telegram.NewMainMenuKeyboard(loc, s.settings.Support(), data.SubscriptionURL, data.TrialDays)
 
-	_, err := bot.Send(msg)
+	err := s.pages.send(messenger, update.Message.Chat.ID, domain.PageWelcome, loc, data, &keyboard)
 	if err != nil {
-		log.Printf("ошибка отправки сообщения: %v", err)
-		slog.Error(
-			"ошибка отправки сообщения",
-			"err_msg", err,
+		logger.FromContext(ctx, s.logger).Error("ошибка отправки сообщения", logger.Field{Key: "error", Value: err})
+	}
+
+	return nil
+}
+
+// ReloadContentCommand это /reload_content: перечитывает шаблоны текстов без рестарта.
+// Доступ только админам, см. AdminOnly в app.go
+type ReloadContentCommand struct {
+	content domain.ContentStore
+	tr      domain.Translator
+	logger  logger.Logger
+}
+
+// NewReloadContentCommand конструктор.
+func NewReloadContentCommand(content domain.ContentStore, tr domain.Translator, l logger.Logger) *ReloadContentCommand {
+	return &ReloadContentCommand{content: content, tr: tr, logger: l}
+}
+
+// Name возвращаем /reload_content
+func (c *ReloadContentCommand) Name() string {
+	return "reload_content"
+}
+
+// Execute перечитывает шаблоны и сообщает админу результат. Если новые
+// шаблоны с ошибкой, бот продолжает работать на старых, а админ видит причину
+func (c *ReloadContentCommand) Execute(_ context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := c.tr.For(c.tr.Resolve(update.Message.From.LanguageCode))
+
+	text := loc.T("admin.content_reloaded")
+	if err := c.content.Reload(); err != nil {
+		c.logger.Warn("шаблоны текстов не перезагружены",
+			logger.Field{Key: "user_id", Value: update.Message.From.ID},
+			logger.Field{Key: "error", Value: err},
 		)
+		text = loc.T("admin.content_reload_failed", err.Error())
+	} else {
+		c.logger.Info("шаблоны текстов перезагружены", logger.Field{Key: "user_id", Value: update.Message.From.ID})
+	}
+
+	if err := messenger.SendMessage(update.Message.Chat.ID, text, nil); err != nil {
+		return fmt.Errorf("ошибка отправки ответа на reload_content: %w", err)
 	}
 
 	return nil
 }
+
+// RefundCommand это /refund <id транзакции>: возврат платежа админом
+type RefundCommand struct {
+	payments domain.PaymentService
+	tr       domain.Translator
+	logger   logger.Logger
+}
+
+// NewRefundCommand конструктор.
+func NewRefundCommand(payments domain.PaymentService, tr domain.Translator, l logger.Logger) *RefundCommand {
+	return &RefundCommand{payments: payments, tr: tr, logger: l}
+}
+
+// Name возвращаем /refund
+func (c *RefundCommand) Name() string {
+	return "refund"
+}
+
+// Execute возвращает платеж и сообщает админу результат
+func (c *RefundCommand) Execute(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := c.tr.For(c.tr.Resolve(update.Message.From.LanguageCode))
+	chatID := update.Message.Chat.ID
+
+	transactionID := update.Message.CommandArguments()
+	if transactionID == "" {
+		return messenger.SendMessage(chatID, loc.T("admin.refund_usage"), nil)
+	}
+
+	tx, err := c.payments.Refund(ctx, transactionID)
+	if err != nil {
+		c.logger.Warn("возврат не выполнен",
+			logger.Field{Key: "admin_id", Value: update.Message.From.ID},
+			logger.Field{Key: "transaction_id", Value: transactionID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return messenger.SendMessage(chatID, loc.T("admin.refund_failed", err.Error()), nil)
+	}
+
+	c.logger.Info("платеж возвращен админом",
+		logger.Field{Key: "admin_id", Value: update.Message.From.ID},
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+		logger.Field{Key: "user_id", Value: tx.UserID},
+	)
+
+	return messenger.SendMessage(chatID, loc.T("admin.refund_done", tx.ID, tx.Amount), nil)
+}
@@ -0,0 +1,92 @@
+// Package telegrambot сборка редактируемых страниц (приветствие, информация,
+// соглашение) из шаблонов с данными пользователя
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// expireAtLayout формат даты окончания подписки в текстах
+const expireAtLayout = "02.01.2006"
+
+// pageRenderer собирает переменные для шаблонов и рендерит страницу
+type pageRenderer struct {
+	content         domain.ContentStore
+	users           domain.UserRepository
+	remnawaveClient domain.RemnawaveClient
+	settings        domain.Settings
+}
+
+// data переменные шаблона для пользователя. Если DB или remnawave недоступны,
+// соответствующие поля остаются пустыми: страницу все равно надо показать
+func (r pageRenderer) data(ctx context.Context, user *tgbotapi.User) domain.ContentData {
+	data := domain.ContentData{
+		UserID:    user.ID,
+		FirstName: user.FirstName,
+		Support:   r.settings.Support(),
+	}
+
+	// Новый пользователь, которого еще нет в DB, пробный период не брал
+	trialUsed := false
+	if dbUser, err := r.users.GetUserByID(ctx, strconv.Itoa(user.ID)); err == nil {
+		data.Balance = dbUser.Balance
+		trialUsed = dbUser.Trial
+	}
+
+	info, ok := service.GetSubscriptionInfo(ctx, r.remnawaveClient, strconv.Itoa(user.ID))
+	if ok {
+		data.SubscriptionURL = info.URL
+		data.HasSubscription = info.URL != ""
+		if !info.ExpireAt.IsZero() {
+			data.ExpireAt = info.ExpireAt.Format(expireAtLayout)
+		}
+	}
+	// Пользователь в панели уже был, даже если подписка истекла
+	if !ok && !trialUsed {
+		data.TrialDays = r.settings.TrialDays()
+	}
+
+	return data
+}
+
+// send отправляет страницу новым сообщением
+func (r pageRenderer) send(
+	messenger telegram.Messenger,
+	chatID int64,
+	page string,
+	loc domain.Localizer,
+	data domain.ContentData,
+	keyboard *tgbotapi.InlineKeyboardMarkup,
+) error {
+	rendered, err := r.content.Render(page, loc.Lang(), data)
+	if err != nil {
+		return fmt.Errorf("ошибка сборки страницы %s: %w", page, err)
+	}
+
+	return messenger.SendFormatted(chatID, rendered.Text, rendered.ParseMode, keyboard)
+}
+
+// edit показывает страницу вместо текущего сообщения
+func (r pageRenderer) edit(
+	messenger telegram.Messenger,
+	msg *tgbotapi.Message,
+	page string,
+	loc domain.Localizer,
+	data domain.ContentData,
+	keyboard *tgbotapi.InlineKeyboardMarkup,
+) error {
+	rendered, err := r.content.Render(page, loc.Lang(), data)
+	if err != nil {
+		return fmt.Errorf("ошибка сборки страницы %s: %w", page, err)
+	}
+
+	return messenger.EditFormatted(msg.Chat.ID, msg.MessageID, rendered.Text, rendered.ParseMode, keyboard)
+}
@@ -0,0 +1,157 @@
+// Package telegrambot экран "Мои устройства": устройства из панели,
+// отвязка и покупка устройства сверх подписки.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// devicesScreen список устройств и лимит
+func (h *CallbackHandler) devicesScreen(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	info, err := h.devices.Devices(ctx, strconv.Itoa(update.CallbackQuery.From.ID))
+	if err != nil {
+		text := loc.T("devices.no_subscription")
+		if !errors.Is(err, domain.ErrNoSubscription) {
+			logger.FromContext(ctx, h.logger).Error("ошибка получения устройств", logger.Field{Key: "error", Value: err})
+			text = loc.T("devices.error")
+		}
+
+		keyboard := telegram.NewBackToMenuKeyboard(loc)
+		if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		return nil
+	}
+
+	lines := []string{loc.T("devices.title", len(info.Devices), info.Limit)}
+	if len(info.Devices) == 0 {
+		lines = append(lines, loc.T("devices.empty"))
+	}
+	for i, d := range info.Devices {
+		lines = append(lines, loc.T("devices.item", i+1, deviceName(loc, d), d.CreatedAt.Format("02.01.2006")))
+	}
+
+	// Кнопку покупки показываем, пока не докуплен максимум
+	price := 0
+	if info.Extra < domain.MaxExtraDevices {
+		price = h.settings.PricePerDevice()
+	}
+
+	keyboard := telegram.NewDevicesKeyboard(loc, info.Devices, price)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, strings.Join(lines, "\n\n"), &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// unlinkDevice отвязывает устройство и показывает обновленный список
+func (h *CallbackHandler) unlinkDevice(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	userID := update.CallbackQuery.From.ID
+
+	err := h.devices.UnlinkDevice(ctx, strconv.Itoa(userID), params["device"])
+	// Устройство уже отвязано (кнопка из старого списка) - просто обновляем экран
+	if err != nil && !errors.Is(err, domain.ErrDeviceNotFound) {
+		logger.FromContext(ctx, h.logger).Error("ошибка отвязки устройства", logger.Field{Key: "error", Value: err})
+
+		loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+		if err := messenger.SendMessage(int64(userID), loc.T("devices.unlink_error"), nil); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		return nil
+	}
+
+	return h.devicesScreen(ctx, update, messenger, params)
+}
+
+// buyDevice покупка устройства сверх подписки с баланса
+func (h *CallbackHandler) buyDevice(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	userID := update.CallbackQuery.From.ID
+	callbackID := update.CallbackQuery.ID
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+
+	// Как и при покупке подписки: повторный callback не списывает деньги второй раз
+	first, err := h.botState.MarkCallbackProcessed(callbackID, strconv.Itoa(userID))
+	if err != nil {
+		return fmt.Errorf("ошибка проверки ключа идемпотентности: %w", err)
+	}
+	if !first {
+		logger.FromContext(ctx, h.logger).Warn("повторный callback покупки устройства пропущен",
+			logger.Field{Key: "callback_id", Value: callbackID},
+		)
+
+		return nil
+	}
+
+	limit, err := h.devices.BuyExtraDevice(ctx, strconv.Itoa(userID))
+
+	var text string
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	switch {
+	case err == nil:
+		text = loc.T("devices.buy_success", limit)
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		profile := telegram.NewProfileKeyboard(loc)
+		text, keyboard = loc.T("purchase.insufficient_funds"), &profile
+	case errors.Is(err, domain.ErrDeviceLimit):
+		text = loc.T("devices.limit_reached")
+	case errors.Is(err, domain.ErrNoSubscription):
+		text = loc.T("devices.no_subscription")
+	case errors.Is(err, domain.ErrNotRefunded):
+		logger.FromContext(ctx, h.logger).Error("устройство оплачено, но не добавлено", logger.Field{Key: "error", Value: err})
+		text = loc.T("devices.not_refunded", h.settings.Support())
+	default:
+		logger.FromContext(ctx, h.logger).Error("ошибка покупки устройства", logger.Field{Key: "error", Value: err})
+		text = loc.T("devices.buy_error", h.settings.Support())
+	}
+
+	if sendErr := messenger.SendMessage(int64(userID), text, keyboard); sendErr != nil {
+		return fmt.Errorf("failed to send message: %w", sendErr)
+	}
+	// Лимит не изменился, экран обновлять незачем
+	if err != nil {
+		return nil
+	}
+
+	return h.devicesScreen(ctx, update, messenger, params)
+}
+
+// deviceName название устройства для списка: модель и система
+func deviceName(loc domain.Localizer, d domain.Device) string {
+	parts := make([]string, 0, 2)
+	for _, part := range []string{d.Model, strings.TrimSpace(d.Platform + " " + d.OSVersion)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return loc.T("devices.unknown")
+	}
+
+	return strings.Join(parts, ", ")
+}
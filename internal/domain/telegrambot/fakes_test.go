@@ -0,0 +1,349 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/i18n"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Фейки зависимостей экранов. Каждый встраивает интерфейс целиком и
+// реализует только то, что вызывают экраны: вызов остального - паника,
+// то есть обработчик полез туда, куда тест не ожидал
+
+// newTestLogger логгер, который пишет только ошибки
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+
+	levels, err := logger.NewLevels("error", "")
+	if err != nil {
+		t.Fatalf("NewLevels: %v", err)
+	}
+	l, err := logger.New(logger.Options{Levels: levels})
+	if err != nil {
+		t.Fatalf("logger.New: %v", err)
+	}
+
+	return l
+}
+
+// newTestTranslator каталоги переводов из бинарника, как в боте
+func newTestTranslator(t *testing.T) domain.Translator {
+	t.Helper()
+
+	tr, err := i18n.LoadEmbedded()
+	if err != nil {
+		t.Fatalf("i18n.LoadEmbedded: %v", err)
+	}
+
+	return tr
+}
+
+// fakeUsers пользователи в памяти вместо таблицы users
+type fakeUsers struct {
+	domain.UserRepository
+
+	mu    sync.Mutex
+	users map[string]models.UserTG
+}
+
+// newFakeUsers фейк с заданными пользователями
+func newFakeUsers(users ...models.UserTG) *fakeUsers {
+	f := &fakeUsers{users: make(map[string]models.UserTG, len(users))}
+	for _, user := range users {
+		f.users[user.ID] = user
+	}
+
+	return f
+}
+
+// GetUserByID копия пользователя, как будто только что прочитали из DB
+func (f *fakeUsers) GetUserByID(_ context.Context, id string) (*models.UserTG, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return &user, nil
+}
+
+// UpdateUser меняет только переданные поля
+func (f *fakeUsers) UpdateUser(_ context.Context, id string, data models.UpdateUserTGDTO) (*models.UserTG, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	if data.Language != nil {
+		user.Language = *data.Language
+	}
+	if data.Region != nil {
+		user.Region = *data.Region
+	}
+	f.users[id] = user
+
+	return &user, nil
+}
+
+// AddBalance меняет баланс на delta, в минус не уходит
+func (f *fakeUsers) AddBalance(_ context.Context, id string, delta int) (*models.UserTG, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	if user.Balance+delta < 0 {
+		return nil, domain.ErrInsufficientFunds
+	}
+	user.Balance += delta
+	f.users[id] = user
+
+	return &user, nil
+}
+
+// user текущее состояние пользователя для проверок
+func (f *fakeUsers) user(t *testing.T, id string) models.UserTG {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		t.Fatalf("пользователя %s нет", id)
+	}
+
+	return user
+}
+
+// fakeTransactions транзакции в памяти с теми же переходами статусов, что в DB
+type fakeTransactions struct {
+	domain.TransactionRepository
+
+	mu  sync.Mutex
+	txs map[string]models.Transaction
+	// users на баланс которых зачисляет CompleteTransaction
+	users *fakeUsers
+}
+
+// newFakeTransactions фейк с заданными транзакциями
+func newFakeTransactions(users *fakeUsers, txs ...models.Transaction) *fakeTransactions {
+	f := &fakeTransactions{txs: make(map[string]models.Transaction, len(txs)), users: users}
+	for _, tx := range txs {
+		f.txs[tx.ID] = tx
+	}
+
+	return f
+}
+
+// GetTransactionByID копия транзакции
+func (f *fakeTransactions) GetTransactionByID(id string) (*models.Transaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tx, ok := f.txs[id]
+	if !ok {
+		return nil, fmt.Errorf("транзакция %s не найдена", id)
+	}
+
+	return &tx, nil
+}
+
+// SetTransactionStatus переводит транзакцию из from в to
+func (f *fakeTransactions) SetTransactionStatus(id string, from, to domain.PaymentStatus, externalID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.move(id, []domain.PaymentStatus{from}, to, externalID), nil
+}
+
+// CompleteTransaction переводит pending или paid в success и зачисляет сумму
+func (f *fakeTransactions) CompleteTransaction(id, externalID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.move(id, []domain.PaymentStatus{domain.PaymentStatusPending, domain.PaymentStatusPaid}, domain.PaymentStatusSuccess, externalID) {
+		return false, nil
+	}
+
+	tx := f.txs[id]
+	if _, err := f.users.AddBalance(context.Background(), tx.UserID, tx.Amount); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// move меняет статус, если текущий один из from. Вызывать под mu
+func (f *fakeTransactions) move(id string, from []domain.PaymentStatus, to domain.PaymentStatus, externalID string) bool {
+	tx, ok := f.txs[id]
+	if !ok || !slices.Contains(from, domain.PaymentStatus(tx.Status)) {
+		return false
+	}
+
+	tx.Status = string(to)
+	if externalID != "" {
+		tx.ExternalID = &externalID
+	}
+	f.txs[id] = tx
+
+	return true
+}
+
+// fakeBotState ключи идемпотентности callback в памяти
+type fakeBotState struct {
+	domain.BotStateRepository
+
+	mu        sync.Mutex
+	callbacks map[string]bool
+}
+
+// MarkCallbackProcessed false, если callback уже был
+func (f *fakeBotState) MarkCallbackProcessed(callbackID, _ string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.callbacks == nil {
+		f.callbacks = make(map[string]bool)
+	}
+	if f.callbacks[callbackID] {
+		return false, nil
+	}
+	f.callbacks[callbackID] = true
+
+	return true, nil
+}
+
+// fakeSubscriptions запоминает покупки и возвращает err
+type fakeSubscriptions struct {
+	domain.SubscriptionService
+
+	err    error
+	months []int
+}
+
+// ActivateSubscription записывает срок покупки
+func (f *fakeSubscriptions) ActivateSubscription(_ context.Context, _ int64, months int) (string, error) {
+	f.months = append(f.months, months)
+	if f.err != nil {
+		return "", f.err
+	}
+
+	return "ok", nil
+}
+
+// fakeRemnawave панель с одной подпиской или без нее
+type fakeRemnawave struct {
+	domain.RemnawaveClient
+
+	// subscription подписка пользователя, nil - подписки нет
+	subscription *models.GetUserInfoResponse
+}
+
+// GetUUIDByUsername uuid подписки, ошибка если ее нет
+func (f *fakeRemnawave) GetUUIDByUsername(_ context.Context, username string) (string, error) {
+	if f.subscription == nil {
+		return "", errors.New("user not found")
+	}
+
+	return "uuid-" + username, nil
+}
+
+// GetUserInfo подписка пользователя
+func (f *fakeRemnawave) GetUserInfo(_ context.Context, _ string) (models.GetUserInfoResponse, error) {
+	return *f.subscription, nil
+}
+
+// fakeRegions регионы и выбор пользователей
+type fakeRegions struct {
+	domain.RegionService
+
+	regions []domain.Region
+	err     error
+	// selected выбранный регион по id пользователя
+	selected map[string]string
+}
+
+// Regions список регионов или err
+func (f *fakeRegions) Regions(_ context.Context) ([]domain.Region, error) {
+	return f.regions, f.err
+}
+
+// UserRegion выбранный регион, если он еще есть в списке
+func (f *fakeRegions) UserRegion(_ context.Context, userID string) (domain.Region, bool) {
+	for _, region := range f.regions {
+		if region.ID == f.selected[userID] {
+			return region, true
+		}
+	}
+
+	return domain.Region{}, false
+}
+
+// SetUserRegion запоминает выбор. ErrUnknownRegion для региона не из списка
+func (f *fakeRegions) SetUserRegion(_ context.Context, userID, regionID string) error {
+	if !slices.ContainsFunc(f.regions, func(r domain.Region) bool { return r.ID == regionID }) {
+		return domain.ErrUnknownRegion
+	}
+	if f.selected == nil {
+		f.selected = make(map[string]string)
+	}
+	f.selected[userID] = regionID
+
+	return nil
+}
+
+// fakeDevices устройства подписки
+type fakeDevices struct {
+	domain.DeviceService
+
+	info domain.DevicesInfo
+	err  error
+	// buyErr ошибка покупки устройства
+	buyErr   error
+	unlinked []string
+}
+
+// Devices устройства или err
+func (f *fakeDevices) Devices(_ context.Context, _ string) (domain.DevicesInfo, error) {
+	return f.info, f.err
+}
+
+// UnlinkDevice убирает устройство из списка. ErrDeviceNotFound если его нет
+func (f *fakeDevices) UnlinkDevice(_ context.Context, _, key string) error {
+	i := slices.IndexFunc(f.info.Devices, func(d domain.Device) bool { return d.Key() == key })
+	if i < 0 {
+		return domain.ErrDeviceNotFound
+	}
+
+	f.unlinked = append(f.unlinked, key)
+	f.info.Devices = slices.Delete(f.info.Devices, i, i+1)
+
+	return nil
+}
+
+// BuyExtraDevice увеличивает лимит на одно устройство или возвращает buyErr
+func (f *fakeDevices) BuyExtraDevice(_ context.Context, _ string) (int, error) {
+	if f.buyErr != nil {
+		return 0, f.buyErr
+	}
+
+	f.info.Extra++
+	f.info.Limit++
+
+	return f.info.Limit, nil
+}
@@ -0,0 +1,40 @@
+// Package telegrambot выбор языка пользователя для ответов бота
+package telegrambot
+
+import (
+	"context"
+	"strconv"
+
+	"ProxyMaster_v2/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// localeResolver выбирает язык пользователя: сохраненный в профиле,
+// а если его нет - по LanguageCode из телеграма
+type localeResolver struct {
+	users domain.UserRepository
+	tr    domain.Translator
+}
+
+// localizer переводчик для пользователя
+func (r localeResolver) localizer(ctx context.Context, user *tgbotapi.User) domain.Localizer {
+	dbUser, err := r.users.GetUserByID(ctx, strconv.Itoa(user.ID))
+	if err == nil && dbUser.Language != "" {
+		return r.tr.For(dbUser.Language)
+	}
+
+	return r.tr.For(r.tr.Resolve(user.LanguageCode))
+}
+
+// localizerByID переводчик для сообщений не в ответ на обновление
+// (например уведомление об оплате). Языка из телеграма тут нет,
+// поэтому без сохраненного языка берем основной
+func (r localeResolver) localizerByID(ctx context.Context, userID string) domain.Localizer {
+	dbUser, err := r.users.GetUserByID(ctx, userID)
+	if err == nil && dbUser.Language != "" {
+		return r.tr.For(dbUser.Language)
+	}
+
+	return r.tr.For(r.tr.Resolve(""))
+}
@@ -0,0 +1,41 @@
+// Package telegrambot доставка кода входа на сайт в личку с ботом
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+)
+
+// LoginCodeSender отправляет код входа на сайт в телеграм, реализует domain.LoginCodeSender
+type LoginCodeSender struct {
+	messenger telegram.Messenger
+	locales   localeResolver
+}
+
+// NewLoginCodeSender конструктор.
+func NewLoginCodeSender(messenger telegram.Messenger, users domain.UserRepository, tr domain.Translator) *LoginCodeSender {
+	return &LoginCodeSender{
+		messenger: messenger,
+		locales:   localeResolver{users: users, tr: tr},
+	}
+}
+
+// SendLoginCode пишет код пользователю to (Telegram ID). Если пользователь
+// ни разу не запускал бота, телеграм не даст ему написать
+func (s *LoginCodeSender) SendLoginCode(ctx context.Context, to, code string) error {
+	chatID, err := strconv.ParseInt(to, 10, 64)
+	if err != nil {
+		return fmt.Errorf("неверный Telegram ID %s: %w", to, err)
+	}
+
+	loc := s.locales.localizerByID(ctx, to)
+	if err := s.messenger.SendMessage(chatID, loc.T("web.login_code", code), nil); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
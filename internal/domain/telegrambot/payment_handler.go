@@ -0,0 +1,288 @@
+// Package telegrambot экран пополнения баланса и обработка оплаты:
+// выбор платежной системы из реестра, Telegram Stars (pre_checkout_query
+// и successful_payment) и Crypto Pay (webhook и ручная проверка оплаты).
+package telegrambot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/internal/payments/cryptopay"
+	"ProxyMaster_v2/internal/payments/stars"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// topUpAmounts суммы пополнения в рублях, которые предлагаем на экране
+var topUpAmounts = []int{100, 300, 500, 1000}
+
+// PaymentHandler пополнение баланса
+type PaymentHandler struct {
+	payments domain.PaymentService
+	// stars нужен для проверки оплаты звездами, nil если она выключена
+	stars *stars.Gateway
+	// messenger для уведомлений не в ответ на обновление (webhook об оплате)
+	messenger telegram.Messenger
+	locales   localeResolver
+	logger    logger.Logger
+}
+
+// NewPaymentHandler конструктор. starsGateway может быть nil
+func NewPaymentHandler(
+	payments domain.PaymentService,
+	starsGateway *stars.Gateway,
+	messenger telegram.Messenger,
+	users domain.UserRepository,
+	tr domain.Translator,
+	l logger.Logger,
+) *PaymentHandler {
+	return &PaymentHandler{
+		payments:  payments,
+		stars:     starsGateway,
+		messenger: messenger,
+		locales:   localeResolver{users: users, tr: tr},
+		logger:    l,
+	}
+}
+
+// topupBalance экран выбора платежной системы. Показываем только включенные
+func (h *PaymentHandler) topupBalance(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+
+	providers := h.payments.Providers()
+
+	text := loc.T("topup.text")
+	if len(providers) > 0 {
+		text = loc.T("topup.choose_text")
+	}
+
+	msg := update.CallbackQuery.Message
+	keyboard := telegram.NewTopupProvidersKeyboard(loc, providers)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// topupProvider экран выбора суммы: только суммы в лимитах провайдера, с его комиссией
+func (h *PaymentHandler) topupProvider(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, params telegram.CallbackParams) error {
+	provider, ok := h.payments.Provider(params["provider"])
+	if !ok {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownProvider, params["provider"])
+	}
+
+	var amounts []telegram.TopupAmount
+	for _, amount := range topUpAmounts {
+		if h.payments.Allows(provider.ID, amount) {
+			amounts = append(amounts, telegram.TopupAmount{Amount: amount, Fee: h.payments.Fee(provider.ID, amount)})
+		}
+	}
+
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	text := loc.T("topup.amount_text", provider.Name)
+	if len(amounts) == 0 {
+		text = loc.T("topup.no_amounts", provider.Name)
+	}
+
+	msg := update.CallbackQuery.Message
+	keyboard := telegram.NewTopupAmountsKeyboard(loc, provider.ID, amounts)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// topupAmount сумма из кнопки. Принимаем только те, что сами предлагали
+func topupAmount(params telegram.CallbackParams) (int, error) {
+	amount, err := params.Int("amount")
+	if err != nil {
+		return 0, fmt.Errorf("неверный формат суммы: %w", err)
+	}
+	if !slices.Contains(topUpAmounts, amount) {
+		return 0, fmt.Errorf("недопустимая сумма пополнения: %d", amount)
+	}
+
+	return amount, nil
+}
+
+// topupPay создает транзакцию и счет в выбранной платежной системе. Если
+// платежка дала ссылку, присылаем ее, иначе счет уже пришел в чат (звезды)
+func (h *PaymentHandler) topupPay(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, params telegram.CallbackParams) error {
+	amount, err := topupAmount(params)
+	if err != nil {
+		return err
+	}
+
+	userID := update.CallbackQuery.From.ID
+	tx, paymentURL, err := h.payments.CreateTopUp(ctx, params["provider"], strconv.Itoa(userID), amount)
+	if err != nil {
+		h.sendTopupError(ctx, update, messenger)
+
+		return fmt.Errorf("ошибка создания счета: %w", err)
+	}
+
+	if paymentURL == "" {
+		return nil
+	}
+
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	keyboard := telegram.NewInvoiceKeyboard(loc, paymentURL, tx.ID)
+	if err := messenger.SendMessage(int64(userID), loc.T("topup.invoice", tx.Amount+tx.Fee), &keyboard); err != nil {
+		return fmt.Errorf("ошибка отправки счета: %w", err)
+	}
+
+	return nil
+}
+
+// topupCheck ручная проверка оплаты: если уведомление от платежки не дошло,
+// пользователь может сам попросить проверить счет
+func (h *PaymentHandler) topupCheck(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, params telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	chatID := update.CallbackQuery.Message.Chat.ID
+
+	userID := strconv.Itoa(update.CallbackQuery.From.ID)
+
+	tx, credited, err := h.payments.CheckTopUp(ctx, userID, params["tx"])
+	if err != nil {
+		h.sendTopupError(ctx, update, messenger)
+
+		return fmt.Errorf("ошибка проверки оплаты: %w", err)
+	}
+
+	var text string
+	switch {
+	case credited:
+		text = loc.T("topup.success", tx.Amount)
+	case tx.Status == string(domain.PaymentStatusSuccess):
+		text = loc.T("topup.already_paid")
+	case tx.Status == string(domain.PaymentStatusPending):
+		text = loc.T("topup.pending")
+	default:
+		text = loc.T("topup.expired")
+	}
+
+	return messenger.SendMessage(chatID, text, nil)
+}
+
+// CryptoPaid обработчик webhook Crypto Pay об оплате счета
+func (h *PaymentHandler) CryptoPaid(ctx context.Context, invoice cryptopay.Invoice) error {
+	tx, credited, err := h.payments.CompleteTopUp(ctx, invoice.Payload, invoice.GetID())
+	if err != nil {
+		return fmt.Errorf("ошибка зачисления оплаты crypto pay: %w", err)
+	}
+	if credited {
+		h.NotifyTopUp(ctx, tx)
+	}
+
+	return nil
+}
+
+// PreCheckout последняя проверка перед списанием звезд. Телеграм ждет
+// ответ 10 секунд, поэтому тут только сверка с DB, без внешних вызовов
+func (h *PaymentHandler) PreCheckout(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	query := update.PreCheckoutQuery
+
+	if h.stars == nil {
+		return fmt.Errorf("pre_checkout_query при выключенной оплате звездами")
+	}
+
+	err := h.stars.CheckPreCheckout(query.InvoicePayload, query.From.ID, query.Currency, query.TotalAmount)
+	if err != nil {
+		h.logger.Warn("оплата отклонена",
+			logger.Field{Key: "user_id", Value: query.From.ID},
+			logger.Field{Key: "payload", Value: query.InvoicePayload},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		loc := h.locales.localizer(ctx, query.From)
+		if answerErr := messenger.AnswerPreCheckout(query.ID, false, loc.T("stars.precheckout_failed")); answerErr != nil {
+			return fmt.Errorf("ошибка отказа в оплате: %w", answerErr)
+		}
+
+		return nil
+	}
+
+	if err := messenger.AnswerPreCheckout(query.ID, true, ""); err != nil {
+		return fmt.Errorf("ошибка подтверждения оплаты: %w", err)
+	}
+
+	return nil
+}
+
+// SuccessfulPayment звезды списаны: зачисляем рубли на баланс
+func (h *PaymentHandler) SuccessfulPayment(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	payment := update.Message.SuccessfulPayment
+	from := update.Message.From
+
+	if h.stars == nil {
+		return fmt.Errorf("successful_payment при выключенной оплате звездами")
+	}
+
+	// Деньги уже у нас, поэтому несовпадение - повод разобраться руками,
+	// charge id в логе нужен для возврата
+	if err := h.stars.CheckPayment(payment.InvoicePayload, from.ID, payment.Currency, payment.TotalAmount); err != nil {
+		h.logger.Error("оплата не совпадает со счетом",
+			logger.Field{Key: "user_id", Value: from.ID},
+			logger.Field{Key: "payload", Value: payment.InvoicePayload},
+			logger.Field{Key: "charge_id", Value: payment.TelegramPaymentChargeID},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return err
+	}
+
+	tx, credited, err := h.payments.CompleteTopUp(ctx, payment.InvoicePayload, payment.TelegramPaymentChargeID)
+	if err != nil {
+		return fmt.Errorf("ошибка зачисления оплаты звездами: %w", err)
+	}
+	// Повторное уведомление: баланс уже пополнен, второй раз не пишем
+	if !credited {
+		return nil
+	}
+
+	loc := h.locales.localizer(ctx, from)
+	if err := messenger.SendMessage(update.Message.Chat.ID, loc.T("topup.success", tx.Amount), nil); err != nil {
+		h.logger.Error("ошибка отправки сообщения", logger.Field{Key: "error", Value: err})
+	}
+
+	return nil
+}
+
+// NotifyTopUp сообщает пользователю о зачислении. Ошибку только логируем:
+// деньги уже на балансе, а уведомление не повод заставлять платежку повторять webhook
+func (h *PaymentHandler) NotifyTopUp(ctx context.Context, tx *models.Transaction) {
+	// Пользователи сайта без телеграма узнают об оплате на странице заказа
+	chatID, err := strconv.ParseInt(tx.UserID, 10, 64)
+	if err != nil {
+		return
+	}
+
+	loc := h.locales.localizerByID(ctx, tx.UserID)
+	if err := h.messenger.SendMessage(chatID, loc.T("topup.success", tx.Amount), nil); err != nil {
+		h.logger.Error("ошибка отправки сообщения", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// sendTopupError сообщает пользователю, что со счетом что-то пошло не так
+func (h *PaymentHandler) sendTopupError(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	if err := messenger.SendMessage(update.CallbackQuery.Message.Chat.ID, loc.T("topup.error"), nil); err != nil {
+		h.logger.Error("ошибка отправки сообщения", logger.Field{Key: "error", Value: err})
+	}
+}
+
+// Register регистрирует экраны пополнения в роутере кнопок
+func (h *PaymentHandler) Register(router *telegram.CallbackRouter) {
+	router.Register(telegram.RouteTopupBalance, h.topupBalance)
+	router.Register(telegram.RouteTopupProvider, h.topupProvider)
+	router.Register(telegram.RouteTopupPay, h.topupPay)
+	router.Register(telegram.RouteTopupCheck, h.topupCheck)
+}
@@ -0,0 +1,276 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/delivery/telegram/telegramtest"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/internal/payments/stars"
+	"ProxyMaster_v2/internal/service"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Счет в звездах в тестах: пополнение на 300 ₽ при курсе 2 ₽ за звезду
+const (
+	testTxID       = "tx-1"
+	testTopUp      = 300
+	testRubPerStar = 2
+	testStars      = testTopUp / testRubPerStar
+	testChargeID   = "charge-1"
+)
+
+// paymentEnv PaymentHandler с настоящими PaymentService и stars.Gateway поверх фейков
+type paymentEnv struct {
+	users        *fakeUsers
+	transactions *fakeTransactions
+	messenger    *telegramtest.Messenger
+	handler      *PaymentHandler
+}
+
+// newPaymentEnv пользователь testUserID с нулевым балансом и неоплаченным счетом testTxID
+func newPaymentEnv(t *testing.T, status domain.PaymentStatus) *paymentEnv {
+	t.Helper()
+
+	l := newTestLogger(t)
+	tr := newTestTranslator(t)
+
+	e := &paymentEnv{
+		users:     newFakeUsers(models.UserTG{ID: strconv.Itoa(testUserID)}),
+		messenger: telegramtest.NewMessenger(),
+	}
+	e.transactions = newFakeTransactions(e.users, models.Transaction{
+		ID:       testTxID,
+		UserID:   strconv.Itoa(testUserID),
+		Amount:   testTopUp,
+		Status:   string(status),
+		Provider: stars.Provider,
+	})
+
+	gateway, err := stars.NewGateway(e.messenger, e.transactions, e.users, tr, testRubPerStar, l)
+	if err != nil {
+		t.Fatalf("stars.NewGateway: %v", err)
+	}
+	payments := service.NewPaymentService(e.transactions, e.users, nil, l)
+	e.handler = NewPaymentHandler(payments, gateway, e.messenger, e.users, tr, l)
+
+	return e
+}
+
+// successfulPayment обновление об оплате счета payload
+func successfulPayment(payload string, totalAmount int) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: testUserID, LanguageCode: "ru"},
+			Chat: &tgbotapi.Chat{ID: testUserID},
+			SuccessfulPayment: &tgbotapi.SuccessfulPayment{
+				Currency:                stars.Currency,
+				TotalAmount:             totalAmount,
+				InvoicePayload:          payload,
+				TelegramPaymentChargeID: testChargeID,
+			},
+		},
+	}
+}
+
+// balance баланс testUserID
+func (e *paymentEnv) balance(t *testing.T) int {
+	t.Helper()
+
+	return e.users.user(t, strconv.Itoa(testUserID)).Balance
+}
+
+// status статус счета testTxID
+func (e *paymentEnv) status(t *testing.T) string {
+	t.Helper()
+
+	tx, err := e.transactions.GetTransactionByID(testTxID)
+	if err != nil {
+		t.Fatalf("GetTransactionByID: %v", err)
+	}
+
+	return tx.Status
+}
+
+func TestSuccessfulPaymentCredits(t *testing.T) {
+	e := newPaymentEnv(t, domain.PaymentStatusPending)
+	update := successfulPayment(testTxID, testStars)
+
+	if err := e.handler.SuccessfulPayment(context.Background(), update, e.messenger); err != nil {
+		t.Fatalf("SuccessfulPayment: %v", err)
+	}
+
+	if got := e.balance(t); got != testTopUp {
+		t.Errorf("баланс %d, ожидали %d", got, testTopUp)
+	}
+	if got := e.status(t); got != string(domain.PaymentStatusSuccess) {
+		t.Errorf("статус %s, ожидали success", got)
+	}
+	tx, _ := e.transactions.GetTransactionByID(testTxID)
+	if tx.ExternalID == nil || *tx.ExternalID != testChargeID {
+		t.Errorf("id платежа %v, ожидали %s: без него звезды не вернуть", tx.ExternalID, testChargeID)
+	}
+
+	want := newTestTranslator(t).For("ru").T("topup.success", testTopUp)
+	calls := e.messenger.Calls()
+	if len(calls) != 1 || calls[0].Method != telegramtest.MethodSendMessage || calls[0].ChatID != testUserID || calls[0].Text != want {
+		t.Fatalf("вызовы %+v, ожидали одно сообщение %q", calls, want)
+	}
+
+	// Телеграм прислал то же successful_payment еще раз: второй раз не зачисляем и не пишем
+	e.messenger.Reset()
+	if err := e.handler.SuccessfulPayment(context.Background(), update, e.messenger); err != nil {
+		t.Fatalf("повторный SuccessfulPayment: %v", err)
+	}
+
+	if got := e.balance(t); got != testTopUp {
+		t.Errorf("после повтора баланс %d, ожидали %d", got, testTopUp)
+	}
+	if calls := e.messenger.Calls(); len(calls) != 0 {
+		t.Errorf("после повтора отправлено %+v", calls)
+	}
+}
+
+func TestSuccessfulPaymentMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		totalAmount int
+	}{
+		{name: "сумма не совпадает со счетом", payload: testTxID, totalAmount: testStars - 1},
+		{name: "неизвестный счет", payload: "tx-unknown", totalAmount: testStars},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newPaymentEnv(t, domain.PaymentStatusPending)
+
+			err := e.handler.SuccessfulPayment(context.Background(), successfulPayment(tt.payload, tt.totalAmount), e.messenger)
+			if !errors.Is(err, stars.ErrInvalidPayment) {
+				t.Fatalf("SuccessfulPayment: %v, ожидали ErrInvalidPayment", err)
+			}
+
+			if got := e.balance(t); got != 0 {
+				t.Errorf("зачислено %d", got)
+			}
+			if got := e.status(t); got != string(domain.PaymentStatusPending) {
+				t.Errorf("статус %s, ожидали pending", got)
+			}
+			if calls := e.messenger.Calls(); len(calls) != 0 {
+				t.Errorf("отправлено %+v", calls)
+			}
+		})
+	}
+}
+
+func TestPreCheckout(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      domain.PaymentStatus
+		payload     string
+		userID      int
+		currency    string
+		totalAmount int
+		wantOK      bool
+	}{
+		{name: "счет совпадает", wantOK: true},
+		{name: "другая сумма", totalAmount: testStars + 1},
+		{name: "другая валюта", currency: "USD"},
+		{name: "чужой счет", userID: testUserID + 1},
+		{name: "неизвестный счет", payload: "tx-unknown"},
+		{name: "счет уже оплачен", status: domain.PaymentStatusSuccess},
+		{name: "счет истек", status: domain.PaymentStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := domain.PaymentStatusPending
+			if tt.status != "" {
+				status = tt.status
+			}
+			e := newPaymentEnv(t, status)
+
+			query := &tgbotapi.PreCheckoutQuery{
+				ID:             "query-1",
+				From:           &tgbotapi.User{ID: testUserID, LanguageCode: "ru"},
+				Currency:       stars.Currency,
+				TotalAmount:    testStars,
+				InvoicePayload: testTxID,
+			}
+			if tt.payload != "" {
+				query.InvoicePayload = tt.payload
+			}
+			if tt.userID != 0 {
+				query.From.ID = tt.userID
+			}
+			if tt.currency != "" {
+				query.Currency = tt.currency
+			}
+			if tt.totalAmount != 0 {
+				query.TotalAmount = tt.totalAmount
+			}
+
+			err := e.handler.PreCheckout(context.Background(), tgbotapi.Update{PreCheckoutQuery: query}, e.messenger)
+			if err != nil {
+				t.Fatalf("PreCheckout: %v", err)
+			}
+
+			calls := e.messenger.Calls()
+			if len(calls) != 1 || calls[0].Method != telegramtest.MethodAnswerPreCheckout || calls[0].QueryID != "query-1" {
+				t.Fatalf("вызовы %+v, ожидали один ответ на query-1", calls)
+			}
+			if calls[0].OK != tt.wantOK {
+				t.Errorf("ответ ok=%v, ожидали %v", calls[0].OK, tt.wantOK)
+			}
+			if !tt.wantOK && calls[0].Text != newTestTranslator(t).For("ru").T("stars.precheckout_failed") {
+				t.Errorf("текст отказа %q", calls[0].Text)
+			}
+
+			// Проверка перед оплатой ничего не зачисляет
+			if got := e.balance(t); got != 0 {
+				t.Errorf("зачислено %d", got)
+			}
+			if got := e.status(t); got != string(status) {
+				t.Errorf("статус %s, ожидали %s", got, status)
+			}
+		})
+	}
+}
+
+func TestTopupCheckOwnership(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   int
+		wantErr  error
+		wantText string
+	}{
+		{name: "свой счет", userID: testUserID, wantText: "topup.already_paid"},
+		{name: "чужой счет", userID: testUserID + 1, wantErr: domain.ErrTransactionNotFound, wantText: "topup.error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newPaymentEnv(t, domain.PaymentStatusSuccess)
+			update := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+				From:    &tgbotapi.User{ID: tt.userID, LanguageCode: "ru"},
+				Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: int64(tt.userID)}},
+			}}
+
+			err := e.handler.topupCheck(context.Background(), update, e.messenger, telegram.CallbackParams{"tx": testTxID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("topupCheck: %v, ожидали %v", err, tt.wantErr)
+			}
+
+			want := newTestTranslator(t).For("ru").T(tt.wantText)
+			calls := e.messenger.Calls()
+			if len(calls) != 1 || calls[0].ChatID != int64(tt.userID) || calls[0].Text != want {
+				t.Fatalf("вызовы %+v, ожидали одно сообщение %q", calls, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package telegrambot экраны выбора региона подключения: перед первой
+// покупкой и из личного кабинета.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// regionsScreen список регионов с отметкой выбранного
+func (h *CallbackHandler) regionsScreen(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	regions, err := h.regions.Regions(ctx)
+	if err != nil || len(regions) == 0 {
+		logger.FromContext(ctx, h.logger).Warn("регионы недоступны", logger.Field{Key: "error", Value: err})
+
+		keyboard := telegram.NewBackToMenuKeyboard(loc)
+		if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, loc.T("region.unavailable"), &keyboard); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		return nil
+	}
+
+	current, _ := h.regions.UserRegion(ctx, strconv.Itoa(update.CallbackQuery.From.ID))
+
+	return h.showRegions(messenger, msg, loc, regions, current.ID, params["next"])
+}
+
+// showRegions рисует выбор региона в сообщении msg
+func (h *CallbackHandler) showRegions(
+	messenger telegram.Messenger,
+	msg *tgbotapi.Message,
+	loc domain.Localizer,
+	regions []domain.Region,
+	current, next string,
+) error {
+	keyboard := telegram.NewRegionsKeyboard(loc, regions, current, next)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, loc.T("region.title"), &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// setRegion сохраняет регион и возвращает на экран, с которого пришли
+func (h *CallbackHandler) setRegion(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	userID := update.CallbackQuery.From.ID
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+
+	err := h.regions.SetUserRegion(ctx, strconv.Itoa(userID), params["region"])
+	switch {
+	case errors.Is(err, domain.ErrUnknownRegion):
+		// Кнопка из старого списка, сквада уже нет: показываем актуальный
+		return h.regionsScreen(ctx, update, messenger, params)
+	case err != nil:
+		logger.FromContext(ctx, h.logger).Error("ошибка смены региона", logger.Field{Key: "error", Value: err})
+
+		if err := messenger.SendMessage(int64(userID), loc.T("region.error", h.settings.Support()), nil); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		return nil
+	}
+
+	if params["next"] == telegram.RouteTariffs.Name() {
+		return h.tariffs(ctx, update, messenger, params)
+	}
+
+	return h.profile(ctx, update, messenger, params)
+}
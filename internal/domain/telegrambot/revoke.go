@@ -0,0 +1,88 @@
+// Package telegrambot перевыпуск ссылки подписки с подтверждением.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// RevokeHandler перевыпуск ссылки подписки
+type RevokeHandler struct {
+	links domain.LinkService
+	// settings ссылка на поддержку
+	settings domain.Settings
+	locales  localeResolver
+	logger   logger.Logger
+}
+
+// NewRevokeHandler конструктор
+func NewRevokeHandler(
+	links domain.LinkService,
+	settings domain.Settings,
+	users domain.UserRepository,
+	tr domain.Translator,
+	l logger.Logger,
+) *RevokeHandler {
+	return &RevokeHandler{
+		links:    links,
+		settings: settings,
+		locales:  localeResolver{users: users, tr: tr},
+		logger:   l,
+	}
+}
+
+// revokePrompt первый шаг: объясняем последствия и спрашиваем подтверждение
+func (h *RevokeHandler) revokePrompt(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	keyboard := telegram.NewRevokeKeyboard(loc)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, loc.T("revoke.confirm"), &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// revokeLink второй шаг: перевыпускаем ссылку и присылаем новую
+func (h *RevokeHandler) revokeLink(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	url, wait, err := h.links.RevokeLink(ctx, strconv.Itoa(update.CallbackQuery.From.ID))
+
+	text := loc.T("revoke.success", url)
+	keyboard := telegram.NewMainMenuKeyboard(loc, h.settings.Support(), url, 0)
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrRevokeTooSoon):
+		text, keyboard = loc.T("revoke.too_soon", int(math.Ceil(wait.Minutes()))), telegram.NewBackToMenuKeyboard(loc)
+	case errors.Is(err, domain.ErrNoSubscription):
+		text, keyboard = loc.T("revoke.no_subscription"), telegram.NewBackToMenuKeyboard(loc)
+	default:
+		logger.FromContext(ctx, h.logger).Error("ошибка перевыпуска ссылки", logger.Field{Key: "error", Value: err})
+		text, keyboard = loc.T("revoke.error", h.settings.Support()), telegram.NewBackToMenuKeyboard(loc)
+	}
+
+	// Убираем подтверждение, чтобы его нельзя было нажать второй раз
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// Register регистрирует экраны перевыпуска в роутере кнопок
+func (h *RevokeHandler) Register(router *telegram.CallbackRouter) {
+	router.Register(telegram.RouteRevoke, h.revokePrompt)
+	router.Register(telegram.RouteRevokeConfirm, h.revokeLink)
+}
@@ -0,0 +1,181 @@
+// Package telegrambot команды админа для настроек без рестарта:
+// /settings показывает значения, /set меняет или сбрасывает,
+// /maintenance переключает режим техработ
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// settingsUpdatedLayout формат даты изменения настройки
+const settingsUpdatedLayout = "02.01.2006 15:04"
+
+// SettingsCommand это /settings: список настроек. Доступ только админам
+type SettingsCommand struct {
+	settings domain.SettingsService
+	tr       domain.Translator
+}
+
+// NewSettingsCommand конструктор.
+func NewSettingsCommand(settings domain.SettingsService, tr domain.Translator) *SettingsCommand {
+	return &SettingsCommand{settings: settings, tr: tr}
+}
+
+// Name возвращаем /settings
+func (c *SettingsCommand) Name() string {
+	return "settings"
+}
+
+// Execute отправляет админу текущие значения
+func (c *SettingsCommand) Execute(_ context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := c.tr.For(c.tr.Resolve(update.Message.From.LanguageCode))
+
+	var text strings.Builder
+	text.WriteString(loc.T("admin.settings_title"))
+	for _, s := range c.settings.List() {
+		value := s.Value
+		if value == "" {
+			value = loc.T("admin.settings_empty")
+		}
+
+		text.WriteString("\n\n")
+		if s.Overridden {
+			text.WriteString(loc.T("admin.settings_overridden", s.Key, value, s.UpdatedBy, s.UpdatedAt.Format(settingsUpdatedLayout)))
+		} else {
+			text.WriteString(loc.T("admin.settings_default", s.Key, value))
+		}
+	}
+	text.WriteString("\n\n")
+	text.WriteString(loc.T("admin.set_usage"))
+
+	return messenger.SendMessage(update.Message.Chat.ID, text.String(), nil)
+}
+
+// SetCommand это /set <ключ> [значение]: меняет настройку, без значения -
+// сбрасывает на значение по умолчанию. Доступ только админам
+type SetCommand struct {
+	settings domain.SettingsService
+	tr       domain.Translator
+	logger   logger.Logger
+}
+
+// NewSetCommand конструктор.
+func NewSetCommand(settings domain.SettingsService, tr domain.Translator, l logger.Logger) *SetCommand {
+	return &SetCommand{settings: settings, tr: tr, logger: l}
+}
+
+// Name возвращаем /set
+func (c *SetCommand) Name() string {
+	return "set"
+}
+
+// Execute меняет настройку и сообщает админу результат
+func (c *SetCommand) Execute(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := c.tr.For(c.tr.Resolve(update.Message.From.LanguageCode))
+	chatID := update.Message.Chat.ID
+	actor := strconv.Itoa(update.Message.From.ID)
+
+	// Значение - все после ключа: сообщение о техработах может быть с пробелами
+	args := strings.TrimSpace(update.Message.CommandArguments())
+	key, value, _ := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	if key == "" {
+		return messenger.SendMessage(chatID, loc.T("admin.set_usage"), nil)
+	}
+
+	var err error
+	if value == "" {
+		err = c.settings.Reset(ctx, key, actor)
+	} else {
+		err = c.settings.Set(ctx, key, value, actor)
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrUnknownSetting):
+		return messenger.SendMessage(chatID, loc.T("admin.set_unknown", key), nil)
+	case errors.Is(err, domain.ErrInvalidSetting):
+		return messenger.SendMessage(chatID, loc.T("admin.set_invalid", err.Error()), nil)
+	case err != nil:
+		logger.FromContext(ctx, c.logger).Error("настройка не изменена",
+			logger.Field{Key: "key", Value: key},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return messenger.SendMessage(chatID, loc.T("admin.set_failed"), nil)
+	case value == "":
+		return messenger.SendMessage(chatID, loc.T("admin.set_reset", key), nil)
+	default:
+		return messenger.SendMessage(chatID, loc.T("admin.set_done", key, value), nil)
+	}
+}
+
+// MaintenanceCommand это /maintenance on [текст] | off: режим техработ.
+// Без аргументов показывает, включен ли он. Доступ только админам
+type MaintenanceCommand struct {
+	settings domain.SettingsService
+	tr       domain.Translator
+	logger   logger.Logger
+}
+
+// NewMaintenanceCommand конструктор.
+func NewMaintenanceCommand(settings domain.SettingsService, tr domain.Translator, l logger.Logger) *MaintenanceCommand {
+	return &MaintenanceCommand{settings: settings, tr: tr, logger: l}
+}
+
+// Name возвращаем /maintenance
+func (c *MaintenanceCommand) Name() string {
+	return "maintenance"
+}
+
+// Execute включает или выключает техработы. Текст после on заменяет
+// уведомление для пользователей
+func (c *MaintenanceCommand) Execute(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger) error {
+	loc := c.tr.For(c.tr.Resolve(update.Message.From.LanguageCode))
+	chatID := update.Message.Chat.ID
+	actor := strconv.Itoa(update.Message.From.ID)
+
+	mode, message, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	message = strings.TrimSpace(message)
+
+	var err error
+	switch mode {
+	case "":
+		status := loc.T("admin.maintenance_off")
+		if c.settings.Maintenance() {
+			status = loc.T("admin.maintenance_on")
+		}
+
+		return messenger.SendMessage(chatID, status+"\n\n"+loc.T("admin.maintenance_usage"), nil)
+	case "on":
+		if message != "" {
+			err = c.settings.Set(ctx, domain.SettingMaintenanceMessage, message, actor)
+		}
+		if err == nil {
+			err = c.settings.Set(ctx, domain.SettingMaintenance, "on", actor)
+		}
+	case "off":
+		err = c.settings.Set(ctx, domain.SettingMaintenance, "off", actor)
+	default:
+		return messenger.SendMessage(chatID, loc.T("admin.maintenance_usage"), nil)
+	}
+
+	if err != nil {
+		logger.FromContext(ctx, c.logger).Error("режим техработ не переключен", logger.Field{Key: "error", Value: err})
+
+		return messenger.SendMessage(chatID, loc.T("admin.set_failed"), nil)
+	}
+	if mode == "on" {
+		return messenger.SendMessage(chatID, loc.T("admin.maintenance_on"), nil)
+	}
+
+	return messenger.SendMessage(chatID, loc.T("admin.maintenance_off"), nil)
+}
@@ -0,0 +1,173 @@
+// Package telegrambot экран трафика, покупка пакетов и предупреждения
+// о заканчивающемся трафике.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// TrafficHandler трафик подписки
+type TrafficHandler struct {
+	traffic domain.TrafficService
+	// settings ссылка на поддержку
+	settings domain.Settings
+	// botState хранит ключи идемпотентности покупок
+	botState domain.BotStateRepository
+	// messenger для предупреждений не в ответ на обновление
+	messenger telegram.Messenger
+	locales   localeResolver
+	logger    logger.Logger
+}
+
+// NewTrafficHandler конструктор
+func NewTrafficHandler(
+	traffic domain.TrafficService,
+	settings domain.Settings,
+	botState domain.BotStateRepository,
+	messenger telegram.Messenger,
+	users domain.UserRepository,
+	tr domain.Translator,
+	l logger.Logger,
+) *TrafficHandler {
+	return &TrafficHandler{
+		traffic:   traffic,
+		settings:  settings,
+		botState:  botState,
+		messenger: messenger,
+		locales:   localeResolver{users: users, tr: tr},
+		logger:    l,
+	}
+}
+
+// trafficScreen израсходованный трафик и пакеты
+func (h *TrafficHandler) trafficScreen(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	info, err := h.traffic.Traffic(ctx, strconv.Itoa(update.CallbackQuery.From.ID))
+
+	var text string
+	keyboard := telegram.NewBackToMenuKeyboard(loc)
+	switch {
+	case errors.Is(err, domain.ErrNoSubscription):
+		text = loc.T("traffic.no_subscription")
+	case err != nil:
+		logger.FromContext(ctx, h.logger).Error("ошибка получения трафика", logger.Field{Key: "error", Value: err})
+		text = loc.T("traffic.error")
+	case info.Limit == 0:
+		text = loc.T("traffic.unlimited", formatGB(info.Used))
+	default:
+		text = loc.T("traffic.text", formatGB(info.Used), formatGB(info.Limit))
+		keyboard = telegram.NewTrafficKeyboard(loc, h.traffic.Packs())
+	}
+
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// buyPack покупка пакета трафика с баланса
+func (h *TrafficHandler) buyPack(
+	ctx context.Context,
+	update tgbotapi.Update,
+	messenger telegram.Messenger,
+	params telegram.CallbackParams,
+) error {
+	userID := update.CallbackQuery.From.ID
+	callbackID := update.CallbackQuery.ID
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+
+	gb, err := params.Int("gb")
+	if err != nil {
+		return fmt.Errorf("неверный размер пакета: %w", err)
+	}
+
+	// Повторный callback не списывает деньги второй раз
+	first, err := h.botState.MarkCallbackProcessed(callbackID, strconv.Itoa(userID))
+	if err != nil {
+		return fmt.Errorf("ошибка проверки ключа идемпотентности: %w", err)
+	}
+	if !first {
+		logger.FromContext(ctx, h.logger).Warn("повторный callback покупки трафика пропущен",
+			logger.Field{Key: "callback_id", Value: callbackID},
+		)
+
+		return nil
+	}
+
+	info, err := h.traffic.BuyPack(ctx, strconv.Itoa(userID), gb)
+
+	var text string
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	switch {
+	case err == nil:
+		text = loc.T("traffic.buy_success", formatGB(info.Limit))
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		profile := telegram.NewProfileKeyboard(loc)
+		text, keyboard = loc.T("purchase.insufficient_funds"), &profile
+	case errors.Is(err, domain.ErrNoSubscription):
+		text = loc.T("traffic.no_subscription")
+	case errors.Is(err, domain.ErrUnlimitedTraffic):
+		text = loc.T("traffic.unlimited", formatGB(info.Used))
+	case errors.Is(err, domain.ErrNotRefunded):
+		logger.FromContext(ctx, h.logger).Error("трафик оплачен, но не добавлен", logger.Field{Key: "error", Value: err})
+		text = loc.T("traffic.not_refunded", h.settings.Support())
+	default:
+		logger.FromContext(ctx, h.logger).Error("ошибка покупки трафика", logger.Field{Key: "error", Value: err})
+		text = loc.T("traffic.buy_error", h.settings.Support())
+	}
+
+	if sendErr := messenger.SendMessage(int64(userID), text, keyboard); sendErr != nil {
+		return fmt.Errorf("failed to send message: %w", sendErr)
+	}
+	// Лимит не изменился, экран обновлять незачем
+	if err != nil {
+		return nil
+	}
+
+	return h.trafficScreen(ctx, update, messenger, params)
+}
+
+// NotifyTraffic предупреждение о трафике. Подходит для TrafficService.SetNotifier
+func (h *TrafficHandler) NotifyTraffic(ctx context.Context, userID string, info domain.TrafficInfo, threshold int) error {
+	// Пользователям сайта без телеграма написать некуда
+	chatID, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	loc := h.locales.localizerByID(ctx, userID)
+	text := loc.T("traffic.warning", threshold, formatGB(max(info.Limit-info.Used, 0)))
+	if threshold >= 100 {
+		text = loc.T("traffic.exhausted")
+	}
+
+	keyboard := telegram.NewTrafficKeyboard(loc, h.traffic.Packs())
+	if err := h.messenger.SendMessage(chatID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// Register регистрирует экраны трафика в роутере кнопок
+func (h *TrafficHandler) Register(router *telegram.CallbackRouter) {
+	router.Register(telegram.RouteTraffic, h.trafficScreen)
+	router.Register(telegram.RouteTrafficBuy, h.buyPack)
+}
+
+// formatGB байты в гигабайтах с одним знаком после запятой
+func formatGB(bytes int64) string {
+	return strconv.FormatFloat(float64(bytes)/float64(domain.GB), 'f', 1, 64)
+}
@@ -0,0 +1,74 @@
+// Package telegrambot кнопка пробного периода в главном меню.
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// TrialHandler выдача пробного периода
+type TrialHandler struct {
+	trial domain.TrialService
+	// settings ссылка на поддержку
+	settings domain.Settings
+	locales  localeResolver
+	logger   logger.Logger
+}
+
+// NewTrialHandler конструктор
+func NewTrialHandler(
+	trial domain.TrialService,
+	settings domain.Settings,
+	users domain.UserRepository,
+	tr domain.Translator,
+	l logger.Logger,
+) *TrialHandler {
+	return &TrialHandler{
+		trial:    trial,
+		settings: settings,
+		locales:  localeResolver{users: users, tr: tr},
+		logger:   l,
+	}
+}
+
+// activateTrial выдает пробный период. Повторное нажатие безопасно:
+// ActivateTrial второй раз вернет ErrTrialUsed
+func (h *TrialHandler) activateTrial(ctx context.Context, update tgbotapi.Update, messenger telegram.Messenger, _ telegram.CallbackParams) error {
+	loc := h.locales.localizer(ctx, update.CallbackQuery.From)
+	msg := update.CallbackQuery.Message
+
+	days, err := h.trial.ActivateTrial(ctx, int64(update.CallbackQuery.From.ID))
+
+	var text string
+	switch {
+	case err == nil:
+		text = loc.T("trial.success", days)
+	case errors.Is(err, domain.ErrTrialUsed):
+		text = loc.T("trial.used")
+	case errors.Is(err, domain.ErrTrialDisabled):
+		text = loc.T("trial.disabled")
+	default:
+		logger.FromContext(ctx, h.logger).Error("ошибка выдачи пробного периода", logger.Field{Key: "error", Value: err})
+		text = loc.T("trial.error", h.settings.Support())
+	}
+
+	// Убираем кнопку, чтобы пробный период не нажимали второй раз
+	keyboard := telegram.NewBackToMenuKeyboard(loc)
+	if err := messenger.EditMessage(msg.Chat.ID, msg.MessageID, text, &keyboard); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// Register регистрирует кнопку пробного периода в роутере кнопок
+func (h *TrialHandler) Register(router *telegram.CallbackRouter) {
+	router.Register(telegram.RouteTrial, h.activateTrial)
+}
@@ -0,0 +1,73 @@
+// Package domain трафик подписки: лимит тарифа, докупаемые пакеты и
+// предупреждения, когда трафик подходит к концу.
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// GB байт в гигабайте, панель считает трафик в байтах
+const GB int64 = 1024 * 1024 * 1024
+
+// Стратегии сброса трафика в панели
+const (
+	TrafficResetNever = "NO_RESET"
+	TrafficResetDay   = "DAY"
+	TrafficResetWeek  = "WEEK"
+	TrafficResetMonth = "MONTH"
+)
+
+const (
+	// TrafficLimitGB лимит трафика тарифа по умолчанию (настройка traffic_limit_gb)
+	TrafficLimitGB = 100
+	// PricePerGB цена гигабайта в пакетах по умолчанию (настройка price_per_gb)
+	PricePerGB = 2
+)
+
+// TrafficPacksGB размеры пакетов трафика, которые можно купить
+var TrafficPacksGB = []int{10, 50, 100}
+
+// TrafficThresholds при каком проценте израсходованного трафика предупреждаем
+var TrafficThresholds = []int{80, 100}
+
+// ErrUnlimitedTraffic у подписки безлимитный трафик, пакет не нужен
+var ErrUnlimitedTraffic = errors.New("unlimited traffic")
+
+// TrafficInfo трафик пользователя в байтах
+type TrafficInfo struct {
+	Used int64
+	// Limit 0 - безлимит
+	Limit int64
+}
+
+// Percent сколько процентов лимита израсходовано. У безлимита всегда 0
+func (t TrafficInfo) Percent() int {
+	if t.Limit <= 0 {
+		return 0
+	}
+
+	return int(t.Used * 100 / t.Limit)
+}
+
+// TrafficPack пакет трафика и его цена
+type TrafficPack struct {
+	GB int
+	// Price цена в рублях
+	Price int
+}
+
+// TrafficService трафик подписки и покупка пакетов
+type TrafficService interface {
+	// Traffic израсходованный трафик и лимит. ErrNoSubscription если подписки нет
+	Traffic(ctx context.Context, userID string) (TrafficInfo, error)
+	// Packs пакеты с текущими ценами
+	Packs() []TrafficPack
+	// BuyPack списывает цену пакета gb с баланса и поднимает лимит до сброса трафика.
+	// ErrInsufficientFunds, ErrUnlimitedTraffic или ErrNoSubscription, если купить нельзя
+	BuyPack(ctx context.Context, userID string, gb int) (TrafficInfo, error)
+}
+
+// TrafficNotifyFunc отправляет пользователю предупреждение: израсходовано
+// threshold процентов трафика (одно из TrafficThresholds)
+type TrafficNotifyFunc func(ctx context.Context, userID string, info TrafficInfo, threshold int) error
@@ -0,0 +1,269 @@
+// Package content шаблоны редактируемых текстов бота. Каждая страница - файл
+// <страница>.<язык>.<формат> (например welcome.ru.html). Формат задает режим
+// разметки телеграма: .html - HTML, .md - Markdown, .txt - обычный текст.
+// Тексты пишутся на Go text/template, переменные - domain.ContentData.
+package content
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"ProxyMaster_v2/internal/domain"
+)
+
+// defaultLanguage язык, на котором обязаны быть все страницы
+const defaultLanguage = "ru"
+
+// Режимы разметки телеграма
+const (
+	ParseModeHTML     = "HTML"
+	ParseModeMarkdown = "Markdown"
+)
+
+// requiredPages страницы, без которых бот не запустится
+var requiredPages = []string{domain.PageWelcome, domain.PageInfo, domain.PageAgreement}
+
+// parseModes расширение файла -> режим разметки
+var parseModes = map[string]string{
+	".html": ParseModeHTML,
+	".md":   ParseModeMarkdown,
+	".txt":  "",
+}
+
+// allowedHTMLTags теги, которые понимает телеграм
+var allowedHTMLTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "a": true, "code": true, "pre": true,
+	"tg-spoiler": true, "span": true, "blockquote": true,
+}
+
+// htmlTagRe находит открывающие и закрывающие теги
+var htmlTagRe = regexp.MustCompile(`<(/?)([a-zA-Z-]+)[^>]*>`)
+
+// sampleData данные для пробного рендера при загрузке
+var sampleData = domain.ContentData{
+	UserID:          123456789,
+	FirstName:       "Иван",
+	Balance:         100,
+	Support:         "https://t.me/support",
+	SubscriptionURL: "https://example.com/sub/abc",
+	ExpireAt:        "01.01.2030",
+	HasSubscription: true,
+}
+
+//go:embed pages/*
+var embedded embed.FS
+
+// page один загруженный шаблон
+type page struct {
+	tmpl      *template.Template
+	parseMode string
+}
+
+// Store хранилище шаблонов, реализует domain.ContentStore
+type Store struct {
+	fsys fs.FS
+
+	mu sync.RWMutex
+	// pages ключ "<страница>.<язык>"
+	pages map[string]page
+}
+
+// NewEmbeddedStore хранилище со вшитыми в бинарник шаблонами
+func NewEmbeddedStore() (*Store, error) {
+	sub, err := fs.Sub(embedded, "pages")
+	if err != nil {
+		return nil, fmt.Errorf("content: ошибка чтения вшитых шаблонов: %w", err)
+	}
+
+	return NewStore(sub)
+}
+
+// NewStore хранилище шаблонов из fsys. Шаблоны сразу загружаются и проверяются
+func NewStore(fsys fs.FS) (*Store, error) {
+	s := &Store{fsys: fsys}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload перечитывает шаблоны. Если хоть один с ошибкой - остаются старые
+func (s *Store) Reload() error {
+	pages, err := load(s.fsys)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.pages = pages
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Render собирает страницу. Если на языке lang страницы нет - берем основной язык
+func (s *Store) Render(name, lang string, data domain.ContentData) (domain.RenderedContent, error) {
+	s.mu.RLock()
+	p, ok := s.pages[name+"."+lang]
+	if !ok {
+		p, ok = s.pages[name+"."+defaultLanguage]
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		return domain.RenderedContent{}, fmt.Errorf("content: страница %s не найдена", name)
+	}
+
+	text, err := p.render(data)
+	if err != nil {
+		return domain.RenderedContent{}, err
+	}
+
+	return domain.RenderedContent{Text: text, ParseMode: p.parseMode}, nil
+}
+
+// render выполняет шаблон
+func (p page) render(data domain.ContentData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("content: ошибка рендера %s: %w", p.tmpl.Name(), err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// load читает и проверяет все шаблоны. Ошибки собираются все сразу,
+// чтобы редактор увидел их одним списком
+func load(fsys fs.FS) (map[string]page, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("content: ошибка чтения папки шаблонов: %w", err)
+	}
+
+	pages := make(map[string]page, len(entries))
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		key, p, err := loadPage(fsys, entry.Name())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pages[key] = p
+	}
+
+	for _, name := range requiredPages {
+		if _, ok := pages[name+"."+defaultLanguage]; !ok {
+			errs = append(errs, fmt.Errorf("content: нет обязательной страницы %s.%s", name, defaultLanguage))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return pages, nil
+}
+
+// loadPage читает один файл <страница>.<язык>.<формат>
+func loadPage(fsys fs.FS, fileName string) (string, page, error) {
+	ext := path.Ext(fileName)
+	parseMode, ok := parseModes[ext]
+	if !ok {
+		return "", page{}, fmt.Errorf("content: %s: неизвестный формат %s", fileName, ext)
+	}
+
+	key := strings.TrimSuffix(fileName, ext)
+	if strings.Count(key, ".") != 1 {
+		return "", page{}, fmt.Errorf("content: %s: имя должно быть вида страница.язык%s", fileName, ext)
+	}
+
+	raw, err := fs.ReadFile(fsys, fileName)
+	if err != nil {
+		return "", page{}, fmt.Errorf("content: ошибка чтения %s: %w", fileName, err)
+	}
+
+	// missingkey=error: опечатка в имени переменной - ошибка при загрузке, а не пустое место
+	tmpl, err := template.New(fileName).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return "", page{}, fmt.Errorf("content: ошибка разбора %s: %w", fileName, err)
+	}
+
+	p := page{tmpl: tmpl, parseMode: parseMode}
+
+	// Пробный рендер ловит несуществующие поля и битую разметку
+	text, err := p.render(sampleData)
+	if err != nil {
+		return "", page{}, err
+	}
+	if err := validateMarkup(text, parseMode); err != nil {
+		return "", page{}, fmt.Errorf("content: %s: %w", fileName, err)
+	}
+
+	return key, p, nil
+}
+
+// validateMarkup проверяет, что телеграм примет разметку
+func validateMarkup(text, parseMode string) error {
+	switch parseMode {
+	case ParseModeHTML:
+		return validateHTML(text)
+	case ParseModeMarkdown:
+		return validateMarkdown(text)
+	}
+
+	return nil
+}
+
+// validateHTML проверяет, что теги разрешены телеграмом и правильно закрыты
+func validateHTML(text string) error {
+	var stack []string
+	for _, m := range htmlTagRe.FindAllStringSubmatch(text, -1) {
+		closing, tag := m[1] == "/", strings.ToLower(m[2])
+		if !allowedHTMLTags[tag] {
+			return fmt.Errorf("тег <%s> не поддерживается телеграмом", tag)
+		}
+
+		if !closing {
+			stack = append(stack, tag)
+			continue
+		}
+		if len(stack) == 0 || stack[len(stack)-1] != tag {
+			return fmt.Errorf("лишний закрывающий тег </%s>", tag)
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	if len(stack) > 0 {
+		return fmt.Errorf("не закрыт тег <%s>", stack[len(stack)-1])
+	}
+
+	return nil
+}
+
+// validateMarkdown проверяет, что символы разметки Markdown парные
+func validateMarkdown(text string) error {
+	for _, mark := range []string{"*", "_", "`"} {
+		if strings.Count(text, mark)%2 != 0 {
+			return fmt.Errorf("непарный символ разметки %s", mark)
+		}
+	}
+	if strings.Count(text, "[") != strings.Count(text, "]") {
+		return errors.New("непарные скобки ссылки [ ]")
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package i18n каталоги переводов бота. Тексты лежат в JSON файлах
+// (locales/ru.json, locales/en.json), по умолчанию вшиты в бинарник,
+// но можно подложить свою папку и править тексты без пересборки.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"ProxyMaster_v2/internal/domain"
+)
+
+// DefaultLanguage основной язык. Его каталог - эталон ключей для остальных
+const DefaultLanguage = "ru"
+
+// fallbackLanguage язык для всех, чей язык мы не поддерживаем
+const fallbackLanguage = "en"
+
+// cisLanguages языки СНГ, носителям которых понятнее русский, чем английский
+var cisLanguages = []string{"ru", "uk", "be", "kk", "ky", "uz", "tg", "hy", "az", "ka"}
+
+//go:embed locales/*.json
+var embedded embed.FS
+
+// Bundle все загруженные каталоги
+type Bundle struct {
+	catalogs map[string]map[string]string
+}
+
+// LoadEmbedded загружает вшитые в бинарник каталоги
+func LoadEmbedded() (*Bundle, error) {
+	sub, err := fs.Sub(embedded, "locales")
+	if err != nil {
+		return nil, fmt.Errorf("i18n: ошибка чтения вшитых каталогов: %w", err)
+	}
+
+	return Load(sub)
+}
+
+// Load загружает каталоги <lang>.json из fsys и проверяет, что они
+// совпадают с основным: те же ключи и то же кол-во подстановок
+func Load(fsys fs.FS) (*Bundle, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("i18n: ошибка поиска каталогов: %w", err)
+	}
+
+	b := &Bundle{catalogs: make(map[string]map[string]string, len(files))}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("i18n: ошибка чтения %s: %w", name, err)
+		}
+
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("i18n: ошибка разбора %s: %w", name, err)
+		}
+
+		b.catalogs[strings.TrimSuffix(path.Base(name), ".json")] = catalog
+	}
+
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// validate сверяет все каталоги с основным и собирает все ошибки сразу
+func (b *Bundle) validate() error {
+	base, ok := b.catalogs[DefaultLanguage]
+	if !ok {
+		return fmt.Errorf("i18n: нет основного каталога %s.json", DefaultLanguage)
+	}
+	if _, ok := b.catalogs[fallbackLanguage]; !ok {
+		return fmt.Errorf("i18n: нет каталога %s.json", fallbackLanguage)
+	}
+
+	var errs []error
+	for lang, catalog := range b.catalogs {
+		for key, text := range base {
+			translated, ok := catalog[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("i18n: %s: нет ключа %q", lang, key))
+				continue
+			}
+			if countVerbs(translated) != countVerbs(text) {
+				errs = append(errs, fmt.Errorf("i18n: %s: ключ %q: другое кол-во подстановок", lang, key))
+			}
+		}
+		for key := range catalog {
+			if _, ok := base[key]; !ok {
+				errs = append(errs, fmt.Errorf("i18n: %s: лишний ключ %q", lang, key))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// countVerbs считает подстановки вида %s, %d (%% не считается)
+func countVerbs(s string) int {
+	n := 0
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if s[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+
+	return n
+}
+
+// For переводчик для языка. Неизвестный язык заменяется запасным
+func (b *Bundle) For(lang string) domain.Localizer {
+	if _, ok := b.catalogs[lang]; !ok {
+		lang = fallbackLanguage
+	}
+
+	return localizer{lang: lang, bundle: b}
+}
+
+// Resolve выбирает язык по LanguageCode телеграма
+func (b *Bundle) Resolve(code string) string {
+	// "en-US" -> "en"
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+
+	if _, ok := b.catalogs[lang]; ok {
+		return lang
+	}
+	if slices.Contains(cisLanguages, lang) {
+		return DefaultLanguage
+	}
+	if lang == "" {
+		return DefaultLanguage
+	}
+
+	return fallbackLanguage
+}
+
+// Languages поддерживаемые языки, основной первым
+func (b *Bundle) Languages() []string {
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		if lang != DefaultLanguage {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+
+	return append([]string{DefaultLanguage}, langs...)
+}
+
+// localizer реализация domain.Localizer
+type localizer struct {
+	lang   string
+	bundle *Bundle
+}
+
+// T текст по ключу. Если ключа нет - возвращаем сам ключ, чтобы это было видно
+func (l localizer) T(key string, args ...any) string {
+	text, ok := l.bundle.catalogs[l.lang][key]
+	if !ok {
+		text, ok = l.bundle.catalogs[DefaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+
+	if len(args) == 0 {
+		return text
+	}
+
+	return fmt.Sprintf(text, args...)
+}
+
+// Lang язык переводчика
+func (l localizer) Lang() string {
+	return l.lang
+}
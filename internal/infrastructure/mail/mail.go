@@ -0,0 +1,84 @@
+// Package mail отправка писем через SMTP. Пока нужна только для кода
+// входа на сайт у пользователей без телеграма.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Sender SMTP отправитель, реализует domain.LoginCodeSender
+type Sender struct {
+	// addr host:port SMTP сервера. smtp.SendMail сам включает STARTTLS,
+	// если сервер его поддерживает (порт 587). Порт 465 (сразу TLS) не поддерживаем
+	addr   string
+	auth   smtp.Auth
+	from   string
+	logger logger.Logger
+}
+
+// NewSender конструктор. user пустой - без авторизации (локальный relay)
+func NewSender(host string, port int, user, password, from string, l logger.Logger) (*Sender, error) {
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("mail: не заданы host или from")
+	}
+	if port == 0 {
+		port = 587
+	}
+
+	s := &Sender{
+		addr:   net.JoinHostPort(host, fmt.Sprint(port)),
+		from:   from,
+		logger: l,
+	}
+	if user != "" {
+		s.auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return s, nil
+}
+
+// SendLoginCode отправляет код входа на сайт на адрес to
+func (s *Sender) SendLoginCode(_ context.Context, to, code string) error {
+	body := fmt.Sprintf("Код для входа на сайт: %s\r\n\r\nКод действует 10 минут. Никому его не сообщайте.\r\n"+
+		"Если вы не входили на сайт, просто проигнорируйте это письмо.\r\n", code)
+
+	if err := s.Send(to, "Код для входа", body); err != nil {
+		return fmt.Errorf("mail.SendLoginCode: %w", err)
+	}
+
+	return nil
+}
+
+// Send отправляет простое текстовое письмо
+func (s *Sender) Send(to, subject, body string) error {
+	// Адрес попадает в заголовки письма, переносы строк дали бы подставить свои заголовки
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("недопустимый адрес: %q", to)
+	}
+
+	headers := []string{
+		"From: " + s.from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" + body
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg)); err != nil {
+		s.logger.Error("ошибка отправки письма", logger.Field{Key: "error", Value: err})
+		return err
+	}
+
+	return nil
+}
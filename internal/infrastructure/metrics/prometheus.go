@@ -0,0 +1,197 @@
+// Package metrics реализация domain.Metrics на Prometheus и endpoint /metrics.
+// Все метрики с префиксом proxymaster_, свой реестр вместо глобального,
+// чтобы в /metrics не попадало лишнее из сторонних библиотек.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace префикс всех метрик приложения
+const namespace = "proxymaster"
+
+// activeSubscriptionsTimeout сколько ждем панель при сборе числа активных подписок
+const activeSubscriptionsTimeout = 5 * time.Second
+
+// Prometheus метрики приложения
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	updates        *prometheus.CounterVec
+	updateDuration *prometheus.HistogramVec
+
+	remnawaveRequests *prometheus.CounterVec
+	remnawaveDuration *prometheus.HistogramVec
+
+	payments       *prometheus.CounterVec
+	revenue        *prometheus.CounterVec
+	refunded       *prometheus.CounterVec
+	balanceDebited *prometheus.CounterVec
+
+	logger logger.Logger
+}
+
+// New создает метрики и регистрирует их вместе с метриками Go рантайма и процесса
+func New(l logger.Logger) *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "telegram_updates_total",
+			Help:      "Обработанные обновления телеграма по типу, маршруту и результату.",
+		}, []string{"kind", "route", "result"}),
+		updateDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "telegram_handler_duration_seconds",
+			Help:      "Время обработки обновления телеграма.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"kind", "route"}),
+		remnawaveRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "remnawave_requests_total",
+			Help:      "Запросы к панели Remnawave по endpoint и HTTP статусу (0 - нет ответа).",
+		}, []string{"endpoint", "status"}),
+		remnawaveDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "remnawave_request_duration_seconds",
+			Help:      "Время ответа панели Remnawave.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		payments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_total",
+			Help:      "Платежи по провайдеру и событию: created, succeeded, failed, refunded.",
+		}, []string{"provider", "event"}),
+		revenue: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "revenue_rubles_total",
+			Help:      "Зачислено на балансы в рублях без комиссии.",
+		}, []string{"provider"}),
+		refunded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "refunded_rubles_total",
+			Help:      "Возвращено пользователям в рублях.",
+		}, []string{"provider"}),
+		balanceDebited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "balance_debited_rubles_total",
+			Help:      "Списано с балансов в рублях.",
+		}, []string{"reason"}),
+		logger: l,
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.updates,
+		p.updateDuration,
+		p.remnawaveRequests,
+		p.remnawaveDuration,
+		p.payments,
+		p.revenue,
+		p.refunded,
+		p.balanceDebited,
+	)
+
+	return p
+}
+
+// WatchActiveSubscriptions добавляет метрику активных подписок. count
+// вызывается при каждом сборе метрик, ошибка пропускает значение
+func (p *Prometheus) WatchActiveSubscriptions(count func(ctx context.Context) (int, error)) {
+	p.registry.MustRegister(&activeSubscriptions{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_subscriptions"),
+			"Пользователи Remnawave со статусом ACTIVE.",
+			nil, nil,
+		),
+		count:  count,
+		logger: p.logger,
+	})
+}
+
+// Register отдает метрики на GET /metrics
+func (p *Prometheus) Register(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+}
+
+// ObserveUpdate обработано обновление телеграма
+func (p *Prometheus) ObserveUpdate(kind, route string, ok bool, duration time.Duration) {
+	result := "ok"
+	if !ok {
+		result = "error"
+	}
+
+	p.updates.WithLabelValues(kind, route, result).Inc()
+	p.updateDuration.WithLabelValues(kind, route).Observe(duration.Seconds())
+}
+
+// ObserveRemnawaveRequest запрос к панели
+func (p *Prometheus) ObserveRemnawaveRequest(endpoint string, status int, duration time.Duration) {
+	p.remnawaveRequests.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	p.remnawaveDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
+
+// PaymentCreated создан счет на пополнение
+func (p *Prometheus) PaymentCreated(provider string) {
+	p.payments.WithLabelValues(provider, "created").Inc()
+}
+
+// PaymentSucceeded оплата зачислена
+func (p *Prometheus) PaymentSucceeded(provider string, amount int) {
+	p.payments.WithLabelValues(provider, "succeeded").Inc()
+	p.revenue.WithLabelValues(provider).Add(float64(amount))
+}
+
+// PaymentFailed счет не создан или оплата отменена
+func (p *Prometheus) PaymentFailed(provider string) {
+	p.payments.WithLabelValues(provider, "failed").Inc()
+}
+
+// PaymentRefunded оплата возвращена
+func (p *Prometheus) PaymentRefunded(provider string, amount int) {
+	p.payments.WithLabelValues(provider, "refunded").Inc()
+	p.refunded.WithLabelValues(provider).Add(float64(amount))
+}
+
+// BalanceDebited списание с баланса
+func (p *Prometheus) BalanceDebited(reason string, amount int) {
+	p.balanceDebited.WithLabelValues(reason).Add(float64(amount))
+}
+
+// activeSubscriptions коллектор, который спрашивает число подписок
+// в момент сбора метрик, а не держит устаревшее значение
+type activeSubscriptions struct {
+	desc   *prometheus.Desc
+	count  func(ctx context.Context) (int, error)
+	logger logger.Logger
+}
+
+// Describe описание метрики для реестра
+func (c *activeSubscriptions) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect спрашивает число активных подписок. Если панель не ответила,
+// метрики в этом сборе не будет: лучше пропуск на графике, чем ложный ноль
+func (c *activeSubscriptions) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), activeSubscriptionsTimeout)
+	defer cancel()
+
+	count, err := c.count(ctx)
+	if err != nil {
+		c.logger.Warn("не удалось получить число активных подписок", logger.Field{Key: "error", Value: err})
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
@@ -10,9 +10,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -21,38 +20,63 @@ import (
 	"ProxyMaster_v2/pkg/logger"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // RemnaClient описывает то что нужно для работы remnawave.
 type RemnaClient struct {
-	cfg        *config.Config
+	cfg        config.Remnawave
 	httpClient *http.Client
 	// Храним логгер здесь для обращения к нему
 	logger logger.Logger
 }
 
+// log логгер клиента с полями запроса из ctx (update_id, user_id, trace_id)
+func (c *RemnaClient) log(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, c.logger)
+}
+
 // logDuration логирует время выполнения метода.
-func (c *RemnaClient) logDuration(method string) func() {
+func (c *RemnaClient) logDuration(ctx context.Context, method string) func() {
 	start := time.Now()
 	return func() {
-		c.logger.Info("вызов метода завершен",
+		c.log(ctx).Info("вызов метода завершен",
 			logger.Field{Key: "method", Value: method},
 			logger.Field{Key: "duration", Value: time.Since(start)},
 		)
 	}
 }
 
+// do выполняет запрос к панели. В ошибке net/http полный адрес запроса,
+// а в нем секретный токен панели, поэтому адрес маскируем
+func (c *RemnaClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = logger.Redact(urlErr.URL)
+	}
+
+	return resp, err
+}
+
 // NewRemnaClient конструктор для создания клиента.
-func NewRemnaClient(cfg *config.Config, l logger.Logger) *RemnaClient {
+func NewRemnaClient(cfg config.Remnawave, l logger.Logger) *RemnaClient {
 	l.Info("Создан экземпляр remnawave")
 
 	return &RemnaClient{
 		cfg: cfg,
 		httpClient: &http.Client{
 			// Хорошая практика: всегда задавать тайм-аут.
-			Timeout: 10 * time.Second,
+			Timeout: cfg.Timeout,
+			// Спан на каждый запрос к панели, дочерний к спану из ctx запроса.
+			// Без настроенного трейсинга ничего не стоит
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "remnawave " + r.Method + " " + endpointPath(r.URL.Path)
+				}),
+			),
 			// Указываем все поля, так как ругаются линтер.
-			Transport:     nil,
 			CheckRedirect: nil,
 			Jar:           nil,
 		},
@@ -61,59 +85,57 @@ func NewRemnaClient(cfg *config.Config, l logger.Logger) *RemnaClient {
 }
 
 // GetUUIDByUsername - метод нахождения пользователя через username.
-func (c *RemnaClient) GetUUIDByUsername(username string) (string, error) {
-	defer c.logDuration("GetUUIDByUsername")()
+func (c *RemnaClient) GetUUIDByUsername(ctx context.Context, username string) (string, error) {
+	defer c.logDuration(ctx, "GetUUIDByUsername")()
 
 	var userData models.GetUUIDByUsernameResponse
 	// /api/users/by-username/{username}
-	url := fmt.Sprintf("%s/api/users/by-username/%s?%s", c.cfg.RemnaPanelURL, username, c.cfg.RemnaSecretURLToken)
+	url := fmt.Sprintf("%s/api/users/by-username/%s?%s", c.cfg.PanelURL, username, c.cfg.SecretURLToken)
 
-	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
-		slog.Error(err.Error())
-
 		return "", fmt.Errorf("remnawave: не удалось создать request: %w", err)
 	}
 
 	request.Header.Add("Content-Type", "application/json")
-	request.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	request.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
-	response, err := c.httpClient.Do(request)
+	response, err := c.do(request)
 	if err != nil {
 		return "", fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
 	}
 	defer func() {
 		if err = response.Body.Close(); err != nil {
-			c.logger.Error("не удалось закрыть тело ответа", logger.Field{Key: "error", Value: err.Error()})
+			c.log(ctx).Error("не удалось закрыть тело ответа", logger.Field{Key: "error", Value: err.Error()})
 		}
 	}()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		c.logger.Error("не удалось преобразовать тело ответа", logger.Field{Key: "error", Value: err.Error()})
+		c.log(ctx).Error("не удалось преобразовать тело ответа", logger.Field{Key: "error", Value: err.Error()})
 
 		return "", fmt.Errorf("remnawave: не удалось преобразовать тело ответа: %w", err)
 	}
 
 	if err := json.Unmarshal(body, &userData); err != nil {
-		c.logger.Error("не удалось распарсить тело ответа", logger.Field{Key: "error", Value: err.Error()})
+		c.log(ctx).Error("не удалось распарсить тело ответа", logger.Field{Key: "error", Value: err.Error()})
 
 		return "", fmt.Errorf("remnawave: не удалось распарсить тело ответа: %w", err)
 	}
 
 	switch response.StatusCode {
 	case http.StatusBadRequest:
-		c.logger.Error(fmt.Sprintf("%s\n%s", ErrBadRequestUsername.Error(), string(body)))
+		c.log(ctx).Error(fmt.Sprintf("%s\n%s", ErrBadRequestUsername.Error(), string(body)))
 
 		return "", ErrBadRequestUsername
 
 	case http.StatusInternalServerError:
-		slog.Error(ErrInternalServerError.Error())
+		c.log(ctx).Error(ErrInternalServerError.Error())
 
 		return "", ErrInternalServerError
 
 	case http.StatusNotFound:
-		c.logger.Error(ErrNotFound.Error())
+		c.log(ctx).Error(ErrNotFound.Error())
 
 		return "", ErrNotFound
 	}
@@ -125,7 +147,7 @@ func (c *RemnaClient) GetUUIDByUsername(username string) (string, error) {
 		return "", errors.New("UUID or Username Is nil")
 	}
 
-	c.logger.Info(
+	c.log(ctx).Info(
 		"получен UUID пользователя",
 		logger.Field{Key: "username", Value: username},
 		logger.Field{Key: "uuid", Value: userData.Response.UUID},
@@ -135,125 +157,110 @@ func (c *RemnaClient) GetUUIDByUsername(username string) (string, error) {
 	return userData.Response.UUID, nil
 }
 
-// SetDevices устанавилвает кол-во устройств пользователя
-func (c *RemnaClient) SetDevices(username string, devices *uint8) error {
-	if devices == nil {
-		return fmt.Errorf("не указано кол-во устройств в методе SetDevices")
+// maxDeviceLimit панель хранит лимит устройств в uint8
+const maxDeviceLimit = 255
+
+// SetDevices устанавливает лимит устройств (HWID) пользователя
+func (c *RemnaClient) SetDevices(ctx context.Context, username string, devices int) error {
+	if devices <= 0 || devices > maxDeviceLimit {
+		return fmt.Errorf("remnawave: неверный лимит устройств: %d", devices)
 	}
-	defer c.logDuration("SetDevices")()
+	defer c.logDuration(ctx, "SetDevices")()
 
 	// Отправляем только то что нужно изменить, без идентификаторов в теле
+	limit := uint8(devices)
 	userData := &models.UpdateUserRequest{
 		Username:        &username,
-		HwidDeviceLimit: devices,
+		HwidDeviceLimit: &limit,
 	}
 
-	url := fmt.Sprintf("%s/api/users?%s", c.cfg.RemnaPanelURL, c.cfg.RemnaSecretURLToken)
+	url := fmt.Sprintf("%s/api/users?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
 	jsonData, err := json.Marshal(userData)
 	if err != nil {
-		c.logger.Error(
-			"failed to marshal request",
-			logger.Field{Key: "err_msg", Value: err},
-		)
-		return err
+		return fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
 	}
 
-	request, err := http.NewRequestWithContext(context.Background(), "PATCH", url, bytes.NewBuffer(jsonData))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		c.logger.Error(
-			"failed to make request",
-			logger.Field{Key: "err_msg", Value: err},
-		)
-
-		return err
+		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
 	}
 
 	request.Header.Add("Content-Type", "application/json")
-	request.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	request.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
-	response, err := c.httpClient.Do(request)
+	response, err := c.do(request)
 	if err != nil {
-		c.logger.Error(
-			"failed to get response",
-			logger.Field{Key: "err_msg", Value: err},
-		)
+		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
 	}
 
 	defer func() {
-		if response != nil {
-			if err := response.Body.Close(); err != nil {
-				c.logger.Error(
-					"failed to close response body",
-					logger.Field{Key: "err_msg", Value: err},
-				)
-			}
+		if err := response.Body.Close(); err != nil {
+			c.log(ctx).Error("не удалось закрыть тело ответа", logger.Field{Key: "error", Value: err})
 		}
 	}()
 
 	switch response.StatusCode {
 	case http.StatusOK:
-		c.logger.Info(
-			fmt.Sprintf("devices for user: %s set succesfully", username),
-			logger.Field{Key: "status code", Value: response.StatusCode},
+		c.log(ctx).Info("лимит устройств изменен",
+			logger.Field{Key: "username", Value: username},
+			logger.Field{Key: "devices", Value: devices},
 		)
 
 		return nil
 	case http.StatusBadRequest:
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			return fmt.Errorf("failed to make repsonse body")
+			c.log(ctx).Warn("не удалось преобразовать тело ответа", logger.Field{Key: "error", Value: err})
 		}
-		c.logger.Error(
-			"failed to set devices",
-			logger.Field{Key: "status code", Value: response.StatusCode},
-			logger.Field{Key: "response body", Value: body},
+		c.log(ctx).Error("не удалось изменить лимит устройств",
+			logger.Field{Key: "username", Value: username},
+			logger.Field{Key: "response_body", Value: string(body)},
 		)
 
-		return err
+		return ErrBadRequestUsername
+	case http.StatusNotFound:
+		return ErrNotFound
 	case http.StatusInternalServerError:
-		c.logger.Error(
-			"failed to set devices",
-			logger.Field{Key: "status code", Value: response.StatusCode},
-		)
-
-		return fmt.Errorf("server returned internal error: %v", response.StatusCode)
+		return ErrInternalServerError
 	}
 
-	return fmt.Errorf("undefined error")
+	return fmt.Errorf("remnawave: лимит устройств не изменен, статус %d", response.StatusCode)
 }
 
-// CreateUser создает пользователя в панели.
-func (c *RemnaClient) CreateUser(username string, days int) error {
+// CreateUser создает пользователя в панели по параметрам тарифа plan.
+// Пустой сквад - сквад по умолчанию из конфига
+func (c *RemnaClient) CreateUser(ctx context.Context, username string, days int, plan models.UserPlan) error {
 	if days <= 0 {
 		return errors.New("дней не может быть ноль при создании подписки")
 	}
+	if plan.SquadUUID == "" {
+		plan.SquadUUID = c.cfg.SquadUUID
+	}
+	if plan.TrafficLimitStrategy == "" {
+		plan.TrafficLimitStrategy = "MONTH"
+	}
 
 	now := time.Now().UTC()
 
-	// указываем лимиты трафика
-	const oneGb int = 1024 * 1024 * 1024
-	// лимит 100 gb
-	trafficLimit := 100 * oneGb
-
 	// Заполняем структуру для remnawave, чтобы она указала параметры в панели.
 	userData := &models.CreateRequestUserDTO{
 		Username:             username,
 		Status:               "ACTIVE",
 		TrojanPassword:       newShortSecret(), // Пароль для протокола Trojan.
 		VLessUUID:            uuid.NewString(),
-		SsPassword:           newShortSecret(), // Пароль для shadow socks.
-		ShortUUID:            newShortSecret(), // Короткий uuid для идентификации.
-		TrafficLimitBytes:    trafficLimit,     // Устанавливаем лимит трафика.
-		TrafficLimitStrategy: "MONTH",          // Период сброса трафика.
+		SsPassword:           newShortSecret(),            // Пароль для shadow socks.
+		ShortUUID:            newShortSecret(),            // Короткий uuid для идентификации.
+		TrafficLimitBytes:    int(plan.TrafficLimitBytes), // Устанавливаем лимит трафика.
+		TrafficLimitStrategy: plan.TrafficLimitStrategy,   // Период сброса трафика.
 		ExpireAt:             now.AddDate(0, 0, days).Format(time.RFC3339),
 		CreatedAt:            now.Format(time.RFC3339),
 		LastTrafficResetAt:   now.Format(time.RFC3339),
 		Description:          "Created via ProxyMaster",
-		ActiveInternalSquads: []string{c.cfg.RemnaSquadUUID},
+		ActiveInternalSquads: []string{plan.SquadUUID},
 	}
 
 	// формируем строку куда идет запрос
-	url := fmt.Sprintf("%s/api/users?%s", c.cfg.RemnaPanelURL, c.cfg.RemnaSecretURLToken)
+	url := fmt.Sprintf("%s/api/users?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
 
 	// время для логирования
 	start := time.Now()
@@ -263,15 +270,15 @@ func (c *RemnaClient) CreateUser(username string, days int) error {
 		return fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
 	}
 
-	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewBuffer(jsonData))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
 	}
 
 	request.Header.Add("Content-Type", "application/json")
-	request.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	request.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
-	response, err := c.httpClient.Do(request)
+	response, err := c.do(request)
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
 	}
@@ -286,32 +293,30 @@ func (c *RemnaClient) CreateUser(username string, days int) error {
 	case http.StatusBadRequest:
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			slog.Warn("не удалось преобразовать тело ответа")
+			c.log(ctx).Warn("не удалось преобразовать тело ответа", logger.Field{Key: "error", Value: err})
 		}
 
-		slog.Error(fmt.Sprintf("%s\n%s", ErrBadRequestCreate.Error(), string(body)))
+		c.log(ctx).Error(ErrBadRequestCreate.Error(), logger.Field{Key: "response_body", Value: string(body)})
 
 		return ErrBadRequestCreate
 	case http.StatusInternalServerError:
-		slog.Error(ErrInternalServerError.Error())
+		c.log(ctx).Error(ErrInternalServerError.Error())
 
 		return ErrInternalServerError
 	}
 
-	slog.Info(
-		"User created",
-		"time taken", time.Since(start),
-		"status code", http.StatusCreated,
-		"username", userData.Username,
+	c.log(ctx).Info("пользователь создан в панели",
+		logger.Field{Key: "username", Value: userData.Username},
+		logger.Field{Key: "duration", Value: time.Since(start)},
 	)
 
 	return nil
 }
 
 // ExtendClientSubscription продлевает подписку в панели.
-func (c *RemnaClient) ExtendClientSubscription(userUUID, username string, days int) error {
+func (c *RemnaClient) ExtendClientSubscription(ctx context.Context, userUUID, username string, days int) error {
 	// формирует url для запроса в api с секретным token для прохода через Nginx
-	url := fmt.Sprintf("%s/api/users/bulk/extend-expiration-date?%s", c.cfg.RemnaPanelURL, c.cfg.RemnaSecretURLToken)
+	url := fmt.Sprintf("%s/api/users/bulk/extend-expiration-date?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
 
 	payload := models.BulkExtendRequest{
 		UUIDs: []string{userUUID},
@@ -324,17 +329,17 @@ func (c *RemnaClient) ExtendClientSubscription(userUUID, username string, days i
 	}
 
 	// создаем запрос
-	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewBuffer(payloadBytes))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
 	}
 
 	request.Header.Add("Content-Type", "application/json")
 
-	request.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	request.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
 	// делаем запрос и получаем ответ
-	response, err := c.httpClient.Do(request)
+	response, err := c.do(request)
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
 	}
@@ -347,17 +352,26 @@ func (c *RemnaClient) ExtendClientSubscription(userUUID, username string, days i
 
 	// Если соединение прошло, то все отлично.
 	if response.StatusCode == http.StatusOK {
-		log.Printf("remnawave: период подписки клиента: %s. UUID: %s увеличен на %d дней.\n", username, userUUID, days)
+		c.log(ctx).Info("период подписки увеличен",
+			logger.Field{Key: "username", Value: username},
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "days", Value: days},
+		)
 	} else {
 		// Если соединения нету.
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			log.Println("remnawave: не удалось преобразовать тело ответа")
+			c.log(ctx).Error("не удалось преобразовать тело ответа", logger.Field{Key: "error", Value: err})
 
 			return errors.New("remnawave: не удалось преобразовать тело ответа")
 		}
 
-		log.Printf("remnawave: не удалось увеличить период подписки клиента: %s. UUID: %s. Тело ошибки: %s.\n", username, userUUID, string(body))
+		c.log(ctx).Error("не удалось увеличить период подписки",
+			logger.Field{Key: "username", Value: username},
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "status", Value: response.StatusCode},
+			logger.Field{Key: "response_body", Value: string(body)},
+		)
 
 		return errors.New("remnawave: не удалось увеличить период подписки")
 	}
@@ -368,25 +382,25 @@ func (c *RemnaClient) ExtendClientSubscription(userUUID, username string, days i
 // actionUrl отдаем методам строку, чтобы избежать дублирование кода в методах.
 func (c *RemnaClient) actionUrl(userUUID string, action string) string {
 	return fmt.Sprintf("%s/api/users/%s/actions/%s?%s",
-		c.cfg.RemnaPanelURL,
+		c.cfg.PanelURL,
 		userUUID,
 		action,
-		c.cfg.RemnaSecretURLToken,
+		c.cfg.SecretURLToken,
 	)
 }
 
 // changeUserState изменяет состояние пользователя в панели Remnawave.
-func (c *RemnaClient) changeUserState(userUUID, action string) error {
+func (c *RemnaClient) changeUserState(ctx context.Context, userUUID, action string) error {
 	url := c.actionUrl(userUUID, action)
 
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, url, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, http.NoBody)
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
 	}
@@ -410,39 +424,39 @@ func (c *RemnaClient) changeUserState(userUUID, action string) error {
 }
 
 // EnableClient включает клиента в панели remnawave.
-func (c *RemnaClient) EnableClient(userUUID string) error {
-	if err := c.changeUserState(userUUID, "enable"); err != nil {
+func (c *RemnaClient) EnableClient(ctx context.Context, userUUID string) error {
+	if err := c.changeUserState(ctx, userUUID, "enable"); err != nil {
 		return err
 	}
 
-	slog.Info("Пользователь успешно включен")
+	c.log(ctx).Info("пользователь включен", logger.Field{Key: "uuid", Value: userUUID})
 
 	return nil
 }
 
 // DisableClient выключает подписку в панели.
-func (c *RemnaClient) DisableClient(userUUID string) error {
-	if err := c.changeUserState(userUUID, "disable"); err != nil {
+func (c *RemnaClient) DisableClient(ctx context.Context, userUUID string) error {
+	if err := c.changeUserState(ctx, userUUID, "disable"); err != nil {
 		return err
 	}
 
-	slog.Info("Пользователь успешно выключен")
+	c.log(ctx).Info("пользователь выключен", logger.Field{Key: "uuid", Value: userUUID})
 
 	return nil
 }
 
 // GetUserInfo - возвращает информацию.
-func (c *RemnaClient) GetUserInfo(uuid string) (models.GetUserInfoResponse, error) {
-	url := fmt.Sprintf("%s/api/users/%s?%s", c.cfg.RemnaPanelURL, uuid, c.cfg.RemnaSecretURLToken)
+func (c *RemnaClient) GetUserInfo(ctx context.Context, uuid string) (models.GetUserInfoResponse, error) {
+	url := fmt.Sprintf("%s/api/users/%s?%s", c.cfg.PanelURL, uuid, c.cfg.SecretURLToken)
 
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
 		return models.GetUserInfoResponse{}, fmt.Errorf("remnaClient.GetUserInfo: NewRequestError: %w", err)
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.cfg.RemnaKey)
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return models.GetUserInfoResponse{}, fmt.Errorf("remnaClient.GetUserInfo: GetResponseError: %w", err)
 	}
@@ -455,17 +469,17 @@ func (c *RemnaClient) GetUserInfo(uuid string) (models.GetUserInfoResponse, erro
 
 	switch resp.StatusCode {
 	case http.StatusNotFound:
-		slog.Error(ErrNotFound.Error())
+		c.log(ctx).Error(ErrNotFound.Error(), logger.Field{Key: "uuid", Value: uuid})
 
 		return models.GetUserInfoResponse{}, ErrNotFound
 
 	case http.StatusInternalServerError:
-		slog.Error(ErrInternalServerError.Error())
+		c.log(ctx).Error(ErrInternalServerError.Error(), logger.Field{Key: "uuid", Value: uuid})
 
 		return models.GetUserInfoResponse{}, ErrInternalServerError
 
 	case http.StatusBadRequest:
-		slog.Error(ErrBadRequestUUID.Error())
+		c.log(ctx).Error(ErrBadRequestUUID.Error(), logger.Field{Key: "uuid", Value: uuid})
 
 		return models.GetUserInfoResponse{}, ErrBadRequestUUID
 	}
@@ -486,8 +500,8 @@ func (c *RemnaClient) GetUserInfo(uuid string) (models.GetUserInfoResponse, erro
 	return userInfo, nil
 }
 
-func (c *RemnaClient) GetUserStatus(uuid string) (status string, err error) {
-	userInfo, err := c.GetUserInfo(uuid)
+func (c *RemnaClient) GetUserStatus(ctx context.Context, uuid string) (status string, err error) {
+	userInfo, err := c.GetUserInfo(ctx, uuid)
 	if err != nil {
 		return "", err
 	}
@@ -527,7 +541,7 @@ func (c *RemnaClient) Login(ctx context.Context, username, password string) erro
 	// делаем итоговую ссылку куда идем логиниться
 	// Сохраняем адрес в requestURL поскольку remna
 	// разрешает вход только по секретному адресу
-	requestURL := fmt.Sprintf("%s/api/auth/login?%s", c.cfg.RemnaPanelURL, c.cfg.RemnaSecretURLToken)
+	requestURL := fmt.Sprintf("%s/api/auth/login?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
 
 	// Создаем запрос на отправку. Как письмо перед отправкой.
 	// Кладем итоговый путь и json который делали как паспорт при
@@ -543,37 +557,30 @@ func (c *RemnaClient) Login(ctx context.Context, username, password string) erro
 	req.Header.Set("Content-Type", "application/json")
 
 	// вот тут идет коннект с сервером
-	// httpClient.Do() отправляет данные и в
+	// c.do() отправляет данные и в
 	// него кладем что отправим на сервер
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("ошибка отправки запроса: %w", err)
 	}
 
 	defer func() {
 		if err = resp.Body.Close(); err != nil {
-			slog.Error(
-				"Ошибка закрытия соединения",
-				"error", err,
-			)
+			c.log(ctx).Error("ошибка закрытия соединения", logger.Field{Key: "error", Value: err})
 		}
 	}()
 
 	// Читаем что вернул сервер, а вернул он resp. Body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		slog.Error(
-			"ошибка чтения тела ответа",
-			"response_body", string(bodyBytes),
-		)
+		c.log(ctx).Error("ошибка чтения тела ответа", logger.Field{Key: "error", Value: err})
 	}
 
 	// вернул ли сервер OK (200) или Created (201) иначе ошибка
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		slog.Error(
-			"ошибка входа",
-			"status_code", resp.StatusCode,
-			"response_body", string(bodyBytes),
+		c.log(ctx).Error("ошибка входа",
+			logger.Field{Key: "status_code", Value: resp.StatusCode},
+			logger.Field{Key: "response_body", Value: string(bodyBytes)},
 		)
 
 		return fmt.Errorf("ошибка входа: %d, тело: %s: %w", resp.StatusCode, string(bodyBytes), ErrLoginFailed)
@@ -587,14 +594,10 @@ func (c *RemnaClient) Login(ctx context.Context, username, password string) erro
 	}
 
 	// Сохраняем полученный токен
-	c.cfg.RemnaKey = loginResp.Response.AccessToken
+	c.cfg.Token = loginResp.Response.AccessToken
 
 	// Логирование успеха как в remna.go
-	slog.Info(
-		"Login is successful",
-		"status", resp.Status,
-		"response_body", string(bodyBytes),
-	)
+	c.log(ctx).Info("вход выполнен", logger.Field{Key: "status", Value: resp.Status})
 
 	return nil
 }
@@ -0,0 +1,92 @@
+// Package remnawave устройства пользователя (HWID), которые подключались
+// по ссылке подписки. Лимит устройств задает SetDevices.
+package remnawave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// GetUserDevices устройства пользователя userUUID
+func (c *RemnaClient) GetUserDevices(ctx context.Context, userUUID string) ([]models.HWIDDevice, error) {
+	defer c.logDuration(ctx, "GetUserDevices")()
+
+	url := fmt.Sprintf("%s/api/hwid/devices/%s?%s", c.cfg.PanelURL, userUUID, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		c.log(ctx).Error("не удалось получить устройства", logger.Field{Key: "status", Value: resp.StatusCode})
+
+		return nil, fmt.Errorf("remnawave: устройства недоступны, статус %d", resp.StatusCode)
+	}
+
+	var devices models.HWIDDevicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+		return nil, fmt.Errorf("remnawave: %w", ErrUnmarshal)
+	}
+
+	return devices.Response.Devices, nil
+}
+
+// DeleteUserDevice отвязывает устройство hwid. Место освобождается сразу,
+// а само устройство подключится снова, только если хватит лимита
+func (c *RemnaClient) DeleteUserDevice(ctx context.Context, userUUID, hwid string) error {
+	defer c.logDuration(ctx, "DeleteUserDevice")()
+
+	jsonData, err := json.Marshal(models.DeleteHWIDDeviceRequest{UserUUID: userUUID, HWID: hwid})
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/hwid/devices/delete?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.log(ctx).Info("устройство отвязано", logger.Field{Key: "uuid", Value: userUUID})
+
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+
+	c.log(ctx).Error("не удалось отвязать устройство",
+		logger.Field{Key: "uuid", Value: userUUID},
+		logger.Field{Key: "status", Value: resp.StatusCode},
+	)
+
+	return fmt.Errorf("remnawave: устройство не отвязано, статус %d", resp.StatusCode)
+}
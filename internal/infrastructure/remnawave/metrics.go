@@ -0,0 +1,106 @@
+// Package remnawave метрики запросов к панели, число активных подписок
+// и проверка доступности панели.
+package remnawave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// statsResponse нужная часть ответа /api/system/stats
+type statsResponse struct {
+	Response struct {
+		Users struct {
+			StatusCounts map[string]int `json:"statusCounts"`
+		} `json:"users"`
+	} `json:"response"`
+}
+
+// SetMetrics включает метрики: каждый запрос к панели попадает в m
+// с endpoint без id пользователя, статусом и временем ответа
+func (c *RemnaClient) SetMetrics(m domain.Metrics) {
+	next := c.httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.httpClient.Transport = &metricsTransport{next: next, metrics: m}
+}
+
+// CountActiveUsers сколько пользователей панели со статусом ACTIVE
+func (c *RemnaClient) CountActiveUsers(ctx context.Context) (int, error) {
+	url := fmt.Sprintf("%s/api/system/stats?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("remnawave: статистика недоступна, статус %d", resp.StatusCode)
+	}
+
+	var stats statsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, fmt.Errorf("remnawave: %w", ErrUnmarshal)
+	}
+
+	return stats.Response.Users.StatusCounts["ACTIVE"], nil
+}
+
+// Ping проверяет, что панель отвечает и принимает наш токен
+func (c *RemnaClient) Ping(ctx context.Context) error {
+	_, err := c.CountActiveUsers(ctx)
+	return err
+}
+
+// metricsTransport RoundTripper, который замеряет запросы к панели
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics domain.Metrics
+}
+
+// RoundTrip выполняет запрос и сообщает его endpoint, статус и время
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	t.metrics.ObserveRemnawaveRequest(req.Method+" "+endpointPath(req.URL.Path), status, time.Since(start))
+
+	return resp, err
+}
+
+// endpointPath путь без id и username, чтобы у метрики было ограниченное
+// число значений: /api/users/by-username/123 -> /api/users/by-username/{username}
+func endpointPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		switch {
+		case i > 0 && parts[i-1] == "by-username":
+			parts[i] = "{username}"
+		case uuid.Validate(part) == nil:
+			parts[i] = "{uuid}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,63 @@
+// Package remnawave перевыпуск подписки: старая ссылка и пароли
+// подключений перестают работать.
+package remnawave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// RevokeSubscription перевыпускает подписку пользователя userUUID и
+// возвращает новую ссылку
+func (c *RemnaClient) RevokeSubscription(ctx context.Context, userUUID string) (string, error) {
+	defer c.logDuration(ctx, "RevokeSubscription")()
+
+	jsonData, err := json.Marshal(models.RevokeSubscriptionRequest{})
+	if err != nil {
+		return "", fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/users/%s/actions/revoke?%s", c.cfg.PanelURL, userUUID, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrNotFound
+	default:
+		c.log(ctx).Error("не удалось перевыпустить подписку",
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "status", Value: resp.StatusCode},
+		)
+
+		return "", fmt.Errorf("remnawave: подписка не перевыпущена, статус %d", resp.StatusCode)
+	}
+
+	// Ответ такой же, как у информации о пользователе
+	var info models.GetUserInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("remnawave: %w", ErrUnmarshal)
+	}
+
+	c.log(ctx).Info("подписка перевыпущена", logger.Field{Key: "uuid", Value: userUUID})
+
+	return info.Response.SubscriptionURL, nil
+}
@@ -0,0 +1,105 @@
+// Package remnawave внутренние сквады панели. Сквад - набор нод одной
+// локации, поэтому пользователь выбирает регион, выбирая сквад.
+package remnawave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// GetInternalSquads список внутренних сквадов панели
+func (c *RemnaClient) GetInternalSquads(ctx context.Context) ([]models.InternalSquad, error) {
+	defer c.logDuration(ctx, "GetInternalSquads")()
+
+	url := fmt.Sprintf("%s/api/internal-squads?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.log(ctx).Error("не удалось получить сквады", logger.Field{Key: "status", Value: resp.StatusCode})
+
+		return nil, fmt.Errorf("remnawave: сквады недоступны, статус %d", resp.StatusCode)
+	}
+
+	var squads models.InternalSquadsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&squads); err != nil {
+		return nil, fmt.Errorf("remnawave: %w", ErrUnmarshal)
+	}
+
+	return squads.Response.InternalSquads, nil
+}
+
+// SetUserSquads заменяет сквады пользователя. Подписка и ссылка не меняются,
+// клиент после обновления подписки получает ноды нового сквада
+func (c *RemnaClient) SetUserSquads(ctx context.Context, userUUID string, squads []string) error {
+	defer c.logDuration(ctx, "SetUserSquads")()
+
+	if len(squads) == 0 {
+		// Пустой список в PATCH стер бы все сквады пользователя
+		return fmt.Errorf("remnawave: не указаны сквады пользователя %s", userUUID)
+	}
+
+	userData := &models.UpdateUserRequest{
+		Uuid:                 &userUUID,
+		ActiveInternalSquads: squads,
+	}
+
+	jsonData, err := json.Marshal(userData)
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/users?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.log(ctx).Info("сквады пользователя изменены",
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "squads", Value: squads},
+		)
+
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusBadRequest:
+		body, _ := io.ReadAll(resp.Body)
+		c.log(ctx).Error("не удалось изменить сквады",
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "response_body", Value: string(body)},
+		)
+
+		return ErrBadRequestUUID
+	}
+
+	return fmt.Errorf("remnawave: сквады не изменены, статус %d", resp.StatusCode)
+}
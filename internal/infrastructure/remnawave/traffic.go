@@ -0,0 +1,93 @@
+// Package remnawave лимит трафика пользователя и постраничный список
+// пользователей панели с израсходованным трафиком.
+package remnawave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// SetTrafficLimit задает лимит трафика пользователя. 0 - безлимит
+func (c *RemnaClient) SetTrafficLimit(ctx context.Context, userUUID string, limitBytes int64) error {
+	defer c.logDuration(ctx, "SetTrafficLimit")()
+
+	if limitBytes < 0 {
+		return fmt.Errorf("remnawave: неверный лимит трафика: %d", limitBytes)
+	}
+
+	limit := uint64(limitBytes)
+	jsonData, err := json.Marshal(&models.UpdateUserRequest{
+		Uuid:              &userUUID,
+		TrafficLimitBytes: &limit,
+	})
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать тело запроса: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/users?%s", c.cfg.PanelURL, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.log(ctx).Info("лимит трафика изменен",
+			logger.Field{Key: "uuid", Value: userUUID},
+			logger.Field{Key: "limit_bytes", Value: limitBytes},
+		)
+
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusBadRequest:
+		return ErrBadRequestUUID
+	}
+
+	return fmt.Errorf("remnawave: лимит трафика не изменен, статус %d", resp.StatusCode)
+}
+
+// GetUsers страница пользователей панели: size штук, начиная со start
+func (c *RemnaClient) GetUsers(ctx context.Context, start, size int) (models.UsersResponse, error) {
+	defer c.logDuration(ctx, "GetUsers")()
+
+	url := fmt.Sprintf("%s/api/users?start=%d&size=%d&%s", c.cfg.PanelURL, start, size, c.cfg.SecretURLToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return models.UsersResponse{}, fmt.Errorf("remnawave: не удалось создать request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+c.cfg.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return models.UsersResponse{}, fmt.Errorf("remnawave: не удалось получить ответ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.UsersResponse{}, fmt.Errorf("remnawave: список пользователей недоступен, статус %d", resp.StatusCode)
+	}
+
+	var users models.UsersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return models.UsersResponse{}, fmt.Errorf("remnawave: %w", ErrUnmarshal)
+	}
+
+	return users, nil
+}
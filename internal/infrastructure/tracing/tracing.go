@@ -0,0 +1,65 @@
+// Package tracing настройка OpenTelemetry. Без адреса коллектора остается
+// глобальный no-op провайдер: спаны создаются бесплатно и никуда не уходят,
+// поэтому бот работает и без Jaeger/Tempo рядом.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"ProxyMaster_v2/pkg/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName имя сервиса в трейсах
+const ServiceName = "proxymaster"
+
+// Setup включает экспорт трейсов по OTLP/HTTP на endpoint
+// (например http://tempo:4318). sampleRatio доля трейсов от 0 до 1, 0 - все.
+// Возвращает функцию, которая досылает накопленные спаны при остановке
+func Setup(ctx context.Context, endpoint string, sampleRatio float64, l logger.Logger) (func(context.Context) error, error) {
+	if endpoint == "" {
+		l.Info("трейсинг выключен: не задан адрес коллектора")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания OTLP экспортера: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка описания сервиса для трейсов: %w", err)
+	}
+
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Дочерние спаны следуют решению родителя, иначе трейс рвется на куски
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	l.Info("трейсинг включен",
+		logger.Field{Key: "endpoint", Value: endpoint},
+		logger.Field{Key: "sample_ratio", Value: sampleRatio},
+	)
+
+	return provider.Shutdown, nil
+}
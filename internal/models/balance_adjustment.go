@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// BalanceAdjustment ручное изменение баланса оператором
+type BalanceAdjustment struct {
+	ID     string `db:"id"`
+	UserID string `db:"user_id"`
+	// Delta на сколько рублей изменили баланс, может быть отрицательной
+	Delta int `db:"delta"`
+	// Reason причина, обязательна: без нее потом не разобраться
+	Reason string `db:"reason"`
+	// Actor кто изменил (имя ключа admin API)
+	Actor     string    `db:"actor"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// CreateBalanceAdjustmentDTO данные для новой записи
+type CreateBalanceAdjustmentDTO struct {
+	ID     string
+	UserID string
+	Delta  int
+	Reason string
+	Actor  string
+}
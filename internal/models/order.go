@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Order заказ подписки с сайта
+type Order struct {
+	ID     string `db:"id"`
+	UserID string `db:"user_id"`
+	Months int    `db:"months"`
+	// Price цена тарифа в рублях, списывается с баланса при активации
+	Price int `db:"price"`
+	// TransactionID пополнение, которым оплачивается заказ. nil если хватило баланса
+	TransactionID *string `db:"transaction_id"`
+	// PaymentURL ссылка на оплату, чтобы показать ее еще раз
+	PaymentURL string    `db:"payment_url"`
+	Status     string    `db:"status"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// CreateOrderDTO данные для нового заказа
+type CreateOrderDTO struct {
+	ID            string
+	UserID        string
+	Months        int
+	Price         int
+	TransactionID *string
+	PaymentURL    string
+	Status        string
+}
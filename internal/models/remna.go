@@ -62,10 +62,14 @@ type UsersResponse struct {
 
 // User описывает данные одного пользователя.
 type user struct {
-	ID          int         `json:"id"`
-	Username    string      `json:"username"`
-	Status      string      `json:"status"`
-	UserTraffic userTraffic `json:"userTraffic"`
+	ID                int         `json:"id"`
+	UUID              string      `json:"uuid"`
+	Username          string      `json:"username"`
+	Status            string      `json:"status"`
+	TrafficLimitBytes int64       `json:"trafficLimitBytes"`
+	UserTraffic       userTraffic `json:"userTraffic"`
+	// LastTrafficResetAt когда панель последний раз сбрасывала трафик
+	LastTrafficResetAt *time.Time `json:"lastTrafficResetAt"`
 	// Можно добавить остальные поля по необходимости
 }
 
@@ -88,6 +92,16 @@ type user struct {
 // 	APIToken       string // Токен для API запросов (из REMNA_TOKEN)
 // }
 
+// UserPlan параметры тарифа для нового пользователя панели
+type UserPlan struct {
+	// SquadUUID сквад выбранного региона, пусто - сквад по умолчанию
+	SquadUUID string
+	// TrafficLimitBytes лимит трафика, 0 - безлимит
+	TrafficLimitBytes int64
+	// TrafficLimitStrategy когда обнулять трафик: NO_RESET, DAY, WEEK, MONTH
+	TrafficLimitStrategy string
+}
+
 type CreateRequestUserDTO struct {
 	Username             string `json:"username"`
 	Status               string `json:"status"`
@@ -121,6 +135,57 @@ type ActiveInternalSquad struct {
 	Name string `json:"name"`
 }
 
+// InternalSquad внутренний сквад панели: набор нод и инбаундов.
+// Для пользователя это регион подключения
+type InternalSquad struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	Info struct {
+		MembersCount  int `json:"membersCount"`
+		InboundsCount int `json:"inboundsCount"`
+	} `json:"info"`
+}
+
+// HWIDDevice устройство пользователя, которое подключалось по подписке
+type HWIDDevice struct {
+	HWID        string    `json:"hwid"`
+	UserUUID    string    `json:"userUuid"`
+	Platform    *string   `json:"platform"`
+	OSVersion   *string   `json:"osVersion"`
+	DeviceModel *string   `json:"deviceModel"`
+	UserAgent   *string   `json:"userAgent"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// HWIDDevicesResponse ответ /api/hwid/devices/{userUuid}
+type HWIDDevicesResponse struct {
+	Response struct {
+		Total   int          `json:"total"`
+		Devices []HWIDDevice `json:"devices"`
+	} `json:"response"`
+}
+
+// DeleteHWIDDeviceRequest тело запроса на отвязку устройства
+type DeleteHWIDDeviceRequest struct {
+	UserUUID string `json:"userUuid"`
+	HWID     string `json:"hwid"`
+}
+
+// RevokeSubscriptionRequest тело запроса на перевыпуск подписки.
+// RevokeOnlyPasswords=false меняет и ссылку, и пароли подключений
+type RevokeSubscriptionRequest struct {
+	RevokeOnlyPasswords bool `json:"revokeOnlyPasswords"`
+}
+
+// InternalSquadsResponse ответ /api/internal-squads
+type InternalSquadsResponse struct {
+	Response struct {
+		Total          int             `json:"total"`
+		InternalSquads []InternalSquad `json:"internalSquads"`
+	} `json:"response"`
+}
+
 // UserTraffic Информация о трафике пользователя
 type userTraffic struct {
 	UsedTrafficBytes         uint64    `json:"usedTrafficBytes"`
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Setting настройка, измененная админом без рестарта
+type Setting struct {
+	Key   string `db:"key"`
+	Value string `db:"value"`
+	// UpdatedBy кто изменил (telegram id админа)
+	UpdatedBy string    `db:"updated_by"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Transaction платеж пользователя (пополнение баланса)
+type Transaction struct {
+	ID     string `db:"id"`
+	UserID string `db:"user_id"`
+	// Amount сумма в рублях, на которую пополняется баланс
+	Amount int `db:"amount"`
+	// Fee комиссия платежной системы сверх Amount. Пользователь платит Amount + Fee
+	Fee      int    `db:"fee"`
+	Status   string `db:"status"`
+	Provider string `db:"provider"`
+	// ExternalID id платежа у провайдера. Пусто, пока провайдер его не выдал
+	ExternalID *string   `db:"external_id"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// CreateTransactionDTO данные для новой транзакции
+type CreateTransactionDTO struct {
+	ID       string
+	UserID   string
+	Amount   int
+	Fee      int
+	Status   string
+	Provider string
+}
@@ -3,9 +3,24 @@ package models
 import "time"
 
 type UserTG struct {
-	ID        string    `db:"id"`
-	Balance   int       `db:"balance"`
-	Trial     bool      `db:"trial"`
+	ID       string `db:"id"`
+	Balance  int    `db:"balance"`
+	Trial    bool   `db:"trial"`
+	Banned   bool   `db:"banned"`
+	Language string `db:"language"`
+	// Region uuid выбранного сквада панели, пусто - сквад по умолчанию
+	Region string `db:"region"`
+	// ExtraDevices сколько устройств куплено сверх подписки
+	ExtraDevices int `db:"extra_devices"`
+	// TrafficNotified последнее отправленное предупреждение о трафике в
+	// процентах, 0 - не отправляли с последнего сброса
+	TrafficNotified int `db:"traffic_notified"`
+	// TrafficPacksGB пакеты трафика, купленные в текущем периоде сброса
+	TrafficPacksGB int `db:"traffic_packs_gb"`
+	// TrafficPacksAt когда куплен первый пакет периода. nil - пакетов нет
+	TrafficPacksAt *time.Time `db:"traffic_packs_at"`
+	// Email для входа на сайт. nil у пользователей только из телеграма
+	Email     *string   `db:"email"`
 	CreatedAt time.Time `db:"created_at"`
 }
 
@@ -13,13 +28,24 @@ type CreateUserTGDTO struct {
 	ID      string `db:"id"`
 	Balance int    `db:"balance"`
 	Trial   bool   `db:"trial"`
+	// Email задается при регистрации через сайт
+	Email *string `db:"email"`
 }
 
 type UpdateUserTGDTO struct {
 	// Ставим * для надежности. Чтобы передавали значения через &
-	// Иначе не указав явно Balance он бы при каждом запросе
-	// ставился бы на 0. А так он остается таким же, как был.
+	// Иначе не указав явно Trial он бы при каждом запросе
+	// ставился бы на false. А так он остается таким же, как был.
 	// Потому что ставится nil, если значение не указано.
-	Balance *int
-	Trial   *bool
+	// Баланса тут нет: он меняется только относительно, через AddBalance
+	Trial  *bool
+	Banned *bool
+	// Language пустая строка значит "как в телеграме"
+	Language *string
+	// Region uuid сквада панели, пустая строка - сквад по умолчанию
+	Region *string
+	// ExtraDevices докупленные устройства
+	ExtraDevices *int
+	// TrafficNotified порог последнего предупреждения о трафике
+	TrafficNotified *int
 }
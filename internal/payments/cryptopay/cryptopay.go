@@ -0,0 +1,266 @@
+// Package cryptopay реализация клиента Crypto Pay API (CryptoBot).
+// Счет выставляется в криптовалюте (по умолчанию USDT), сумма в рублях
+// пересчитывается по курсу из RateSource.
+package cryptopay
+
+import (
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// invoiceTTL сколько живет неоплаченный счет
+const invoiceTTL = time.Hour
+
+// Проверяем на этапе компиляции, что Client реализует интерфейс
+var _ domain.PaymentGateway = (*Client)(nil)
+
+// NewClient создает новый экземпляр клиента Crypto Pay.
+// Курс по умолчанию берется из getExchangeRates, поменять можно через SetRateSource
+func NewClient(baseURL, token, asset string, l logger.Logger) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if asset == "" {
+		asset = DefaultAsset
+	}
+
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		asset:   asset,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "cryptopay " + r.Method + " " + r.URL.Path
+				}),
+			),
+		},
+		logger: l,
+	}
+	c.rates = NewAPIRates(c, 5*time.Minute)
+
+	return c
+}
+
+// SetRateSource меняет источник курса (например на фиксированный из конфига)
+func (c *Client) SetRateSource(rates RateSource) {
+	c.rates = rates
+}
+
+// Asset криптовалюта, в которой выставляются счета
+func (c *Client) Asset() string {
+	return c.asset
+}
+
+// logDuration логирует время выполнения метода.
+func (c *Client) logDuration(method string) func() {
+	start := time.Now()
+	return func() {
+		c.logger.Info("вызов метода завершен",
+			logger.Field{Key: "method", Value: method},
+			logger.Field{Key: "duration", Value: time.Since(start)},
+		)
+	}
+}
+
+// CreateTransaction создает счет на amount рублей. orderID - наш id транзакции,
+// он вернется в webhook. Возвращает ссылку на оплату и id счета в Crypto Pay
+func (c *Client) CreateTransaction(ctx context.Context, amount float64, orderID string) (string, string, error) {
+	defer c.logDuration("CreateTransaction")()
+
+	assetAmount, err := c.toAsset(ctx, amount)
+	if err != nil {
+		return "", "", fmt.Errorf("cryptopay.CreateTransaction: %w", err)
+	}
+
+	invoice, err := c.CreateInvoice(ctx, CreateInvoiceRequest{
+		CurrencyType: "crypto",
+		Asset:        c.asset,
+		Amount:       assetAmount,
+		Description:  fmt.Sprintf("Пополнение баланса на %.0f ₽", amount),
+		Payload:      orderID,
+		ExpiresIn:    int(invoiceTTL.Seconds()),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("cryptopay.CreateTransaction: %w", err)
+	}
+
+	return invoice.BotInvoiceURL, strconv.FormatInt(invoice.InvoiceID, 10), nil
+}
+
+// CheckStatus статус счета. transactionID - id счета, который вернул CreateTransaction
+func (c *Client) CheckStatus(ctx context.Context, transactionID string) (domain.PaymentStatus, error) {
+	invoice, err := c.GetInvoice(ctx, transactionID)
+	if err != nil {
+		return "", fmt.Errorf("cryptopay.CheckStatus: %w", err)
+	}
+
+	return invoice.PaymentStatus(), nil
+}
+
+// GetTransactionInfo информация о счете. transactionID - id счета в Crypto Pay
+func (c *Client) GetTransactionInfo(ctx context.Context, transactionID string) (domain.TransactionInfo, error) {
+	invoice, err := c.GetInvoice(ctx, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("cryptopay.GetTransactionInfo: %w", err)
+	}
+
+	return invoice, nil
+}
+
+// CreateInvoice вызывает createInvoice
+func (c *Client) CreateInvoice(ctx context.Context, req CreateInvoiceRequest) (*Invoice, error) {
+	var invoice Invoice
+	if err := call(ctx, c, "createInvoice", req, &invoice); err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// GetInvoice получает счет по id через getInvoices
+func (c *Client) GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	var result getInvoicesResult
+	if err := call(ctx, c, "getInvoices", getInvoicesRequest{InvoiceIDs: invoiceID}, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Items) == 0 {
+		return nil, fmt.Errorf("счет %s не найден", invoiceID)
+	}
+
+	return &result.Items[0], nil
+}
+
+// GetExchangeRates вызывает getExchangeRates
+func (c *Client) GetExchangeRates(ctx context.Context) ([]ExchangeRate, error) {
+	var rates []ExchangeRate
+	if err := call(ctx, c, "getExchangeRates", nil, &rates); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// toAsset переводит рубли в сумму криптовалюты строкой. Округляем вверх
+// до центов, чтобы не зачислить больше, чем заплачено
+func (c *Client) toAsset(ctx context.Context, rub float64) (string, error) {
+	rate, err := c.rates.Rate(ctx, c.asset)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения курса %s: %w", c.asset, err)
+	}
+	if rate <= 0 {
+		return "", fmt.Errorf("неверный курс %s: %v", c.asset, rate)
+	}
+
+	amount := math.Ceil(rub/rate*100) / 100
+
+	return strconv.FormatFloat(amount, 'f', 2, 64), nil
+}
+
+// call выполняет метод API и разбирает result в out.
+// Отдельная функция, потому что у методов Go не бывает type параметров
+func call[T any](ctx context.Context, c *Client, method string, params any, out *T) error {
+	if c.token == "" {
+		return fmt.Errorf("cryptopay.%s: токен не установлен", method)
+	}
+
+	body := []byte("{}")
+	if params != nil {
+		var err error
+		body, err = json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("cryptopay.%s: ошибка маршалинга: %w", method, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cryptopay.%s: ошибка создания запроса: %w", method, err)
+	}
+	req.Header.Set("Crypto-Pay-API-Token", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cryptopay.%s: ошибка получения ответа: %w", method, err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			c.logger.Error(
+				"ошибка про закрытии тела ответа",
+				logger.Field{Key: "method", Value: method},
+				logger.Field{Key: "err_msg", Value: closeErr},
+			)
+		}
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cryptopay.%s: ошибка чтения тела ответа: %w", method, err)
+	}
+
+	var parsed apiResponse[T]
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return fmt.Errorf("cryptopay.%s: код статуса: %v, ошибка анмаршалинга ответа: %w", method, resp.StatusCode, err)
+	}
+
+	if !parsed.OK {
+		if parsed.Error != nil {
+			return fmt.Errorf("cryptopay.%s: ошибка API %d %s", method, parsed.Error.Code, parsed.Error.Name)
+		}
+
+		return fmt.Errorf("cryptopay.%s: код статуса: %v\nОшибка: %s", method, resp.StatusCode, string(respBody))
+	}
+
+	*out = parsed.Result
+
+	return nil
+}
+
+// PaymentStatus статус счета в терминах domain
+func (i *Invoice) PaymentStatus() domain.PaymentStatus {
+	switch i.Status {
+	case InvoiceStatusPaid:
+		return domain.PaymentStatusSuccess
+	case InvoiceStatusExpired:
+		return domain.PaymentStatusFailed
+	default:
+		return domain.PaymentStatusPending
+	}
+}
+
+// GetID id счета в Crypto Pay
+func (i *Invoice) GetID() string {
+	return strconv.FormatInt(i.InvoiceID, 10)
+}
+
+// GetAmount сумма счета в криптовалюте
+func (i *Invoice) GetAmount() float64 {
+	amount, _ := strconv.ParseFloat(i.Amount, 64)
+
+	return amount
+}
+
+// GetStatus статус счета как его прислал Crypto Pay
+func (i *Invoice) GetStatus() string {
+	return i.Status
+}
+
+// GetRawResponse счет целиком
+func (i *Invoice) GetRawResponse() any {
+	return i
+}
@@ -0,0 +1,107 @@
+// Package cryptopay описывает взаимодействие с Crypto Pay API (CryptoBot).
+package cryptopay
+
+import (
+	"ProxyMaster_v2/pkg/logger"
+	"net/http"
+)
+
+// Provider имя провайдера в таблице transactions
+const Provider = "cryptopay"
+
+// DefaultBaseURL адрес боевого API. Для тестов есть https://testnet-pay.crypt.bot
+const DefaultBaseURL = "https://pay.crypt.bot"
+
+// DefaultAsset криптовалюта счета по умолчанию
+const DefaultAsset = "USDT"
+
+// Статусы счета в Crypto Pay
+const (
+	InvoiceStatusActive  = "active"
+	InvoiceStatusPaid    = "paid"
+	InvoiceStatusExpired = "expired"
+)
+
+// updateTypeInvoicePaid тип webhook уведомления об оплате
+const updateTypeInvoicePaid = "invoice_paid"
+
+// signatureHeader заголовок с подписью webhook
+const signatureHeader = "Crypto-Pay-Api-Signature"
+
+// Client что нужно для работы с Crypto Pay
+type Client struct {
+	baseURL    string
+	token      string
+	asset      string
+	rates      RateSource
+	httpClient *http.Client
+	logger     logger.Logger
+}
+
+// apiResponse общий ответ Crypto Pay: {"ok": true, "result": ...} или {"ok": false, "error": ...}
+type apiResponse[T any] struct {
+	OK     bool      `json:"ok"`
+	Result T         `json:"result"`
+	Error  *apiError `json:"error,omitempty"`
+}
+
+// apiError описание ошибки от Crypto Pay
+type apiError struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+// CreateInvoiceRequest запрос на создание счета.
+type CreateInvoiceRequest struct {
+	CurrencyType string `json:"currency_type"`
+	Asset        string `json:"asset"`
+	// Amount сумма строкой, например "1.25"
+	Amount      string `json:"amount"`
+	Description string `json:"description,omitempty"`
+	// Payload наш id транзакции, вернется в webhook
+	Payload   string `json:"payload"`
+	ExpiresIn int    `json:"expires_in,omitempty"`
+}
+
+// getInvoicesRequest запрос на получение счетов по id.
+type getInvoicesRequest struct {
+	InvoiceIDs string `json:"invoice_ids"`
+}
+
+// getInvoicesResult ответ getInvoices.
+type getInvoicesResult struct {
+	Items []Invoice `json:"items"`
+}
+
+// Invoice счет Crypto Pay.
+type Invoice struct {
+	InvoiceID     int64  `json:"invoice_id"`
+	Hash          string `json:"hash"`
+	CurrencyType  string `json:"currency_type"`
+	Asset         string `json:"asset"`
+	Amount        string `json:"amount"`
+	PaidAsset     string `json:"paid_asset,omitempty"`
+	PaidAmount    string `json:"paid_amount,omitempty"`
+	BotInvoiceURL string `json:"bot_invoice_url"`
+	Description   string `json:"description,omitempty"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"created_at"`
+	PaidAt        string `json:"paid_at,omitempty"`
+	Payload       string `json:"payload,omitempty"`
+}
+
+// ExchangeRate курс из getExchangeRates.
+type ExchangeRate struct {
+	IsValid bool   `json:"is_valid"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Rate    string `json:"rate"`
+}
+
+// WebhookUpdate уведомление от Crypto Pay.
+type WebhookUpdate struct {
+	UpdateID    int64   `json:"update_id"`
+	UpdateType  string  `json:"update_type"`
+	RequestDate string  `json:"request_date"`
+	Payload     Invoice `json:"payload"`
+}
@@ -0,0 +1,322 @@
+package cryptopay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// testToken токен API в тестах
+const testToken = "12345:AAtesttokentesttoken"
+
+// newTestLogger логгер, который пишет только ошибки
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+
+	levels, err := logger.NewLevels("error", "")
+	if err != nil {
+		t.Fatalf("NewLevels: %v", err)
+	}
+	l, err := logger.New(logger.Options{Levels: levels})
+	if err != nil {
+		t.Fatalf("logger.New: %v", err)
+	}
+
+	return l
+}
+
+// fakeAPI фейковый Crypto Pay: отвечает result из results по имени метода
+// и запоминает тела запросов
+type fakeAPI struct {
+	t       *testing.T
+	mu      sync.Mutex
+	results map[string]string
+	bodies  map[string][]byte
+	calls   map[string]int
+}
+
+// newFakeAPI поднимает httptest сервер и клиент, который в него ходит
+func newFakeAPI(t *testing.T, results map[string]string) (*fakeAPI, *Client) {
+	t.Helper()
+
+	api := &fakeAPI{t: t, results: results, bodies: map[string][]byte{}, calls: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(server.Close)
+
+	return api, NewClient(server.URL, testToken, "", newTestLogger(t))
+}
+
+// serve обработчик запросов к /api/<method>
+func (a *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[len("/api/"):]
+
+	if got := r.Header.Get("Crypto-Pay-API-Token"); got != testToken {
+		a.t.Errorf("%s: токен %q, ожидали %q", method, got, testToken)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	a.mu.Lock()
+	a.bodies[method] = body
+	a.calls[method]++
+	result, ok := a.results[method]
+	a.mu.Unlock()
+
+	if !ok {
+		_, _ = io.WriteString(w, `{"ok":false,"error":{"code":405,"name":"METHOD_NOT_FOUND"}}`)
+		return
+	}
+	_, _ = io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+}
+
+// body тело последнего запроса к method
+func (a *fakeAPI) body(method string) map[string]any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var parsed map[string]any
+	if err := json.Unmarshal(a.bodies[method], &parsed); err != nil {
+		a.t.Fatalf("%s: тело запроса %q: %v", method, a.bodies[method], err)
+	}
+
+	return parsed
+}
+
+// count сколько раз вызывали method
+func (a *fakeAPI) count(method string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.calls[method]
+}
+
+// exchangeRates ответ getExchangeRates: 1 USDT = 90 ₽, 1 TON = 300 ₽
+const exchangeRates = `[
+	{"is_valid":true,"source":"USDT","target":"RUB","rate":"90"},
+	{"is_valid":true,"source":"USDT","target":"USD","rate":"1"},
+	{"is_valid":false,"source":"BTC","target":"RUB","rate":"1"},
+	{"is_valid":true,"source":"TON","target":"RUB","rate":"300"}
+]`
+
+func TestCreateTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		rub        float64
+		rates      RateSource
+		wantAmount string
+	}{
+		{name: "курс из API", rub: 100, wantAmount: "1.12"},
+		{name: "округляем вверх до центов", rub: 90, wantAmount: "1.00"},
+		{name: "фиксированный курс", rub: 150, rates: FixedRate(100), wantAmount: "1.50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, client := newFakeAPI(t, map[string]string{
+				"getExchangeRates": exchangeRates,
+				"createInvoice":    `{"invoice_id":42,"status":"active","amount":"1.12","bot_invoice_url":"https://t.me/CryptoBot?start=IV42"}`,
+			})
+			if tt.rates != nil {
+				client.SetRateSource(tt.rates)
+			}
+
+			url, id, err := client.CreateTransaction(context.Background(), tt.rub, "order-1")
+			if err != nil {
+				t.Fatalf("CreateTransaction: %v", err)
+			}
+			if url != "https://t.me/CryptoBot?start=IV42" || id != "42" {
+				t.Errorf("CreateTransaction = %q, %q", url, id)
+			}
+
+			body := api.body("createInvoice")
+			want := map[string]any{
+				"currency_type": "crypto",
+				"asset":         DefaultAsset,
+				"amount":        tt.wantAmount,
+				"payload":       "order-1",
+				"expires_in":    float64(invoiceTTL.Seconds()),
+			}
+			for key, value := range want {
+				if body[key] != value {
+					t.Errorf("createInvoice %s = %v, ожидали %v", key, body[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateTransactionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		results map[string]string
+		rates   RateSource
+	}{
+		{name: "нет курса валюты", results: map[string]string{"getExchangeRates": `[]`}},
+		{name: "курс не задан", rates: FixedRate(0)},
+		{name: "ошибка API", results: map[string]string{"getExchangeRates": exchangeRates}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeAPI(t, tt.results)
+			if tt.rates != nil {
+				client.SetRateSource(tt.rates)
+			}
+
+			if _, _, err := client.CreateTransaction(context.Background(), 100, "order-1"); err == nil {
+				t.Fatal("CreateTransaction без ошибки")
+			}
+		})
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   domain.PaymentStatus
+	}{
+		{status: InvoiceStatusActive, want: domain.PaymentStatusPending},
+		{status: InvoiceStatusPaid, want: domain.PaymentStatusSuccess},
+		{status: InvoiceStatusExpired, want: domain.PaymentStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			api, client := newFakeAPI(t, map[string]string{
+				"getInvoices": `{"items":[{"invoice_id":42,"status":"` + tt.status + `","amount":"1.12"}]}`,
+			})
+
+			got, err := client.CheckStatus(context.Background(), "42")
+			if err != nil {
+				t.Fatalf("CheckStatus: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckStatus = %q, ожидали %q", got, tt.want)
+			}
+			if ids := api.body("getInvoices")["invoice_ids"]; ids != "42" {
+				t.Errorf("getInvoices invoice_ids = %v", ids)
+			}
+		})
+	}
+}
+
+func TestGetInvoiceNotFound(t *testing.T) {
+	_, client := newFakeAPI(t, map[string]string{"getInvoices": `{"items":[]}`})
+
+	if _, err := client.GetInvoice(context.Background(), "42"); err == nil {
+		t.Fatal("GetInvoice без ошибки для несуществующего счета")
+	}
+}
+
+func TestAPIRatesCache(t *testing.T) {
+	api, client := newFakeAPI(t, map[string]string{"getExchangeRates": exchangeRates})
+	rates := NewAPIRates(client, time.Hour)
+
+	for asset, want := range map[string]float64{"USDT": 90, "TON": 300} {
+		got, err := rates.Rate(context.Background(), asset)
+		if err != nil {
+			t.Fatalf("Rate(%s): %v", asset, err)
+		}
+		if got != want {
+			t.Errorf("Rate(%s) = %v, ожидали %v", asset, got, want)
+		}
+	}
+
+	if _, err := rates.Rate(context.Background(), "BTC"); err == nil {
+		t.Error("Rate(BTC) без ошибки, хотя курс невалидный")
+	}
+	if n := api.count("getExchangeRates"); n != 1 {
+		t.Errorf("getExchangeRates вызван %d раз, ожидали 1", n)
+	}
+}
+
+func TestAPIRatesKeepsStaleRate(t *testing.T) {
+	api, client := newFakeAPI(t, map[string]string{"getExchangeRates": exchangeRates})
+	rates := NewAPIRates(client, time.Hour)
+
+	if _, err := rates.Rate(context.Background(), "USDT"); err != nil {
+		t.Fatalf("Rate: %v", err)
+	}
+
+	// Курс устарел, а API сломался: отдаем старый
+	rates.updatedAt = time.Now().Add(-2 * time.Hour)
+	api.mu.Lock()
+	delete(api.results, "getExchangeRates")
+	api.mu.Unlock()
+
+	got, err := rates.Rate(context.Background(), "USDT")
+	if err != nil {
+		t.Fatalf("Rate со старым курсом: %v", err)
+	}
+	if got != 90 {
+		t.Errorf("Rate = %v, ожидали 90", got)
+	}
+}
+
+func TestAPIRatesSingleRefresh(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = io.WriteString(w, `{"ok":true,"result":`+exchangeRates+`}`)
+	}))
+	t.Cleanup(server.Close)
+
+	rates := NewAPIRates(NewClient(server.URL, testToken, "", newTestLogger(t)), time.Hour)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rates.Rate(context.Background(), "USDT")
+			errs <- err
+		}()
+	}
+
+	// Пока курс обновляется, mu свободен: ожидающий с отмененным ctx сразу выходит
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := rates.Rate(ctx, "USDT")
+		canceled <- err
+	}()
+	select {
+	case err := <-canceled:
+		if err == nil {
+			t.Error("Rate с отмененным ctx без ошибки")
+		}
+	case <-time.After(time.Second):
+		t.Error("Rate ждет mu, пока идет запрос в API")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Rate: %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("getExchangeRates вызван %d раз, ожидали 1", n)
+	}
+}
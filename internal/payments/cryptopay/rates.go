@@ -0,0 +1,146 @@
+// Package cryptopay источники курса криптовалюты к рублю.
+package cryptopay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Источники курса для конфига
+const (
+	RateSourceAPI   = "api"
+	RateSourceFixed = "fixed"
+)
+
+// RateSource откуда берем курс: сколько рублей стоит 1 единица asset
+type RateSource interface {
+	Rate(ctx context.Context, asset string) (float64, error)
+}
+
+// FixedRate курс из конфига, одинаковый для любой криптовалюты
+type FixedRate float64
+
+// Rate возвращает заданный курс
+func (r FixedRate) Rate(_ context.Context, _ string) (float64, error) {
+	if r <= 0 {
+		return 0, fmt.Errorf("курс не задан")
+	}
+
+	return float64(r), nil
+}
+
+// APIRates курс из getExchangeRates Crypto Pay с кэшем,
+// чтобы не ходить в API на каждый счет
+type APIRates struct {
+	client *Client
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	rates     map[string]float64
+	updatedAt time.Time
+	// refreshing закрывается, когда закончится текущее обновление курса.
+	// nil - никто не обновляет
+	refreshing chan struct{}
+	// refreshErr ошибка последнего обновления
+	refreshErr error
+}
+
+// NewAPIRates конструктор. ttl - сколько держим курс в кэше
+func NewAPIRates(client *Client, ttl time.Duration) *APIRates {
+	return &APIRates{client: client, ttl: ttl}
+}
+
+// Rate курс asset к рублю. В API ходим без блокировки: пока один запрос
+// обновляет курс, остальные ждут его результат, а не встают в очередь за mu
+func (r *APIRates) Rate(ctx context.Context, asset string) (float64, error) {
+	r.mu.Lock()
+	stale := r.rates == nil || time.Since(r.updatedAt) > r.ttl
+	r.mu.Unlock()
+
+	if stale {
+		if err := r.refresh(ctx); err != nil {
+			r.mu.Lock()
+			hasRates := r.rates != nil
+			r.mu.Unlock()
+
+			// Старый курс лучше, чем никакого: API мог просто моргнуть
+			if !hasRates {
+				return 0, err
+			}
+			r.client.logger.Warn("не удалось обновить курс, используем старый")
+		}
+	}
+
+	r.mu.Lock()
+	rate, ok := r.rates[asset]
+	r.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("нет курса %s/RUB", asset)
+	}
+
+	return rate, nil
+}
+
+// refresh обновляет курс. Если обновление уже идет, ждет его и возвращает
+// его ошибку, а не делает второй запрос
+func (r *APIRates) refresh(ctx context.Context) error {
+	r.mu.Lock()
+	if done := r.refreshing; done != nil {
+		r.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		return r.refreshErr
+	}
+
+	done := make(chan struct{})
+	r.refreshing = done
+	r.mu.Unlock()
+
+	rates, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	if err == nil {
+		r.rates = rates
+		r.updatedAt = time.Now()
+	}
+	r.refreshErr = err
+	r.refreshing = nil
+	r.mu.Unlock()
+	close(done)
+
+	return err
+}
+
+// fetch загружает курсы всех криптовалют к рублю
+func (r *APIRates) fetch(ctx context.Context) (map[string]float64, error) {
+	list, err := r.client.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64)
+	for _, item := range list {
+		if !item.IsValid || item.Target != "RUB" {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(item.Rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверный курс %s/RUB %q: %w", item.Source, item.Rate, err)
+		}
+		rates[item.Source] = rate
+	}
+
+	return rates, nil
+}
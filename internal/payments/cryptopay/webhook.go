@@ -0,0 +1,79 @@
+// Package cryptopay прием webhook уведомлений об оплате от Crypto Pay.
+package cryptopay
+
+import (
+	"ProxyMaster_v2/pkg/logger"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxWebhookBody уведомление об оплате маленькое, больше не читаем
+const maxWebhookBody = 1 << 20
+
+// PaidFunc вызывается на оплаченный счет. Ошибка - Crypto Pay пришлет уведомление еще раз
+type PaidFunc func(ctx context.Context, invoice Invoice) error
+
+// VerifySignature проверяет подпись webhook: HMAC-SHA256 тела,
+// ключ - SHA256 от токена API
+func (c *Client) VerifySignature(body []byte, signature string) bool {
+	secret := sha256.Sum256([]byte(c.token))
+
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// WebhookHandler HTTP обработчик уведомлений. Без верной подписи
+// запрос отклоняется, чтобы никто не мог "оплатить" счет сам
+func (c *Client) WebhookHandler(onPaid PaidFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !c.VerifySignature(body, r.Header.Get(signatureHeader)) {
+			c.logger.Warn("webhook crypto pay с неверной подписью", logger.Field{Key: "remote_addr", Value: r.RemoteAddr})
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update WebhookUpdate
+		if err := json.Unmarshal(body, &update); err != nil {
+			c.logger.Error("ошибка разбора webhook crypto pay", logger.Field{Key: "error", Value: err})
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Другие типы уведомлений нам не нужны, но отвечаем 200, чтобы не слали повторно
+		if update.UpdateType != updateTypeInvoicePaid {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := onPaid(r.Context(), update.Payload); err != nil {
+			c.logger.Error("ошибка обработки оплаты crypto pay",
+				logger.Field{Key: "invoice_id", Value: update.Payload.InvoiceID},
+				logger.Field{Key: "payload", Value: update.Payload.Payload},
+				logger.Field{Key: "error", Value: err},
+			)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
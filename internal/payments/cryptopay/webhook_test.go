@@ -0,0 +1,143 @@
+package cryptopay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sign подпись тела, как ее считает Crypto Pay
+func sign(token, body string) string {
+	secret := sha256.Sum256([]byte(token))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// paidUpdate уведомление об оплате счета 42 по заказу order-1
+const paidUpdate = `{"update_id":1,"update_type":"invoice_paid","payload":{"invoice_id":42,"status":"paid","amount":"1.12","payload":"order-1"}}`
+
+func TestVerifySignature(t *testing.T) {
+	client := NewClient("", testToken, "", newTestLogger(t))
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      bool
+	}{
+		{name: "верная подпись", body: paidUpdate, signature: sign(testToken, paidUpdate), want: true},
+		{name: "подпись другим токеном", body: paidUpdate, signature: sign("other-token", paidUpdate)},
+		{name: "подпись другого тела", body: paidUpdate, signature: sign(testToken, "{}")},
+		{name: "подпись в верхнем регистре", body: paidUpdate, signature: strings.ToUpper(sign(testToken, paidUpdate))},
+		{name: "без подписи", body: paidUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := client.VerifySignature([]byte(tt.body), tt.signature); got != tt.want {
+				t.Errorf("VerifySignature = %v, ожидали %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		signature  string
+		paidErr    error
+		wantStatus int
+		wantPaid   bool
+	}{
+		{
+			name:       "оплата с верной подписью",
+			body:       paidUpdate,
+			signature:  sign(testToken, paidUpdate),
+			wantStatus: http.StatusOK,
+			wantPaid:   true,
+		},
+		{
+			name:       "неверная подпись",
+			body:       paidUpdate,
+			signature:  sign("other-token", paidUpdate),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "без подписи",
+			body:       paidUpdate,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "не POST",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "битый JSON",
+			body:       `{"update_type":`,
+			signature:  sign(testToken, `{"update_type":`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "другой тип уведомления",
+			body:       `{"update_id":2,"update_type":"other"}`,
+			signature:  sign(testToken, `{"update_id":2,"update_type":"other"}`),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ошибка зачисления: Crypto Pay повторит",
+			body:       paidUpdate,
+			signature:  sign(testToken, paidUpdate),
+			paidErr:    errors.New("db down"),
+			wantStatus: http.StatusInternalServerError,
+			wantPaid:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("", testToken, "", newTestLogger(t))
+
+			var paid []Invoice
+			handler := client.WebhookHandler(func(_ context.Context, invoice Invoice) error {
+				paid = append(paid, invoice)
+				return tt.paidErr
+			})
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/cryptopay/webhook", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(signatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("статус %d, ожидали %d", rec.Code, tt.wantStatus)
+			}
+			if !tt.wantPaid {
+				if len(paid) != 0 {
+					t.Errorf("onPaid вызван: %+v", paid)
+				}
+				return
+			}
+			if len(paid) != 1 || paid[0].InvoiceID != 42 || paid[0].Payload != "order-1" {
+				t.Errorf("onPaid получил %+v, ожидали счет 42 по order-1", paid)
+			}
+		})
+	}
+}
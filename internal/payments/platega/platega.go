@@ -2,6 +2,7 @@
 package platega
 
 import (
+	"ProxyMaster_v2/internal/config"
 	"ProxyMaster_v2/pkg/logger"
 	"bytes"
 	"context"
@@ -9,17 +10,25 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // NewClient создает новый экземпляр клиента Platega.
-func NewClient(apiKey string, l logger.Logger) *Client {
+func NewClient(cfg config.Platega, l logger.Logger) *Client {
 	return &Client{
-		baseURL: "https://app.platega.io",
-		apiKey:  apiKey,
+		baseURL:    cfg.BaseURL,
+		apiKey:     cfg.APIKey,
+		merchantID: cfg.MerchantID,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: cfg.Timeout,
+			// Спан на каждый запрос к платежке, дочерний к спану из ctx
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return "platega " + r.Method + " " + r.URL.Path
+				}),
+			),
 		},
 		logger: l,
 	}
@@ -51,28 +60,13 @@ func (c *Client) CreateTransaction(
 	description, payload string,
 ) (URL string, err error) {
 	defer c.logDuration("CreateTransaction")
-	merchantID := os.Getenv("PLATEGA_MERCHANT_ID")
-	if merchantID == "" {
-		return "", fmt.Errorf("platega.CreateTransaction: MERCHANT_ID не установлен в .env")
-	}
-
-	plategaAPIKey := c.apiKey
-	if plategaAPIKey == "" {
-		plategaAPIKey = os.Getenv("PLATEGA_API_KEY")
-	}
-	if plategaAPIKey == "" {
-		return "", fmt.Errorf("platega.CreateTransaction: PLATEGA_API_KEY не установлен (ни в клиенте, ни в .env)")
+	// Наличие ключей проверяет config при старте, здесь - защита от клиента без конфига
+	if c.merchantID == "" || c.apiKey == "" || c.baseURL == "" {
+		return "", fmt.Errorf("platega.CreateTransaction: не заданы merchant id, ключ API или адрес")
 	}
 
 	// сборка. URL
-	plategaBaseURL := c.baseURL
-	if plategaBaseURL == "" {
-		plategaBaseURL = os.Getenv("PLATEGA_BASE_URL")
-	}
-	if plategaBaseURL == "" {
-		return "", fmt.Errorf("platega.CreateTransaction: PLATEGA_BASE_URL не установлен (ни в клиенте, ни в .env)")
-	}
-	plategaTotalURL := plategaBaseURL + "/transaction/process"
+	plategaTotalURL := c.baseURL + "/transaction/process"
 
 	// сборка реквеста
 	reqBody := CreateTransactionRequest{
@@ -98,8 +92,8 @@ func (c *Client) CreateTransaction(
 	if err != nil {
 		return "", fmt.Errorf("platega.CreateTransaction: ошибка создания запроса: %w", err)
 	}
-	req.Header.Set("X-MerchantId", merchantID)
-	req.Header.Set("X-Secret", plategaAPIKey)
+	req.Header.Set("X-MerchantId", c.merchantID)
+	req.Header.Set("X-Secret", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
@@ -123,7 +117,8 @@ func (c *Client) CreateTransaction(
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("platega.CreateTransaction: код статуса: %v\nОшибка: %s", resp.StatusCode, string(respBody))
+		// Тело ошибки может содержать данные платежа и ключи, в ошибку - только замаскированное начало
+		return "", fmt.Errorf("platega.CreateTransaction: код статуса: %v\nОшибка: %s", resp.StatusCode, logger.Redact(truncate(string(respBody), 512)))
 	}
 
 	var CreateTransactionResponse CreateTransactionResponse
@@ -137,3 +132,12 @@ func (c *Client) CreateTransaction(
 
 	return URL, nil
 }
+
+// truncate первые max байт s, чтобы огромный HTML ошибки не попадал в логи целиком
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	return s[:max] + "..."
+}
@@ -47,6 +47,7 @@ type PaymentDetails struct {
 type Client struct {
 	baseURL    string
 	apiKey     string
+	merchantID string
 	httpClient *http.Client
 	logger     logger.Logger
 }
@@ -0,0 +1,92 @@
+// Package payments реестр включенных платежных систем. Каждая платежка
+// регистрируется здесь со своими условиями, а экран пополнения и сверка
+// транзакций находят ее по ID из transactions.provider.
+package payments
+
+import (
+	"fmt"
+	"math"
+
+	"ProxyMaster_v2/internal/domain"
+)
+
+// Registry реализация domain.PaymentRegistry
+type Registry struct {
+	// order порядок показа пользователю
+	order     []string
+	providers map[string]domain.PaymentProvider
+}
+
+// Проверяем на этапе компиляции, что Registry реализует интерфейс
+var _ domain.PaymentRegistry = (*Registry)(nil)
+
+// NewRegistry конструктор пустого реестра
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]domain.PaymentProvider)}
+}
+
+// Register добавляет платежку. Неверные условия - ошибка конфига,
+// лучше упасть на старте, чем показать пользователю кривой экран
+func (r *Registry) Register(p domain.PaymentProvider) error {
+	switch {
+	case p.ID == "":
+		return fmt.Errorf("payments: пустой ID провайдера")
+	case p.Gateway == nil:
+		return fmt.Errorf("payments: у провайдера %s нет gateway", p.ID)
+	case p.MinAmount < 0 || p.MaxAmount < 0:
+		return fmt.Errorf("payments: у провайдера %s отрицательный лимит", p.ID)
+	case p.MaxAmount > 0 && p.MinAmount > p.MaxAmount:
+		return fmt.Errorf("payments: у провайдера %s минимум %d больше максимума %d", p.ID, p.MinAmount, p.MaxAmount)
+	case p.FeePercent < 0 || p.FeeFixed < 0:
+		return fmt.Errorf("payments: у провайдера %s отрицательная комиссия", p.ID)
+	}
+
+	if _, ok := r.providers[p.ID]; ok {
+		return fmt.Errorf("payments: провайдер %s уже зарегистрирован", p.ID)
+	}
+	if p.Name == "" {
+		p.Name = p.ID
+	}
+
+	r.providers[p.ID] = p
+	r.order = append(r.order, p.ID)
+
+	return nil
+}
+
+// Providers все провайдеры в порядке регистрации
+func (r *Registry) Providers() []domain.PaymentProvider {
+	out := make([]domain.PaymentProvider, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.providers[id])
+	}
+
+	return out
+}
+
+// Provider провайдер по ID
+func (r *Registry) Provider(id string) (domain.PaymentProvider, bool) {
+	p, ok := r.providers[id]
+
+	return p, ok
+}
+
+// Fee комиссия за пополнение на amount рублей. Процент округляем вверх до рубля
+func (r *Registry) Fee(id string, amount int) int {
+	p, ok := r.providers[id]
+	if !ok {
+		return 0
+	}
+
+	return int(math.Ceil(float64(amount)*p.FeePercent/100)) + p.FeeFixed
+}
+
+// Allows входит ли amount в лимиты провайдера
+func (r *Registry) Allows(id string, amount int) bool {
+	p, ok := r.providers[id]
+	if !ok || amount <= 0 {
+		return false
+	}
+
+	return amount >= p.MinAmount && (p.MaxAmount == 0 || amount <= p.MaxAmount)
+}
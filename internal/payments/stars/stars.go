@@ -0,0 +1,233 @@
+// Package stars реализация оплаты через Telegram Stars (валюта XTR).
+// Внешний эквайринг не нужен: счет отправляется прямо в чат бота,
+// а телеграм сам присылает pre_checkout_query и successful_payment.
+package stars
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"ProxyMaster_v2/internal/delivery/telegram"
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Provider имя провайдера в таблице transactions
+const Provider = "telegram_stars"
+
+// Currency код валюты Telegram Stars
+const Currency = "XTR"
+
+// ErrInvalidPayment оплата не совпадает с выставленным счетом
+var ErrInvalidPayment = errors.New("stars: оплата не совпадает со счетом")
+
+// Gateway платежная система Telegram Stars, реализует domain.PaymentGateway и domain.Refunder
+type Gateway struct {
+	messenger    telegram.Messenger
+	transactions domain.TransactionRepository
+	// users и tr нужны, чтобы подписать счет на языке пользователя
+	users domain.UserRepository
+	tr    domain.Translator
+	// rubPerStar сколько рублей баланса дает одна звезда
+	rubPerStar float64
+	logger     logger.Logger
+}
+
+// Проверяем на этапе компиляции, что Gateway реализует интерфейсы
+var (
+	_ domain.PaymentGateway = (*Gateway)(nil)
+	_ domain.Refunder       = (*Gateway)(nil)
+)
+
+// NewGateway конструктор. rubPerStar должен быть больше нуля
+func NewGateway(
+	messenger telegram.Messenger,
+	transactions domain.TransactionRepository,
+	users domain.UserRepository,
+	tr domain.Translator,
+	rubPerStar float64,
+	l logger.Logger,
+) (*Gateway, error) {
+	if rubPerStar <= 0 {
+		return nil, fmt.Errorf("stars: курс звезды должен быть больше нуля: %v", rubPerStar)
+	}
+
+	return &Gateway{
+		messenger:    messenger,
+		transactions: transactions,
+		users:        users,
+		tr:           tr,
+		rubPerStar:   rubPerStar,
+		logger:       l,
+	}, nil
+}
+
+// Stars сколько звезд стоит пополнение на amount рублей. Округляем вверх,
+// чтобы не зачислять больше, чем заплачено
+func (g *Gateway) Stars(amount int) int {
+	return int(math.Ceil(float64(amount) / g.rubPerStar))
+}
+
+// CreateTransaction отправляет пользователю счет в звездах. orderID - id уже
+// созданной транзакции, из нее берем чат и сумму пополнения, amount - сумма
+// к оплате вместе с комиссией. Ссылки на оплату нет (счет приходит в чат),
+// id платежа появится только после оплаты
+func (g *Gateway) CreateTransaction(ctx context.Context, amount float64, orderID string) (string, string, error) {
+	tx, err := g.transactions.GetTransactionByID(orderID)
+	if err != nil {
+		return "", "", fmt.Errorf("stars.CreateTransaction: %w", err)
+	}
+
+	// В личке с ботом id чата совпадает с id пользователя
+	chatID, err := strconv.ParseInt(tx.UserID, 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("stars.CreateTransaction: неверный id пользователя %s: %w", tx.UserID, err)
+	}
+
+	loc := g.localizer(ctx, tx.UserID)
+	invoice := telegram.Invoice{
+		Title:       loc.T("stars.invoice_title"),
+		Description: loc.T("stars.invoice_description", tx.Amount),
+		Payload:     tx.ID,
+		Currency:    Currency,
+		Amount:      g.Stars(int(amount)),
+	}
+
+	if err := g.messenger.SendInvoice(chatID, invoice); err != nil {
+		return "", "", fmt.Errorf("stars.CreateTransaction: %w", err)
+	}
+
+	return "", "", nil
+}
+
+// CheckStatus статус транзакции. Телеграм сам присылает оплату, поэтому
+// статус берем из нашей DB
+func (g *Gateway) CheckStatus(_ context.Context, transactionID string) (domain.PaymentStatus, error) {
+	tx, err := g.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return "", fmt.Errorf("stars.CheckStatus: %w", err)
+	}
+
+	return domain.PaymentStatus(tx.Status), nil
+}
+
+// GetTransactionInfo информация о транзакции
+func (g *Gateway) GetTransactionInfo(_ context.Context, transactionID string) (domain.TransactionInfo, error) {
+	tx, err := g.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("stars.GetTransactionInfo: %w", err)
+	}
+
+	return transactionInfo{tx: tx}, nil
+}
+
+// CheckPreCheckout проверка перед списанием: счет совпадает и еще не оплачен
+func (g *Gateway) CheckPreCheckout(payload string, userID int, currency string, totalAmount int) error {
+	tx, err := g.checkPayment(payload, userID, currency, totalAmount)
+	if err != nil {
+		return err
+	}
+
+	if tx.Status != string(domain.PaymentStatusPending) {
+		return fmt.Errorf("%w: транзакция %s в статусе %s", ErrInvalidPayment, tx.ID, tx.Status)
+	}
+
+	return nil
+}
+
+// CheckPayment проверка successful_payment. Статус не проверяем: повторное
+// уведомление об оплате не ошибка, его отсеет PaymentService.CompleteTopUp
+func (g *Gateway) CheckPayment(payload string, userID int, currency string, totalAmount int) error {
+	_, err := g.checkPayment(payload, userID, currency, totalAmount)
+
+	return err
+}
+
+// checkPayment сверяет оплату со счетом: транзакция наша, принадлежит
+// этому пользователю, валюта и сумма (вместе с комиссией) те же
+func (g *Gateway) checkPayment(payload string, userID int, currency string, totalAmount int) (*models.Transaction, error) {
+	tx, err := g.transactions.GetTransactionByID(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: транзакция %s: %w", ErrInvalidPayment, payload, err)
+	}
+
+	switch {
+	case tx.Provider != Provider:
+		return nil, fmt.Errorf("%w: транзакция %s другого провайдера %s", ErrInvalidPayment, tx.ID, tx.Provider)
+	case tx.UserID != strconv.Itoa(userID):
+		return nil, fmt.Errorf("%w: транзакция %s другого пользователя", ErrInvalidPayment, tx.ID)
+	case currency != Currency:
+		return nil, fmt.Errorf("%w: валюта %s", ErrInvalidPayment, currency)
+	case totalAmount != g.Stars(tx.Amount+tx.Fee):
+		return nil, fmt.Errorf("%w: сумма %d, ожидалось %d", ErrInvalidPayment, totalAmount, g.Stars(tx.Amount+tx.Fee))
+	}
+
+	return tx, nil
+}
+
+// Refund возвращает звезды за оплаченную транзакцию
+func (g *Gateway) Refund(_ context.Context, transactionID string) error {
+	tx, err := g.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return fmt.Errorf("stars.Refund: %w", err)
+	}
+
+	if tx.ExternalID == nil || *tx.ExternalID == "" {
+		return fmt.Errorf("stars.Refund: у транзакции %s нет id платежа телеграма", tx.ID)
+	}
+
+	userID, err := strconv.ParseInt(tx.UserID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("stars.Refund: неверный id пользователя %s: %w", tx.UserID, err)
+	}
+
+	if err := g.messenger.RefundStarPayment(userID, *tx.ExternalID); err != nil {
+		return fmt.Errorf("stars.Refund: %w", err)
+	}
+
+	g.logger.Info("звезды возвращены",
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+		logger.Field{Key: "user_id", Value: tx.UserID},
+	)
+
+	return nil
+}
+
+// localizer язык пользователя для текста счета
+func (g *Gateway) localizer(ctx context.Context, userID string) domain.Localizer {
+	user, err := g.users.GetUserByID(ctx, userID)
+	if err == nil && user.Language != "" {
+		return g.tr.For(user.Language)
+	}
+
+	return g.tr.For(g.tr.Resolve(""))
+}
+
+// transactionInfo реализация domain.TransactionInfo поверх записи из DB
+type transactionInfo struct {
+	tx *models.Transaction
+}
+
+// GetID id транзакции
+func (i transactionInfo) GetID() string {
+	return i.tx.ID
+}
+
+// GetAmount сумма в рублях
+func (i transactionInfo) GetAmount() float64 {
+	return float64(i.tx.Amount)
+}
+
+// GetStatus статус транзакции
+func (i transactionInfo) GetStatus() string {
+	return i.tx.Status
+}
+
+// GetRawResponse запись из DB, отдельного ответа от телеграма нет
+func (i transactionInfo) GetRawResponse() any {
+	return i.tx
+}
@@ -0,0 +1,257 @@
+// Package service действия операторов для admin API. Своего хранилища
+// нет: пользователи, транзакции и панель те же, что у бота, возвраты через PaymentService.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// AdminService действия операторов
+type AdminService struct {
+	users        domain.UserRepository
+	adjustments  domain.BalanceAdjustmentRepository
+	transactions domain.TransactionSearcher
+	payments     domain.PaymentService
+	remna        domain.RemnawaveClient
+	// settings лимиты тарифа для новых пользователей панели
+	settings domain.Settings
+	logger   logger.Logger
+}
+
+// NewAdminService конструктор сервиса.
+func NewAdminService(
+	users domain.UserRepository,
+	adjustments domain.BalanceAdjustmentRepository,
+	transactions domain.TransactionSearcher,
+	payments domain.PaymentService,
+	remna domain.RemnawaveClient,
+	settings domain.Settings,
+	l logger.Logger,
+) *AdminService {
+	l.Info("Создан экземпляр сервиса операторов")
+	return &AdminService{
+		users:        users,
+		adjustments:  adjustments,
+		transactions: transactions,
+		payments:     payments,
+		remna:        remna,
+		settings:     settings,
+		logger:       l,
+	}
+}
+
+// logDuration логирует время выполнения метода.
+func (s *AdminService) logDuration(method string) func() {
+	start := time.Now()
+
+	return func() {
+		s.logger.Info("вызов метода завершен",
+			logger.Field{Key: "method", Value: method},
+			logger.Field{Key: "duration", Value: time.Since(start)},
+		)
+	}
+}
+
+// logError логирует ошибку и возвращает её обернутую.
+func (s *AdminService) logError(msg string, err error, fields ...logger.Field) error {
+	allFields := append([]logger.Field{{Key: "error", Value: err}}, fields...)
+	s.logger.Error(msg, allFields...)
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// ListUsers страница пользователей. Репозиторий отдает всех, режем тут:
+// пользователей немного, а отдельный запрос с LIMIT не нужен боту
+func (s *AdminService) ListUsers(ctx context.Context, limit, offset int) ([]models.UserTG, int, error) {
+	defer s.logDuration("ListUsers")()
+
+	users, err := s.users.GetAllUsers(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+
+	total := len(users)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	return users[start:end], total, nil
+}
+
+// GetUser пользователь, его подписка в панели и журнал изменений баланса
+func (s *AdminService) GetUser(ctx context.Context, userID string) (*domain.AdminUser, error) {
+	defer s.logDuration("GetUser")()
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := s.adjustments.GetAdjustmentsByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения журнала баланса: %w", err)
+	}
+
+	result := &domain.AdminUser{User: *user, Adjustments: adjustments}
+
+	// Панель может быть недоступна, пользователя из DB все равно показываем
+	state, err := s.remnawaveState(ctx, userID)
+	switch {
+	case err == nil:
+		result.Remnawave = state
+	case errors.Is(err, domain.ErrSubscriptionNotFound):
+	default:
+		result.RemnawaveError = err.Error()
+	}
+
+	return result, nil
+}
+
+// AdjustBalance меняет баланс и пишет запись в журнал
+func (s *AdminService) AdjustBalance(ctx context.Context, userID string, delta int, reason, actor string) (*models.UserTG, error) {
+	defer s.logDuration("AdjustBalance")()
+
+	reason = strings.TrimSpace(reason)
+	if delta == 0 || reason == "" {
+		return nil, fmt.Errorf("%w: нужны ненулевая сумма и причина", domain.ErrInvalidAdjustment)
+	}
+
+	// Несуществующий пользователь - отдельная ошибка, а не нехватка баланса
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Журнал и баланс меняются вместе: в журнале нет изменений, которых не было
+	updated, err := s.adjustments.ApplyAdjustment(models.CreateBalanceAdjustmentDTO{
+		ID:     uuid.NewString(),
+		UserID: userID,
+		Delta:  delta,
+		Reason: reason,
+		Actor:  actor,
+	})
+	if errors.Is(err, domain.ErrInsufficientFunds) {
+		user, getErr := s.users.GetUserByID(ctx, userID)
+		if getErr != nil {
+			return nil, getErr
+		}
+
+		return nil, fmt.Errorf("%w: баланс %d ₽, списание %d ₽", domain.ErrInvalidAdjustment, user.Balance, -delta)
+	}
+	if err != nil {
+		return nil, s.logError("ошибка изменения баланса", err, logger.Field{Key: "user_id", Value: userID})
+	}
+
+	s.logger.Info("баланс изменен оператором",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "delta", Value: delta},
+		logger.Field{Key: "reason", Value: reason},
+		logger.Field{Key: "actor", Value: actor},
+	)
+
+	return updated, nil
+}
+
+// ExtendSubscription продлевает подписку или создает новую на days дней
+func (s *AdminService) ExtendSubscription(ctx context.Context, userID string, days int) (*domain.RemnawaveState, error) {
+	defer s.logDuration("ExtendSubscription")()
+
+	if days <= 0 {
+		return nil, fmt.Errorf("дней должно быть больше нуля: %d", days)
+	}
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userUUID, err := s.remna.GetUUIDByUsername(ctx, userID)
+	switch {
+	case errors.Is(err, remnawave.ErrNotFound):
+		err = s.remna.CreateUser(ctx, userID, days, UserPlan(s.settings, user))
+	case err == nil:
+		err = s.remna.ExtendClientSubscription(ctx, userUUID, userID, days)
+	}
+	if err != nil {
+		return nil, s.logError("ошибка продления подписки", err, logger.Field{Key: "user_id", Value: userID})
+	}
+
+	s.logger.Info("подписка продлена оператором",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "days", Value: days},
+	)
+
+	return s.remnawaveState(ctx, userID)
+}
+
+// SetSubscriptionEnabled включает или выключает подписку в панели
+func (s *AdminService) SetSubscriptionEnabled(ctx context.Context, userID string, enabled bool) (*domain.RemnawaveState, error) {
+	defer s.logDuration("SetSubscriptionEnabled")()
+
+	userUUID, err := s.remna.GetUUIDByUsername(ctx, userID)
+	if err != nil {
+		if errors.Is(err, remnawave.ErrNotFound) {
+			return nil, domain.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("ошибка поиска подписки: %w", err)
+	}
+
+	if enabled {
+		err = s.remna.EnableClient(ctx, userUUID)
+	} else {
+		err = s.remna.DisableClient(ctx, userUUID)
+	}
+	if err != nil {
+		return nil, s.logError("ошибка изменения состояния подписки", err,
+			logger.Field{Key: "user_id", Value: userID},
+			logger.Field{Key: "enabled", Value: enabled},
+		)
+	}
+
+	return s.remnawaveState(ctx, userID)
+}
+
+// SearchTransactions поиск транзакций
+func (s *AdminService) SearchTransactions(_ context.Context, filter domain.TransactionFilter) ([]models.Transaction, error) {
+	defer s.logDuration("SearchTransactions")()
+
+	return s.transactions.SearchTransactions(filter)
+}
+
+// Refund возврат через тот же PaymentService, что и /refund в боте
+func (s *AdminService) Refund(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	return s.payments.Refund(ctx, transactionID)
+}
+
+// remnawaveState подписка пользователя из панели
+func (s *AdminService) remnawaveState(ctx context.Context, userID string) (*domain.RemnawaveState, error) {
+	userUUID, err := s.remna.GetUUIDByUsername(ctx, userID)
+	if err != nil {
+		if errors.Is(err, remnawave.ErrNotFound) {
+			return nil, domain.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("ошибка поиска подписки: %w", err)
+	}
+
+	info, err := s.remna.GetUserInfo(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения подписки: %w", err)
+	}
+
+	return &domain.RemnawaveState{
+		UUID:              info.Response.UUID,
+		Status:            info.Response.Status,
+		ExpireAt:          info.Response.ExpireAt,
+		SubscriptionURL:   info.Response.SubscriptionURL,
+		TrafficLimitBytes: info.Response.TrafficLimitBytes,
+		UsedTrafficBytes:  info.Response.UserTraffic.UsedTrafficBytes,
+		OnlineAt:          info.Response.UserTraffic.OnlineAt,
+	}, nil
+}
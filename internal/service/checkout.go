@@ -0,0 +1,273 @@
+// Package service покупка подписки через сайт. Своей логики подписки тут
+// нет: заказ пополняет баланс через PaymentService, а подписку активирует
+// тот же SubscriptionService, что и в боте.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// CheckoutService заказ тарифа с сайта
+type CheckoutService struct {
+	orders        domain.OrderRepository
+	users         domain.UserRepository
+	payments      domain.PaymentService
+	subscriptions domain.SubscriptionService
+	remna         domain.RemnawaveClient
+	// settings цена месяца, меняется без рестарта
+	settings domain.Settings
+	logger   logger.Logger
+}
+
+// NewCheckoutService конструктор сервиса.
+func NewCheckoutService(
+	orders domain.OrderRepository,
+	users domain.UserRepository,
+	payments domain.PaymentService,
+	subscriptions domain.SubscriptionService,
+	remna domain.RemnawaveClient,
+	settings domain.Settings,
+	l logger.Logger,
+) *CheckoutService {
+	l.Info("Создан экземпляр сервиса заказов с сайта")
+	return &CheckoutService{
+		orders:        orders,
+		users:         users,
+		payments:      payments,
+		subscriptions: subscriptions,
+		remna:         remna,
+		settings:      settings,
+		logger:        l,
+	}
+}
+
+// logDuration логирует время выполнения метода.
+func (s *CheckoutService) logDuration(method string) func() {
+	start := time.Now()
+
+	return func() {
+		s.logger.Info("вызов метода завершен",
+			logger.Field{Key: "method", Value: method},
+			logger.Field{Key: "duration", Value: time.Since(start)},
+		)
+	}
+}
+
+// logError логирует ошибку и возвращает её обернутую.
+func (s *CheckoutService) logError(msg string, err error, fields ...logger.Field) error {
+	allFields := append([]logger.Field{{Key: "error", Value: err}}, fields...)
+	s.logger.Error(msg, allFields...)
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// Tariffs тарифы с ценами
+func (s *CheckoutService) Tariffs() []domain.Tariff {
+	tariffs := make([]domain.Tariff, 0, len(domain.TariffMonths))
+	for _, months := range domain.TariffMonths {
+		tariffs = append(tariffs, domain.Tariff{
+			Months:  months,
+			Price:   TariffPrice(s.settings, months),
+			Devices: s.settings.Devices(),
+		})
+	}
+
+	return tariffs
+}
+
+// Providers платежки с оплатой по ссылке. Звезды и прочие счета
+// в чате телеграма на сайте не работают
+func (s *CheckoutService) Providers() []domain.PaymentProvider {
+	var providers []domain.PaymentProvider
+	for _, p := range s.payments.Providers() {
+		if !p.InChat {
+			providers = append(providers, p)
+		}
+	}
+
+	return providers
+}
+
+// CreateOrder создает заказ на months месяцев. Если баланса хватает,
+// подписка активируется сразу, иначе создается счет на недостающую сумму
+// (но не меньше минимума платежки, остаток останется на балансе)
+func (s *CheckoutService) CreateOrder(ctx context.Context, userID string, months int, providerID string) (*models.Order, string, error) {
+	defer s.logDuration("CreateOrder")()
+
+	// Во время техработ новые заказы не принимаем, оплаченные доводим до конца
+	if s.settings.Maintenance() {
+		return nil, "", domain.ErrMaintenance
+	}
+	if !slices.Contains(domain.TariffMonths, months) {
+		return nil, "", fmt.Errorf("%w: %d мес.", domain.ErrUnknownTariff, months)
+	}
+	price := TariffPrice(s.settings, months)
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	// Баланса хватает: платить нечего, сразу активируем
+	if user.Balance >= price {
+		order, err := s.orders.CreateOrder(models.CreateOrderDTO{
+			ID:     uuid.NewString(),
+			UserID: userID,
+			Months: months,
+			Price:  price,
+			Status: string(domain.OrderStatusPaid),
+		})
+		if err != nil {
+			return nil, "", s.logError("ошибка создания заказа", err, logger.Field{Key: "user_id", Value: userID})
+		}
+
+		order, err = s.activate(ctx, order)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return order, "", nil
+	}
+
+	provider, ok := s.payments.Provider(providerID)
+	if !ok || provider.InChat {
+		return nil, "", fmt.Errorf("%w: %s", domain.ErrUnknownProvider, providerID)
+	}
+
+	amount := max(price-user.Balance, provider.MinAmount)
+	tx, paymentURL, err := s.payments.CreateTopUp(ctx, providerID, userID, amount)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка создания счета: %w", err)
+	}
+
+	order, err := s.orders.CreateOrder(models.CreateOrderDTO{
+		ID:            uuid.NewString(),
+		UserID:        userID,
+		Months:        months,
+		Price:         price,
+		TransactionID: &tx.ID,
+		PaymentURL:    paymentURL,
+		Status:        string(domain.OrderStatusPending),
+	})
+	if err != nil {
+		return nil, "", s.logError("ошибка создания заказа", err,
+			logger.Field{Key: "user_id", Value: userID},
+			logger.Field{Key: "transaction_id", Value: tx.ID},
+		)
+	}
+
+	s.logger.Info("создан заказ с сайта",
+		logger.Field{Key: "order_id", Value: order.ID},
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "months", Value: months},
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+	)
+
+	return order, paymentURL, nil
+}
+
+// CheckOrder сверяет оплату заказа. Деньги могли прийти webhook'ом
+// или еще висят в платежке, CheckTopUp разберется в обоих случаях
+func (s *CheckoutService) CheckOrder(ctx context.Context, userID, orderID string) (*models.Order, string, error) {
+	defer s.logDuration("CheckOrder")()
+
+	order, err := s.orders.GetOrderByID(orderID)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения заказа: %w", err)
+	}
+	// Чужой заказ для пользователя выглядит так же, как несуществующий
+	if order.UserID != userID {
+		return nil, "", domain.ErrOrderNotFound
+	}
+
+	if order.Status == string(domain.OrderStatusPending) && order.TransactionID != nil {
+		tx, _, err := s.payments.CheckTopUp(ctx, order.UserID, *order.TransactionID)
+		if err != nil {
+			return nil, "", fmt.Errorf("ошибка проверки оплаты: %w", err)
+		}
+
+		switch domain.PaymentStatus(tx.Status) {
+		case domain.PaymentStatusSuccess:
+			if _, err := s.orders.SetOrderStatus(order.ID, domain.OrderStatusPending, domain.OrderStatusPaid); err != nil {
+				return nil, "", s.logError("ошибка отметки оплаты заказа", err, logger.Field{Key: "order_id", Value: order.ID})
+			}
+			order.Status = string(domain.OrderStatusPaid)
+		case domain.PaymentStatusFailed, domain.PaymentStatusRefunded:
+			if _, err := s.orders.SetOrderStatus(order.ID, domain.OrderStatusPending, domain.OrderStatusFailed); err != nil {
+				return nil, "", s.logError("ошибка отметки неуспешного заказа", err, logger.Field{Key: "order_id", Value: order.ID})
+			}
+			order.Status = string(domain.OrderStatusFailed)
+		}
+	}
+
+	if order.Status == string(domain.OrderStatusPaid) {
+		if order, err = s.activate(ctx, order); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if order.Status != string(domain.OrderStatusActivated) {
+		return order, "", nil
+	}
+
+	return order, GetURLSubscription(ctx, s.remna, userID), nil
+}
+
+// activate активирует подписку оплаченного заказа. Заказ сначала
+// забираем (paid -> activated), чтобы параллельная проверка не списала
+// цену второй раз. При ошибке ActivateForUser возвращает цену на баланс,
+// и заказ можно вернуть в paid для повтора
+func (s *CheckoutService) activate(ctx context.Context, order *models.Order) (*models.Order, error) {
+	claimed, err := s.orders.SetOrderStatus(order.ID, domain.OrderStatusPaid, domain.OrderStatusActivated)
+	if err != nil {
+		return nil, s.logError("ошибка активации заказа", err, logger.Field{Key: "order_id", Value: order.ID})
+	}
+	if !claimed {
+		// Заказ уже активирует другой запрос, покажем актуальный статус
+		return s.orders.GetOrderByID(order.ID)
+	}
+
+	if _, err := s.subscriptions.ActivateForUser(ctx, order.UserID, order.Months); err != nil {
+		// Цена осталась списанной: повтор списал бы ее второй раз, поэтому
+		// заказ остается забранным до ручного разбора
+		if errors.Is(err, domain.ErrNotRefunded) {
+			return nil, s.logError("заказ оплачен, подписка не активирована, деньги не возвращены", err,
+				logger.Field{Key: "order_id", Value: order.ID},
+				logger.Field{Key: "user_id", Value: order.UserID},
+			)
+		}
+
+		if _, revertErr := s.orders.SetOrderStatus(order.ID, domain.OrderStatusActivated, domain.OrderStatusPaid); revertErr != nil {
+			s.logger.Error("не удалось вернуть заказ в paid",
+				logger.Field{Key: "order_id", Value: order.ID},
+				logger.Field{Key: "error", Value: revertErr},
+			)
+		}
+
+		// Нехватку баланса (потратил в боте, пока платил) отдаем как есть
+		if errors.Is(err, domain.ErrInsufficientFunds) {
+			return nil, err
+		}
+
+		return nil, s.logError("ошибка активации подписки", err, logger.Field{Key: "order_id", Value: order.ID})
+	}
+
+	s.logger.Info("заказ с сайта активирован",
+		logger.Field{Key: "order_id", Value: order.ID},
+		logger.Field{Key: "user_id", Value: order.UserID},
+		logger.Field{Key: "months", Value: order.Months},
+	)
+
+	order.Status = string(domain.OrderStatusActivated)
+
+	return order, nil
+}
@@ -0,0 +1,199 @@
+// Package service устройства подписки. Лимит устройств хранит панель, а
+// докупленные устройства - DB, чтобы они не терялись при продлении.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Проверяем на этапе компиляции, что DeviceService реализует интерфейс
+var _ domain.DeviceService = (*DeviceService)(nil)
+
+// DeviceService устройства пользователя и покупка дополнительных
+type DeviceService struct {
+	remna domain.RemnawaveClient
+	users domain.UserRepository
+	// settings сколько устройств в подписке и цена дополнительного
+	settings domain.Settings
+	metrics  domain.Metrics
+	logger   logger.Logger
+}
+
+// NewDeviceService конструктор сервиса.
+func NewDeviceService(
+	remna domain.RemnawaveClient,
+	users domain.UserRepository,
+	settings domain.Settings,
+	l logger.Logger,
+) *DeviceService {
+	l.Info("Создан экземпляр сервиса устройств")
+	return &DeviceService{
+		remna:    remna,
+		users:    users,
+		settings: settings,
+		metrics:  domain.NopMetrics{},
+		logger:   l,
+	}
+}
+
+// SetMetrics включает метрики списаний с баланса
+func (s *DeviceService) SetMetrics(m domain.Metrics) {
+	s.metrics = m
+}
+
+// DeviceLimit лимит устройств пользователя: из подписки плюс докупленные
+func DeviceLimit(settings domain.Settings, user *models.UserTG) int {
+	return settings.Devices() + user.ExtraDevices
+}
+
+// Devices устройства пользователя из панели
+func (s *DeviceService) Devices(ctx context.Context, userID string) (domain.DevicesInfo, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return domain.DevicesInfo{}, err
+	}
+
+	userUUID, err := panelUUID(ctx, s.remna, userID)
+	if err != nil {
+		return domain.DevicesInfo{}, err
+	}
+
+	hwids, err := s.remna.GetUserDevices(ctx, userUUID)
+	if err != nil {
+		return domain.DevicesInfo{}, fmt.Errorf("ошибка получения устройств: %w", err)
+	}
+
+	devices := make([]domain.Device, 0, len(hwids))
+	for _, d := range hwids {
+		devices = append(devices, domain.Device{
+			HWID:      d.HWID,
+			Platform:  deref(d.Platform),
+			OSVersion: deref(d.OSVersion),
+			Model:     deref(d.DeviceModel),
+			CreatedAt: d.CreatedAt,
+		})
+	}
+
+	return domain.DevicesInfo{
+		Devices: devices,
+		Limit:   DeviceLimit(s.settings, user),
+		Extra:   user.ExtraDevices,
+	}, nil
+}
+
+// UnlinkDevice отвязывает устройство. key ищем среди текущих устройств,
+// а не доверяем кнопке: она могла остаться от старого списка
+func (s *DeviceService) UnlinkDevice(ctx context.Context, userID, key string) error {
+	userUUID, err := panelUUID(ctx, s.remna, userID)
+	if err != nil {
+		return err
+	}
+
+	hwids, err := s.remna.GetUserDevices(ctx, userUUID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройств: %w", err)
+	}
+
+	for _, d := range hwids {
+		if (domain.Device{HWID: d.HWID}).Key() != key {
+			continue
+		}
+
+		if err := s.remna.DeleteUserDevice(ctx, userUUID, d.HWID); err != nil {
+			return fmt.Errorf("ошибка отвязки устройства: %w", err)
+		}
+
+		logger.FromContext(ctx, s.logger).Info("устройство отвязано пользователем",
+			logger.Field{Key: "user_id", Value: userID},
+		)
+
+		return nil
+	}
+
+	return domain.ErrDeviceNotFound
+}
+
+// BuyExtraDevice покупка устройства сверх подписки. Устройство остается
+// у пользователя навсегда и учитывается при каждом продлении
+func (s *DeviceService) BuyExtraDevice(ctx context.Context, userID string) (int, error) {
+	log := logger.FromContext(ctx, s.logger)
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.ExtraDevices >= domain.MaxExtraDevices {
+		return 0, domain.ErrDeviceLimit
+	}
+
+	// Без подписки лимит применить некуда, деньги не списываем
+	if _, err := panelUUID(ctx, s.remna, userID); err != nil {
+		return 0, err
+	}
+
+	price := s.settings.PricePerDevice()
+	balance := user.Balance
+	user, err = s.users.AddExtraDevice(ctx, userID, price, domain.MaxExtraDevices)
+	switch {
+	case errors.Is(err, domain.ErrInsufficientFunds):
+		return 0, fmt.Errorf("%w. Баланс: %d ₽, Требуется: %d ₽", domain.ErrInsufficientFunds, balance, price)
+	case errors.Is(err, domain.ErrDeviceLimit):
+		return 0, err
+	case err != nil:
+		return 0, fmt.Errorf("ошибка списания за устройство: %w", err)
+	}
+	limit := DeviceLimit(s.settings, user)
+	if err := s.remna.SetDevices(ctx, userID, limit); err != nil {
+		// Лимит не изменился, поэтому и устройство, и деньги возвращаем сразу:
+		// иначе повторная попытка списала бы цену второго устройства
+		if _, cancelErr := s.users.CancelExtraDevice(ctx, userID, price); cancelErr != nil {
+			log.Error("устройство не применено и деньги не возвращены",
+				logger.Field{Key: "user_id", Value: userID},
+				logger.Field{Key: "price", Value: price},
+				logger.Field{Key: "error", Value: cancelErr},
+			)
+
+			return 0, fmt.Errorf("%w: %w", domain.ErrNotRefunded, err)
+		}
+
+		return 0, fmt.Errorf("ошибка изменения лимита устройств: %w", err)
+	}
+	s.metrics.BalanceDebited("device", price)
+
+	log.Info("куплено дополнительное устройство",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "limit", Value: limit},
+		logger.Field{Key: "price", Value: price},
+	)
+
+	return limit, nil
+}
+
+// panelUUID uuid пользователя в панели. ErrNoSubscription если его там нет
+func panelUUID(ctx context.Context, remna domain.RemnawaveClient, userID string) (string, error) {
+	userUUID, err := remna.GetUUIDByUsername(ctx, userID)
+	if errors.Is(err, remnawave.ErrNotFound) {
+		return "", domain.ErrNoSubscription
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка поиска пользователя в панели: %w", err)
+	}
+
+	return userUUID, nil
+}
+
+// deref значение необязательного поля из ответа панели
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+)
+
+// Устройства в тестах: 3 в подписке, дополнительное за 50 ₽
+const (
+	testDevices     = 3
+	testDevicePrice = 50
+)
+
+// fakeDeviceSettings настройки устройств
+type fakeDeviceSettings struct {
+	domain.Settings
+}
+
+// Devices устройств в подписке
+func (fakeDeviceSettings) Devices() int { return testDevices }
+
+// PricePerDevice цена дополнительного устройства
+func (fakeDeviceSettings) PricePerDevice() int { return testDevicePrice }
+
+// fakeDeviceUsers один пользователь со списаниями за устройства
+type fakeDeviceUsers struct {
+	domain.UserRepository
+
+	user      models.UserTG
+	cancelErr error
+}
+
+// GetUserByID копия пользователя
+func (f *fakeDeviceUsers) GetUserByID(_ context.Context, _ string) (*models.UserTG, error) {
+	user := f.user
+	return &user, nil
+}
+
+// AddExtraDevice списывает price и добавляет устройство
+func (f *fakeDeviceUsers) AddExtraDevice(_ context.Context, _ string, price, _ int) (*models.UserTG, error) {
+	if f.user.Balance < price {
+		return nil, domain.ErrInsufficientFunds
+	}
+	f.user.Balance -= price
+	f.user.ExtraDevices++
+
+	user := f.user
+	return &user, nil
+}
+
+// CancelExtraDevice возвращает price и убирает устройство или возвращает cancelErr
+func (f *fakeDeviceUsers) CancelExtraDevice(_ context.Context, _ string, price int) (*models.UserTG, error) {
+	if f.cancelErr != nil {
+		return nil, f.cancelErr
+	}
+	f.user.Balance += price
+	f.user.ExtraDevices--
+
+	user := f.user
+	return &user, nil
+}
+
+// fakeDevicePanel панель, в которой SetDevices возвращает err
+type fakeDevicePanel struct {
+	domain.RemnawaveClient
+
+	err   error
+	limit int
+}
+
+// GetUUIDByUsername подписка есть у всех
+func (f *fakeDevicePanel) GetUUIDByUsername(_ context.Context, username string) (string, error) {
+	return "uuid-" + username, nil
+}
+
+// SetDevices запоминает лимит или возвращает err
+func (f *fakeDevicePanel) SetDevices(_ context.Context, _ string, limit int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.limit = limit
+
+	return nil
+}
+
+func TestBuyExtraDevice(t *testing.T) {
+	panelDown := errors.New("panel down")
+
+	tests := []struct {
+		name      string
+		panelErr  error
+		cancelErr error
+		// wantErr nil - покупка прошла
+		wantErr     error
+		wantBalance int
+		wantExtra   int
+	}{
+		{name: "лимит применен", wantBalance: 50, wantExtra: 1},
+		{name: "панель не ответила", panelErr: panelDown, wantErr: panelDown, wantBalance: 100},
+		{
+			name:        "панель не ответила и деньги не вернулись",
+			panelErr:    panelDown,
+			cancelErr:   errors.New("db down"),
+			wantErr:     domain.ErrNotRefunded,
+			wantBalance: 50,
+			wantExtra:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeDeviceUsers{user: models.UserTG{ID: "1", Balance: 100}, cancelErr: tt.cancelErr}
+			panel := &fakeDevicePanel{err: tt.panelErr}
+			s := NewDeviceService(panel, users, fakeDeviceSettings{}, newTestLogger(t))
+
+			limit, err := s.BuyExtraDevice(context.Background(), "1")
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("BuyExtraDevice: %v", err)
+				}
+				if limit != testDevices+1 || panel.limit != limit {
+					t.Errorf("лимит %d, в панели %d, ожидали %d", limit, panel.limit, testDevices+1)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BuyExtraDevice: %v, ожидали %v", err, tt.wantErr)
+			}
+
+			if users.user.Balance != tt.wantBalance || users.user.ExtraDevices != tt.wantExtra {
+				t.Errorf("баланс %d, устройств %d, ожидали %d и %d",
+					users.user.Balance, users.user.ExtraDevices, tt.wantBalance, tt.wantExtra)
+			}
+		})
+	}
+}
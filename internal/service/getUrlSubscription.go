@@ -2,19 +2,44 @@
 // взаимодействует с remnawave.
 package service
 
-import "ProxyMaster_v2/internal/domain"
+import (
+	"context"
+	"time"
 
-// GetURLSubscription получает url подписки пользователя через username (Telegram ID).
-func GetURLSubscription(remnawaveClient domain.RemnawaveClient, username string) string {
-	uuid, err := remnawaveClient.GetUUIDByUsername(username)
+	"ProxyMaster_v2/internal/domain"
+)
+
+// SubscriptionInfo то что показываем пользователю о его подписке
+type SubscriptionInfo struct {
+	URL      string
+	ExpireAt time.Time
+	// Status ACTIVE, DISABLED, EXPIRED и т.д.
+	Status string
+}
+
+// GetSubscriptionInfo получает подписку пользователя через username (Telegram ID).
+// ok=false если пользователя в remnawave нет или панель недоступна
+func GetSubscriptionInfo(ctx context.Context, remnawaveClient domain.RemnawaveClient, username string) (SubscriptionInfo, bool) {
+	uuid, err := remnawaveClient.GetUUIDByUsername(ctx, username)
 	if err != nil {
-		return ""
+		return SubscriptionInfo{}, false
 	}
 
-	userInfo, err := remnawaveClient.GetUserInfo(uuid)
+	userInfo, err := remnawaveClient.GetUserInfo(ctx, uuid)
 	if err != nil {
-		return ""
+		return SubscriptionInfo{}, false
 	}
 
-	return userInfo.Response.SubscriptionURL
+	return SubscriptionInfo{
+		URL:      userInfo.Response.SubscriptionURL,
+		ExpireAt: userInfo.Response.ExpireAt,
+		Status:   userInfo.Response.Status,
+	}, true
+}
+
+// GetURLSubscription получает url подписки пользователя через username (Telegram ID).
+func GetURLSubscription(ctx context.Context, remnawaveClient domain.RemnawaveClient, username string) string {
+	info, _ := GetSubscriptionInfo(ctx, remnawaveClient, username)
+
+	return info.URL
 }
@@ -0,0 +1,356 @@
+// Package service сервис пополнения баланса. Не зависит от конкретной
+// платежной системы: провайдера находит в domain.PaymentRegistry, счет создает
+// его domain.PaymentGateway, возврат - тот же gateway, если он domain.Refunder.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// PaymentService пополнение баланса через любую платежную систему
+type PaymentService struct {
+	transactions domain.TransactionRepository
+	users        domain.UserRepository
+	registry     domain.PaymentRegistry
+	metrics      domain.Metrics
+	// notify сообщает о зачислении, которое повторил Watch. nil - не сообщаем
+	notify func(ctx context.Context, tx *models.Transaction)
+	logger logger.Logger
+}
+
+// NewPaymentService конструктор сервиса.
+func NewPaymentService(
+	transactions domain.TransactionRepository,
+	users domain.UserRepository,
+	registry domain.PaymentRegistry,
+	l logger.Logger,
+) *PaymentService {
+	l.Info("Создан экземпляр платежного сервиса")
+	return &PaymentService{
+		transactions: transactions,
+		users:        users,
+		registry:     registry,
+		metrics:      domain.NopMetrics{},
+		logger:       l,
+	}
+}
+
+// SetMetrics включает метрики платежей
+func (s *PaymentService) SetMetrics(m domain.Metrics) {
+	s.metrics = m
+}
+
+// SetNotifier включает сообщения о зачислениях, которые повторил Watch
+func (s *PaymentService) SetNotifier(fn func(ctx context.Context, tx *models.Transaction)) {
+	s.notify = fn
+}
+
+// Providers включенные платежные системы
+func (s *PaymentService) Providers() []domain.PaymentProvider {
+	return s.registry.Providers()
+}
+
+// Provider включенная платежная система по ID
+func (s *PaymentService) Provider(providerID string) (domain.PaymentProvider, bool) {
+	return s.registry.Provider(providerID)
+}
+
+// Fee комиссия провайдера за пополнение на amount рублей
+func (s *PaymentService) Fee(providerID string, amount int) int {
+	return s.registry.Fee(providerID, amount)
+}
+
+// Allows входит ли amount в лимиты провайдера
+func (s *PaymentService) Allows(providerID string, amount int) bool {
+	return s.registry.Allows(providerID, amount)
+}
+
+// logDuration логирует время выполнения метода.
+func (s *PaymentService) logDuration(method string) func() {
+	start := time.Now()
+
+	return func() {
+		s.logger.Info("вызов метода завершен",
+			logger.Field{Key: "method", Value: method},
+			logger.Field{Key: "duration", Value: time.Since(start)},
+		)
+	}
+}
+
+// logError логирует ошибку и возвращает её обернутую.
+func (s *PaymentService) logError(msg string, err error, fields ...logger.Field) error {
+	allFields := append([]logger.Field{{Key: "error", Value: err}}, fields...)
+	s.logger.Error(msg, allFields...)
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// CreateTopUp создает транзакцию на amount рублей и счет в платежной системе.
+// Комиссия провайдера добавляется к счету, на баланс зачисляется ровно amount
+func (s *PaymentService) CreateTopUp(ctx context.Context, providerID string, userID string, amount int) (*models.Transaction, string, error) {
+	defer s.logDuration("CreateTopUp")()
+
+	provider, ok := s.registry.Provider(providerID)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", domain.ErrUnknownProvider, providerID)
+	}
+	if !s.registry.Allows(providerID, amount) {
+		return nil, "", fmt.Errorf("%w: %d ₽ для %s", domain.ErrAmountOutOfRange, amount, providerID)
+	}
+
+	fee := s.registry.Fee(providerID, amount)
+
+	// Сначала транзакция, потом счет: платежная система получит наш id
+	// и вернет его при оплате, так мы поймем, что зачислять
+	tx, err := s.transactions.CreateTransaction(models.CreateTransactionDTO{
+		ID:       uuid.NewString(),
+		UserID:   userID,
+		Amount:   amount,
+		Status:   string(domain.PaymentStatusPending),
+		Provider: provider.ID,
+		Fee:      fee,
+	})
+	if err != nil {
+		return nil, "", s.logError("ошибка создания транзакции", err, logger.Field{Key: "user_id", Value: userID})
+	}
+
+	paymentURL, externalID, err := provider.Gateway.CreateTransaction(ctx, float64(amount+fee), tx.ID)
+	if err != nil {
+		// Счет не создан, оплатить эту транзакцию уже нельзя
+		s.metrics.PaymentFailed(provider.ID)
+		if _, markErr := s.transactions.SetTransactionStatus(tx.ID, domain.PaymentStatusPending, domain.PaymentStatusFailed, ""); markErr != nil {
+			s.logger.Error("не удалось отметить транзакцию неуспешной",
+				logger.Field{Key: "transaction_id", Value: tx.ID},
+				logger.Field{Key: "error", Value: markErr},
+			)
+		}
+
+		return nil, "", s.logError("ошибка создания счета", err,
+			logger.Field{Key: "transaction_id", Value: tx.ID},
+			logger.Field{Key: "provider", Value: provider.ID},
+		)
+	}
+
+	// Некоторые платежки сразу выдают свой id, запомним его
+	if externalID != "" {
+		if _, err := s.transactions.SetTransactionStatus(tx.ID, domain.PaymentStatusPending, domain.PaymentStatusPending, externalID); err != nil {
+			return nil, "", s.logError("ошибка сохранения id платежа", err, logger.Field{Key: "transaction_id", Value: tx.ID})
+		}
+	}
+
+	s.metrics.PaymentCreated(provider.ID)
+	s.logger.Info("создан счет на пополнение",
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "amount", Value: amount},
+		logger.Field{Key: "fee", Value: fee},
+		logger.Field{Key: "provider", Value: provider.ID},
+	)
+
+	return tx, paymentURL, nil
+}
+
+// CompleteTopUp зачисляет оплаченную транзакцию на баланс пользователя.
+// Платежки могут присылать уведомление об оплате несколько раз, поэтому
+// зачисление происходит только при переходе в success. Сначала оплата
+// отдельно записывается как paid: если зачисление не пройдет, его повторит Watch
+func (s *PaymentService) CompleteTopUp(ctx context.Context, transactionID, externalID string) (*models.Transaction, bool, error) {
+	defer s.logDuration("CompleteTopUp")()
+
+	if _, err := s.transactions.SetTransactionStatus(transactionID, domain.PaymentStatusPending, domain.PaymentStatusPaid, externalID); err != nil {
+		return nil, false, s.logError("ошибка записи оплаты", err,
+			logger.Field{Key: "transaction_id", Value: transactionID},
+			logger.Field{Key: "external_id", Value: externalID},
+		)
+	}
+
+	changed, err := s.transactions.CompleteTransaction(transactionID, externalID)
+	if err != nil {
+		return nil, false, s.logError("ошибка зачисления на баланс", err, logger.Field{Key: "transaction_id", Value: transactionID})
+	}
+
+	tx, err := s.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, false, s.logError("ошибка получения транзакции", err, logger.Field{Key: "transaction_id", Value: transactionID})
+	}
+
+	if !changed {
+		s.logger.Warn("повторное подтверждение оплаты пропущено",
+			logger.Field{Key: "transaction_id", Value: transactionID},
+			logger.Field{Key: "status", Value: tx.Status},
+		)
+
+		return tx, false, nil
+	}
+
+	s.metrics.PaymentSucceeded(tx.Provider, tx.Amount)
+	s.logger.Info("баланс пополнен",
+		logger.Field{Key: "transaction_id", Value: transactionID},
+		logger.Field{Key: "user_id", Value: tx.UserID},
+		logger.Field{Key: "amount", Value: tx.Amount},
+	)
+
+	return tx, true, nil
+}
+
+// Watch каждые interval зачисляет транзакции, оплата которых записана,
+// а зачисление не прошло. Работает, пока не отменят ctx
+func (s *PaymentService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.completePaid(ctx)
+		}
+	}
+}
+
+// completePaid зачисляет все транзакции в статусе paid
+func (s *PaymentService) completePaid(ctx context.Context) {
+	paid, err := s.transactions.GetTransactionsByStatus(domain.PaymentStatusPaid)
+	if err != nil {
+		s.logger.Warn("оплаченные транзакции не проверены", logger.Field{Key: "error", Value: err})
+
+		return
+	}
+
+	for _, tx := range paid {
+		// Ошибку уже залогировал CompleteTopUp, попробуем в следующий раз
+		completed, credited, err := s.CompleteTopUp(ctx, tx.ID, "")
+		if err == nil && credited && s.notify != nil {
+			s.notify(ctx, completed)
+		}
+	}
+}
+
+// CheckTopUp сверяет статус транзакции с платежной системой, которая
+// записана в transactions.provider
+func (s *PaymentService) CheckTopUp(ctx context.Context, userID, transactionID string) (*models.Transaction, bool, error) {
+	defer s.logDuration("CheckTopUp")()
+
+	tx, err := s.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка получения транзакции: %w", err)
+	}
+	// Чужая транзакция для пользователя выглядит так же, как несуществующая
+	if tx.UserID != userID {
+		return nil, false, domain.ErrTransactionNotFound
+	}
+
+	// Оплата уже записана, осталось зачислить
+	if tx.Status == string(domain.PaymentStatusPaid) {
+		return s.CompleteTopUp(ctx, tx.ID, "")
+	}
+	// Уже зачислена или отменена, спрашивать платежку незачем
+	if tx.Status != string(domain.PaymentStatusPending) {
+		return tx, false, nil
+	}
+
+	provider, ok := s.registry.Provider(tx.Provider)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %s", domain.ErrUnknownProvider, tx.Provider)
+	}
+
+	id := tx.ID
+	if tx.ExternalID != nil && *tx.ExternalID != "" {
+		id = *tx.ExternalID
+	}
+
+	status, err := provider.Gateway.CheckStatus(ctx, id)
+	if err != nil {
+		return nil, false, s.logError("ошибка проверки статуса платежа", err, logger.Field{Key: "transaction_id", Value: tx.ID})
+	}
+
+	switch status {
+	case domain.PaymentStatusSuccess:
+		return s.CompleteTopUp(ctx, tx.ID, "")
+	case domain.PaymentStatusFailed:
+		changed, err := s.transactions.SetTransactionStatus(tx.ID, domain.PaymentStatusPending, domain.PaymentStatusFailed, "")
+		if err != nil {
+			return nil, false, s.logError("ошибка отметки неуспешной транзакции", err, logger.Field{Key: "transaction_id", Value: tx.ID})
+		}
+		if changed {
+			s.metrics.PaymentFailed(tx.Provider)
+		}
+		tx.Status = string(domain.PaymentStatusFailed)
+	}
+
+	return tx, false, nil
+}
+
+// Refund возвращает оплату транзакции и списывает ее сумму с баланса.
+// Если пользователь уже потратил деньги, возврат не делаем
+func (s *PaymentService) Refund(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	defer s.logDuration("Refund")()
+
+	tx, err := s.transactions.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения транзакции: %w", err)
+	}
+
+	if tx.Status != string(domain.PaymentStatusSuccess) {
+		return nil, fmt.Errorf("вернуть можно только оплаченную транзакцию, статус: %s", tx.Status)
+	}
+
+	provider, ok := s.registry.Provider(tx.Provider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnknownProvider, tx.Provider)
+	}
+	refunder, ok := provider.Gateway.(domain.Refunder)
+	if !ok {
+		return nil, fmt.Errorf("провайдер %s не поддерживает возврат", tx.Provider)
+	}
+
+	// Сначала списываем с баланса: если пользователь уже потратил деньги,
+	// возврата не будет, а параллельная покупка не уведет баланс в минус
+	changed, err := s.transactions.RefundTransaction(tx.ID)
+	if errors.Is(err, domain.ErrInsufficientFunds) {
+		user, getErr := s.users.GetUserByID(ctx, tx.UserID)
+		if getErr != nil {
+			return nil, fmt.Errorf("ошибка получения пользователя: %w", getErr)
+		}
+
+		return nil, fmt.Errorf("%w. Баланс: %d ₽, к возврату: %d ₽", domain.ErrInsufficientFunds, user.Balance, tx.Amount)
+	}
+	if err != nil {
+		return nil, s.logError("ошибка списания возврата с баланса", err, logger.Field{Key: "transaction_id", Value: tx.ID})
+	}
+	if !changed {
+		return nil, fmt.Errorf("транзакция %s уже возвращается", tx.ID)
+	}
+
+	if err := refunder.Refund(ctx, tx.ID); err != nil {
+		// Деньги пользователю не ушли, возвращаем их на баланс
+		if _, revertErr := s.transactions.RevertRefund(tx.ID); revertErr != nil {
+			s.logger.Error("возврат не прошел, а баланс не восстановлен",
+				logger.Field{Key: "transaction_id", Value: tx.ID},
+				logger.Field{Key: "error", Value: revertErr},
+			)
+		}
+
+		return nil, s.logError("ошибка возврата платежа", err, logger.Field{Key: "transaction_id", Value: tx.ID})
+	}
+	s.metrics.PaymentRefunded(tx.Provider, tx.Amount)
+
+	s.logger.Info("платеж возвращен",
+		logger.Field{Key: "transaction_id", Value: tx.ID},
+		logger.Field{Key: "user_id", Value: tx.UserID},
+		logger.Field{Key: "amount", Value: tx.Amount},
+	)
+
+	tx.Status = string(domain.PaymentStatusRefunded)
+
+	return tx, nil
+}
@@ -0,0 +1,192 @@
+// Package service регионы подключения. Список сквадов берем из панели и
+// держим в памяти: экран тарифов открывают часто, а сквады меняются редко.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// regionsCacheTTL как долго список сквадов из панели считается свежим
+const regionsCacheTTL = 5 * time.Minute
+
+// Проверяем на этапе компиляции, что RegionService реализует интерфейс
+var _ domain.RegionService = (*RegionService)(nil)
+
+// RegionService регионы из сквадов панели и выбор пользователя
+type RegionService struct {
+	remna domain.RemnawaveClient
+	users domain.UserRepository
+
+	mu       sync.Mutex
+	cached   []domain.Region
+	cachedAt time.Time
+	// refreshing закрывается, когда закончится текущий запрос сквадов.
+	// nil - никто не обновляет
+	refreshing chan struct{}
+	// refreshErr ошибка последнего обновления
+	refreshErr error
+
+	logger logger.Logger
+}
+
+// NewRegionService конструктор сервиса.
+func NewRegionService(remna domain.RemnawaveClient, users domain.UserRepository, l logger.Logger) *RegionService {
+	l.Info("Создан экземпляр сервиса регионов")
+	return &RegionService{
+		remna:  remna,
+		users:  users,
+		logger: l,
+	}
+}
+
+// Regions сквады панели. Если панель не ответила, отдаем прошлый список:
+// пользователю лучше увидеть чуть устаревшие регионы, чем ошибку.
+// В панель ходим без блокировки, чтобы медленный ответ не держал экраны
+// всех пользователей
+func (s *RegionService) Regions(ctx context.Context) ([]domain.Region, error) {
+	s.mu.Lock()
+	cached, fresh := s.cached, s.cached != nil && time.Since(s.cachedAt) < regionsCacheTTL
+	s.mu.Unlock()
+
+	if fresh {
+		return cached, nil
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		s.mu.Lock()
+		cached = s.cached
+		s.mu.Unlock()
+
+		if cached != nil {
+			logger.FromContext(ctx, s.logger).Warn("сквады не обновлены, используем прошлый список",
+				logger.Field{Key: "error", Value: err},
+			)
+
+			return cached, nil
+		}
+
+		return nil, fmt.Errorf("ошибка получения сквадов: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cached, nil
+}
+
+// refresh обновляет список сквадов. Если обновление уже идет, ждет его
+// и возвращает его ошибку, а не делает второй запрос в панель
+func (s *RegionService) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	if done := s.refreshing; done != nil {
+		s.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.refreshErr
+	}
+
+	done := make(chan struct{})
+	s.refreshing = done
+	s.mu.Unlock()
+
+	squads, err := s.remna.GetInternalSquads(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		regions := make([]domain.Region, 0, len(squads))
+		for _, squad := range squads {
+			regions = append(regions, domain.Region{ID: squad.UUID, Name: squad.Name})
+		}
+
+		s.cached = regions
+		s.cachedAt = time.Now()
+	}
+	s.refreshErr = err
+	s.refreshing = nil
+	s.mu.Unlock()
+	close(done)
+
+	return err
+}
+
+// UserRegion выбранный пользователем регион
+func (s *RegionService) UserRegion(ctx context.Context, userID string) (domain.Region, bool) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil || user.Region == "" {
+		return domain.Region{}, false
+	}
+
+	return s.find(ctx, user.Region)
+}
+
+// SetUserRegion сохраняет регион. Если подписка уже есть, сначала переносим
+// ее в панели: выбор в DB без переноса показал бы регион, которого у
+// пользователя на деле нет
+func (s *RegionService) SetUserRegion(ctx context.Context, userID, regionID string) error {
+	log := logger.FromContext(ctx, s.logger)
+
+	regions, err := s.Regions(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(regions, func(r domain.Region) bool { return r.ID == regionID }) {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownRegion, regionID)
+	}
+
+	userUUID, err := s.remna.GetUUIDByUsername(ctx, userID)
+	switch {
+	case errors.Is(err, remnawave.ErrNotFound):
+		// Подписки еще нет, регион применится при покупке
+	case err != nil:
+		return fmt.Errorf("ошибка поиска пользователя в панели: %w", err)
+	default:
+		if err := s.remna.SetUserSquads(ctx, userUUID, []string{regionID}); err != nil {
+			return fmt.Errorf("ошибка смены сквада: %w", err)
+		}
+	}
+
+	if _, err := s.users.UpdateUser(ctx, userID, models.UpdateUserTGDTO{Region: &regionID}); err != nil {
+		return fmt.Errorf("ошибка сохранения региона: %w", err)
+	}
+
+	log.Info("регион пользователя изменен",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "region", Value: regionID},
+	)
+
+	return nil
+}
+
+// find регион по uuid сквада
+func (s *RegionService) find(ctx context.Context, regionID string) (domain.Region, bool) {
+	regions, err := s.Regions(ctx)
+	if err != nil {
+		return domain.Region{}, false
+	}
+
+	for _, r := range regions {
+		if r.ID == regionID {
+			return r, true
+		}
+	}
+
+	return domain.Region{}, false
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// newTestLogger логгер, который пишет только ошибки
+func newTestLogger(t *testing.T) logger.Logger {
+	t.Helper()
+
+	levels, err := logger.NewLevels("error", "")
+	if err != nil {
+		t.Fatalf("NewLevels: %v", err)
+	}
+	l, err := logger.New(logger.Options{Levels: levels})
+	if err != nil {
+		t.Fatalf("logger.New: %v", err)
+	}
+
+	return l
+}
+
+// fakeSquads панель, которая отдает сквады, когда закроют release
+type fakeSquads struct {
+	domain.RemnawaveClient
+
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+// GetInternalSquads ждет release и считает вызовы
+func (f *fakeSquads) GetInternalSquads(_ context.Context) ([]models.InternalSquad, error) {
+	f.calls.Add(1)
+	<-f.release
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return []models.InternalSquad{{UUID: "squad-de", Name: "Германия"}}, nil
+}
+
+func TestRegionsSingleRefresh(t *testing.T) {
+	remna := &fakeSquads{release: make(chan struct{})}
+	s := NewRegionService(remna, nil, newTestLogger(t))
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Regions(context.Background())
+			errs <- err
+		}()
+	}
+
+	// Пока панель отвечает, mu свободен: ожидающий с отмененным ctx сразу выходит
+	for remna.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := s.Regions(ctx)
+		canceled <- err
+	}()
+	select {
+	case err := <-canceled:
+		if err == nil {
+			t.Error("Regions с отмененным ctx без ошибки")
+		}
+	case <-time.After(time.Second):
+		t.Error("Regions ждет mu, пока идет запрос в панель")
+	}
+
+	close(remna.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Regions: %v", err)
+		}
+	}
+	if n := remna.calls.Load(); n != 1 {
+		t.Errorf("GetInternalSquads вызван %d раз, ожидали 1", n)
+	}
+}
+
+func TestRegionsKeepsStaleList(t *testing.T) {
+	remna := &fakeSquads{release: make(chan struct{})}
+	close(remna.release)
+	s := NewRegionService(remna, nil, newTestLogger(t))
+
+	if _, err := s.Regions(context.Background()); err != nil {
+		t.Fatalf("Regions: %v", err)
+	}
+
+	// Список устарел, а панель не отвечает: отдаем прошлый
+	s.cachedAt = time.Now().Add(-2 * regionsCacheTTL)
+	remna.err = errors.New("panel down")
+
+	regions, err := s.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions со старым списком: %v", err)
+	}
+	if len(regions) != 1 || regions[0].ID != "squad-de" {
+		t.Errorf("Regions = %+v", regions)
+	}
+}
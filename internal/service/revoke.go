@@ -0,0 +1,105 @@
+// Package service перевыпуск ссылки подписки. Частоту ограничиваем в
+// памяти: после перезапуска ограничение сбрасывается, это допустимо.
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Проверяем на этапе компиляции, что LinkService реализует интерфейс
+var _ domain.LinkService = (*LinkService)(nil)
+
+// LinkService перевыпуск ссылки подписки не чаще domain.RevokeCooldown
+type LinkService struct {
+	remna domain.RemnawaveClient
+
+	mu sync.Mutex
+	// revokedAt когда пользователь последний раз перевыпускал ссылку
+	revokedAt map[string]time.Time
+
+	logger logger.Logger
+}
+
+// NewLinkService конструктор сервиса.
+func NewLinkService(remna domain.RemnawaveClient, l logger.Logger) *LinkService {
+	l.Info("Создан экземпляр сервиса ссылок подписки")
+	return &LinkService{
+		remna:     remna,
+		revokedAt: make(map[string]time.Time),
+		logger:    l,
+	}
+}
+
+// RevokeLink перевыпускает ссылку подписки пользователя
+func (s *LinkService) RevokeLink(ctx context.Context, userID string) (string, time.Duration, error) {
+	now := time.Now()
+	if wait := s.reserve(userID, now); wait > 0 {
+		return "", wait, domain.ErrRevokeTooSoon
+	}
+
+	url, err := s.revoke(ctx, userID)
+	if err != nil {
+		// Ссылка не изменилась, попытка не считается
+		s.release(userID, now)
+
+		return "", 0, err
+	}
+
+	logger.FromContext(ctx, s.logger).Info("ссылка подписки перевыпущена", logger.Field{Key: "user_id", Value: userID})
+
+	return url, 0, nil
+}
+
+// revoke перевыпуск в панели
+func (s *LinkService) revoke(ctx context.Context, userID string) (string, error) {
+	userUUID, err := panelUUID(ctx, s.remna, userID)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := s.remna.RevokeSubscription(ctx, userUUID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка перевыпуска подписки: %w", err)
+	}
+
+	return url, nil
+}
+
+// reserve занимает попытку пользователя заранее, чтобы два одновременных
+// нажатия не перевыпустили ссылку дважды. >0 - сколько ждать до следующей
+func (s *LinkService) reserve(userID string, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.revokedAt[userID]; ok {
+		if wait := last.Add(domain.RevokeCooldown).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+
+	// Заодно удаляем истекшие попытки, чтобы map не рос бесконечно
+	for id, last := range s.revokedAt {
+		if now.Sub(last) >= domain.RevokeCooldown {
+			delete(s.revokedAt, id)
+		}
+	}
+	s.revokedAt[userID] = now
+
+	return 0
+}
+
+// release возвращает попытку, занятую в reserve в момент at
+func (s *LinkService) release(userID string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.revokedAt[userID].Equal(at) {
+		delete(s.revokedAt, userID)
+	}
+}
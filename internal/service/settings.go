@@ -0,0 +1,437 @@
+// Package service настройки, которые админ меняет без рестарта. Значения
+// держим в памяти: они нужны почти на каждое обновление телеграма, а DB
+// перечитываем по таймеру, чтобы подхватить изменения из других копий бота.
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Проверяем на этапе компиляции, что SettingsService реализует интерфейс
+var _ domain.SettingsService = (*SettingsService)(nil)
+
+// settingParser проверяет строку настройки и приводит ее к типу
+type settingParser func(raw string) (any, error)
+
+// settingParsers известные настройки. Типы результатов должны совпадать
+// с тем, что читают методы SettingsService
+var settingParsers = map[string]settingParser{
+	domain.SettingSupport:            parseSupportURL,
+	domain.SettingPricePerMonth:      parsePositiveInt,
+	domain.SettingTrialDays:          parseNonNegativeInt,
+	domain.SettingDevices:            parsePositiveInt,
+	domain.SettingPricePerDevice:     parsePositiveInt,
+	domain.SettingTrafficLimitGB:     parseNonNegativeInt,
+	domain.SettingTrafficReset:       parseTrafficReset,
+	domain.SettingPricePerGB:         parsePositiveInt,
+	domain.SettingMaintenance:        parseSwitch,
+	domain.SettingMaintenanceMessage: parseNotice,
+	domain.SettingAdminIDs:           parseAdminIDs,
+}
+
+// settingEntry текущее значение: как показать админу и разобранное для чтения
+type settingEntry struct {
+	view   domain.SettingValue
+	parsed any
+}
+
+// SettingsService настройки из DB поверх значений по умолчанию
+type SettingsService struct {
+	repo domain.SettingsRepository
+	// defaults значения, если в DB настройки нет
+	defaults map[string]settingEntry
+
+	mu        sync.RWMutex
+	entries   map[string]settingEntry
+	listeners []func(key, value string)
+
+	logger logger.Logger
+}
+
+// NewSettingsService конструктор сервиса. defaults - значения из конфига
+// (поддержка, админы), они перекрывают встроенные. Настройки из DB
+// подгружает Load
+func NewSettingsService(repo domain.SettingsRepository, defaults map[string]string, l logger.Logger) (*SettingsService, error) {
+	raw := map[string]string{
+		domain.SettingSupport:            "",
+		domain.SettingPricePerMonth:      strconv.Itoa(domain.PricePerMonth),
+		domain.SettingTrialDays:          strconv.Itoa(domain.TrialDays),
+		domain.SettingDevices:            strconv.Itoa(domain.DevicesPerSubscription),
+		domain.SettingPricePerDevice:     strconv.Itoa(domain.PricePerDevice),
+		domain.SettingTrafficLimitGB:     strconv.Itoa(domain.TrafficLimitGB),
+		domain.SettingTrafficReset:       domain.TrafficResetMonth,
+		domain.SettingPricePerGB:         strconv.Itoa(domain.PricePerGB),
+		domain.SettingMaintenance:        "off",
+		domain.SettingMaintenanceMessage: "",
+		domain.SettingAdminIDs:           "",
+	}
+	for key, value := range defaults {
+		if _, ok := settingParsers[key]; !ok {
+			return nil, fmt.Errorf("%w: %s", domain.ErrUnknownSetting, key)
+		}
+		raw[key] = value
+	}
+
+	s := &SettingsService{
+		repo:     repo,
+		defaults: make(map[string]settingEntry, len(raw)),
+		entries:  make(map[string]settingEntry, len(raw)),
+		logger:   l,
+	}
+	for key, value := range raw {
+		parsed, err := settingParsers[key](value)
+		// Пустое значение по умолчанию допустимо: настройка просто не задана
+		if err != nil && value != "" {
+			return nil, fmt.Errorf("неверное значение по умолчанию %s: %w", key, err)
+		}
+
+		entry := settingEntry{view: domain.SettingValue{Key: key, Value: value}, parsed: parsed}
+		s.defaults[key] = entry
+		s.entries[key] = entry
+	}
+
+	l.Info("Создан экземпляр сервиса настроек")
+	return s, nil
+}
+
+// Load перечитывает настройки из DB. Неверное значение в DB не ломает
+// бота: для такой настройки остается значение по умолчанию
+func (s *SettingsService) Load(ctx context.Context) error {
+	rows, err := s.repo.GetAllSettings(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки настроек: %w", err)
+	}
+
+	entries := make(map[string]settingEntry, len(s.defaults))
+	for key, entry := range s.defaults {
+		entries[key] = entry
+	}
+
+	for _, row := range rows {
+		entry, err := newSettingEntry(row)
+		if err != nil {
+			s.logger.Warn("настройка в DB пропущена",
+				logger.Field{Key: "key", Value: row.Key},
+				logger.Field{Key: "error", Value: err},
+			)
+			continue
+		}
+		entries[row.Key] = entry
+	}
+
+	s.mu.Lock()
+	changed := make(map[string]string)
+	for key, entry := range entries {
+		if s.entries[key].view.Value != entry.view.Value {
+			changed[key] = entry.view.Value
+		}
+	}
+	s.entries = entries
+	listeners := slices.Clone(s.listeners)
+	s.mu.Unlock()
+
+	for key, value := range changed {
+		s.logger.Info("настройка изменена в DB", logger.Field{Key: "key", Value: key})
+		notify(listeners, key, value)
+	}
+
+	return nil
+}
+
+// Watch перечитывает настройки каждые interval, пока не отменят ctx
+func (s *SettingsService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Load(ctx); err != nil {
+				s.logger.Warn("настройки не перечитаны, работают прежние", logger.Field{Key: "error", Value: err})
+			}
+		}
+	}
+}
+
+// Set проверяет и сохраняет значение настройки. actor - telegram id админа
+func (s *SettingsService) Set(ctx context.Context, key, value, actor string) error {
+	value = strings.TrimSpace(value)
+	row := models.Setting{Key: key, Value: value, UpdatedBy: actor, UpdatedAt: time.Now()}
+
+	entry, err := newSettingEntry(row)
+	if err != nil {
+		return err
+	}
+
+	// Админ не должен случайно лишить прав сам себя
+	if key == domain.SettingAdminIDs {
+		actorID, _ := strconv.ParseInt(actor, 10, 64)
+		if !slices.Contains(entry.parsed.([]int64), actorID) {
+			return fmt.Errorf("%w: в списке нет вашего id %s", domain.ErrInvalidSetting, actor)
+		}
+	}
+
+	if err := s.repo.SaveSetting(ctx, row); err != nil {
+		return fmt.Errorf("ошибка сохранения настройки: %w", err)
+	}
+
+	logger.FromContext(ctx, s.logger).Info("настройка изменена",
+		logger.Field{Key: "key", Value: key},
+		logger.Field{Key: "actor", Value: actor},
+	)
+	s.apply(key, entry)
+
+	return nil
+}
+
+// Reset удаляет настройку из DB, дальше действует значение по умолчанию
+func (s *SettingsService) Reset(ctx context.Context, key, actor string) error {
+	entry, ok := s.defaults[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownSetting, key)
+	}
+
+	if err := s.repo.DeleteSetting(ctx, key); err != nil {
+		return fmt.Errorf("ошибка сброса настройки: %w", err)
+	}
+
+	logger.FromContext(ctx, s.logger).Info("настройка сброшена",
+		logger.Field{Key: "key", Value: key},
+		logger.Field{Key: "actor", Value: actor},
+	)
+	s.apply(key, entry)
+
+	return nil
+}
+
+// OnChange вызывает fn после каждого изменения настройки
+func (s *SettingsService) OnChange(fn func(key, value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, fn)
+}
+
+// List все настройки в порядке ключей
+func (s *SettingsService) List() []domain.SettingValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]domain.SettingValue, 0, len(s.entries))
+	for _, entry := range s.entries {
+		list = append(list, entry.view)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+
+	return list
+}
+
+// Support ссылка на поддержку
+func (s *SettingsService) Support() string {
+	return s.get(domain.SettingSupport).(string)
+}
+
+// PricePerMonth цена месяца подписки в рублях
+func (s *SettingsService) PricePerMonth() int {
+	return s.get(domain.SettingPricePerMonth).(int)
+}
+
+// TrialDays длина пробного периода в днях
+func (s *SettingsService) TrialDays() int {
+	return s.get(domain.SettingTrialDays).(int)
+}
+
+// Devices сколько устройств входит в подписку
+func (s *SettingsService) Devices() int {
+	return s.get(domain.SettingDevices).(int)
+}
+
+// PricePerDevice цена дополнительного устройства в рублях
+func (s *SettingsService) PricePerDevice() int {
+	return s.get(domain.SettingPricePerDevice).(int)
+}
+
+// TrafficLimitGB лимит трафика тарифа в гигабайтах, 0 - безлимит
+func (s *SettingsService) TrafficLimitGB() int {
+	return s.get(domain.SettingTrafficLimitGB).(int)
+}
+
+// TrafficReset когда панель обнуляет трафик
+func (s *SettingsService) TrafficReset() string {
+	return s.get(domain.SettingTrafficReset).(string)
+}
+
+// PricePerGB цена гигабайта в пакетах трафика
+func (s *SettingsService) PricePerGB() int {
+	return s.get(domain.SettingPricePerGB).(int)
+}
+
+// Maintenance идут ли техработы
+func (s *SettingsService) Maintenance() bool {
+	return s.get(domain.SettingMaintenance).(bool)
+}
+
+// MaintenanceMessage текст для пользователей на время техработ
+func (s *SettingsService) MaintenanceMessage() string {
+	return s.get(domain.SettingMaintenanceMessage).(string)
+}
+
+// IsAdmin есть ли telegram id в списке админов
+func (s *SettingsService) IsAdmin(telegramID int64) bool {
+	return slices.Contains(s.get(domain.SettingAdminIDs).([]int64), telegramID)
+}
+
+// get разобранное значение настройки
+func (s *SettingsService) get(key string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.entries[key].parsed
+}
+
+// apply меняет значение в памяти и оповещает подписчиков
+func (s *SettingsService) apply(key string, entry settingEntry) {
+	s.mu.Lock()
+	changed := s.entries[key].view.Value != entry.view.Value
+	s.entries[key] = entry
+	listeners := slices.Clone(s.listeners)
+	s.mu.Unlock()
+
+	if changed {
+		notify(listeners, key, entry.view.Value)
+	}
+}
+
+// notify вызывает подписчиков на изменение настройки
+func notify(listeners []func(key, value string), key, value string) {
+	for _, fn := range listeners {
+		fn(key, value)
+	}
+}
+
+// newSettingEntry проверяет строку из DB или от админа
+func newSettingEntry(row models.Setting) (settingEntry, error) {
+	parse, ok := settingParsers[row.Key]
+	if !ok {
+		return settingEntry{}, fmt.Errorf("%w: %s", domain.ErrUnknownSetting, row.Key)
+	}
+
+	parsed, err := parse(row.Value)
+	if err != nil {
+		return settingEntry{}, fmt.Errorf("%w: %s: %v", domain.ErrInvalidSetting, row.Key, err)
+	}
+
+	return settingEntry{
+		view: domain.SettingValue{
+			Key:        row.Key,
+			Value:      row.Value,
+			Overridden: true,
+			UpdatedBy:  row.UpdatedBy,
+			UpdatedAt:  row.UpdatedAt,
+		},
+		parsed: parsed,
+	}, nil
+}
+
+// parseSupportURL ссылка на поддержку: https://t.me/... или tg://...
+func parseSupportURL(raw string) (any, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "tg") {
+		return "", fmt.Errorf("нужна ссылка вида https://t.me/support")
+	}
+
+	return raw, nil
+}
+
+// parsePositiveInt целое больше нуля
+func parsePositiveInt(raw string) (any, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("нужно целое число больше нуля")
+	}
+
+	return n, nil
+}
+
+// parseNonNegativeInt целое не меньше нуля
+func parseNonNegativeInt(raw string) (any, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("нужно целое число не меньше нуля")
+	}
+
+	return n, nil
+}
+
+// parseSwitch включено или выключено: on/off, true/false, 1/0
+func parseSwitch(raw string) (any, error) {
+	switch strings.ToLower(raw) {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("нужно on или off")
+}
+
+// parseTrafficReset стратегия сброса трафика в панели
+func parseTrafficReset(raw string) (any, error) {
+	strategy := strings.ToUpper(raw)
+	switch strategy {
+	case domain.TrafficResetNever, domain.TrafficResetDay, domain.TrafficResetWeek, domain.TrafficResetMonth:
+		return strategy, nil
+	}
+
+	return "", fmt.Errorf("нужно %s, %s, %s или %s",
+		domain.TrafficResetNever, domain.TrafficResetDay, domain.TrafficResetWeek, domain.TrafficResetMonth)
+}
+
+// maxNoticeLength телеграм показывает во всплывающем уведомлении кнопки не больше 200 символов
+const maxNoticeLength = 200
+
+// parseNotice текст уведомления для пользователей
+func parseNotice(raw string) (any, error) {
+	if utf8.RuneCountInString(raw) > maxNoticeLength {
+		return "", fmt.Errorf("текст длиннее %d символов", maxNoticeLength)
+	}
+
+	return raw, nil
+}
+
+// parseAdminIDs telegram id через запятую
+func parseAdminIDs(raw string) (any, error) {
+	ids := []int64{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return ids, fmt.Errorf("неверный id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return ids, fmt.Errorf("нужен хотя бы один telegram id")
+	}
+
+	return ids, nil
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -10,25 +11,43 @@ import (
 	"ProxyMaster_v2/internal/infrastructure/remnawave"
 	"ProxyMaster_v2/internal/models"
 	"ProxyMaster_v2/pkg/logger"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SubscriptionService представляет собой сервис для управления подписками клиентов с помощью remnawave.
 type SubscriptionService struct {
 	remna  domain.RemnawaveClient
 	dbRepo domain.UserRepository
-	logger logger.Logger
+	// settings цена месяца, меняется без рестарта
+	settings domain.Settings
+	metrics  domain.Metrics
+	logger   logger.Logger
 }
 
 // NewSubscriptionService конструктор сервиса.
-func NewSubscriptionService(remna domain.RemnawaveClient, dbRepo domain.UserRepository, l logger.Logger) *SubscriptionService {
+func NewSubscriptionService(
+	remna domain.RemnawaveClient,
+	dbRepo domain.UserRepository,
+	settings domain.Settings,
+	l logger.Logger,
+) *SubscriptionService {
 	l.Info("Создан экземпляр подписочного сервиса")
 	return &SubscriptionService{
-		remna:  remna,
-		dbRepo: dbRepo,
-		logger: l,
+		remna:    remna,
+		dbRepo:   dbRepo,
+		settings: settings,
+		metrics:  domain.NopMetrics{},
+		logger:   l,
 	}
 }
 
+// SetMetrics включает метрики списаний с баланса
+func (s *SubscriptionService) SetMetrics(m domain.Metrics) {
+	s.metrics = m
+}
+
 func (s *SubscriptionService) logDuration(method string) func() {
 	start := time.Now()
 
@@ -40,6 +59,14 @@ func (s *SubscriptionService) logDuration(method string) func() {
 	}
 }
 
+// withTrace копия сервиса, которая пишет логи с полями запроса и trace_id из ctx
+func (s *SubscriptionService) withTrace(ctx context.Context) *SubscriptionService {
+	traced := *s
+	traced.logger = logger.FromContext(ctx, s.logger)
+
+	return &traced
+}
+
 // logError логирует ошибку и возвращает её обернутую.
 func (s *SubscriptionService) logError(msg string, err error, fields ...logger.Field) error {
 	// Добавляем ошибку к полям
@@ -50,14 +77,27 @@ func (s *SubscriptionService) logError(msg string, err error, fields ...logger.F
 
 // ActivateSubscription активирует подписку клиенту telegram на указанное количество месяцев.
 // Если имеется подписка - продлить. Если подписки нет - создать.
-func (s *SubscriptionService) ActivateSubscription(telegramID int64, months int) (string, error) {
-	defer s.logDuration("ActivateSubscription")()
-
+func (s *SubscriptionService) ActivateSubscription(ctx context.Context, telegramID int64, months int) (string, error) {
 	// User id telegram клиента
-	username := strconv.FormatInt(telegramID, 10)
+	return s.ActivateForUser(ctx, strconv.FormatInt(telegramID, 10), months)
+}
+
+// ActivateForUser то же, что ActivateSubscription, но по id пользователя в DB.
+// Нужен для покупок с сайта, где пользователь может быть без телеграма.
+// id пользователя одновременно username в remnawave
+func (s *SubscriptionService) ActivateForUser(ctx context.Context, username string, months int) (result string, err error) {
+	ctx, span := tracer.Start(ctx, "SubscriptionService.ActivateForUser", trace.WithAttributes(
+		attribute.String("user_id", username),
+		attribute.Int("months", months),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// Логи этого вызова с trace_id, чтобы найти их по трейсу и наоборот
+	s = s.withTrace(ctx)
+	defer s.logDuration("ActivateForUser")()
 
 	// Проверяем наличия пользователя в базе данных и создаем если его нет
-	user, err := s.dbRepo.GetUserByID(username)
+	user, err := s.dbRepo.GetUserByID(ctx, username)
 
 	if err != nil {
 		// Проверяем, является ли ошибка "пользователь не найден"
@@ -66,7 +106,7 @@ func (s *SubscriptionService) ActivateSubscription(telegramID int64, months int)
 
 			// Делаем запрос DB на создание пользователя
 			// Записываем в newUser данные которые получили от DB
-			newUser, createDBErr := s.dbRepo.CreateUser(models.CreateUserTGDTO{
+			newUser, createDBErr := s.dbRepo.CreateUser(ctx, models.CreateUserTGDTO{
 				ID:      username,
 				Balance: 0,
 				Trial:   false,
@@ -87,42 +127,57 @@ func (s *SubscriptionService) ActivateSubscription(telegramID int64, months int)
 	s.logger.Info("пользователь найден", logger.Field{Key: "user_id", Value: username})
 
 	// Вычисляем на сколько дней клиенту нужна подписка
-	totalDays := months * 30
-	const pricePerMonth = 100
+	totalDays := months * domain.DaysPerMonth
 
 	// Вычисляем стоимость подписки за указанное количество месяцев
 	// Если пришла 2 месяца, 100 * на 2 = 200 итоговая цена
-	totalCost := months * pricePerMonth
+	totalCost := TariffPrice(s.settings, months)
+
+	// Списываем средства. Проверка баланса и списание - один запрос,
+	// поэтому параллельная покупка не уведет баланс в минус
+	if _, err := s.dbRepo.AddBalance(ctx, username, -totalCost); err != nil {
+		if errors.Is(err, domain.ErrInsufficientFunds) {
+			s.logger.Info("у пользователя не достаточно средств для подписки",
+				logger.Field{Key: "user_id", Value: username},
+				logger.Field{Key: "balance", Value: user.Balance},
+				logger.Field{Key: "required", Value: totalCost},
+			)
+			return "", fmt.Errorf("%w. Баланс: %d ₽, Требуется: %d ₽", domain.ErrInsufficientFunds, user.Balance, totalCost)
+		}
 
-	// Проверяем достаточно ли на балансе средств на подписку
-	if user.Balance < totalCost {
-		s.logger.Info("у пользователя не достаточно средств для подписки",
-			logger.Field{Key: "user_id", Value: username},
-			logger.Field{Key: "balance", Value: user.Balance},
-			logger.Field{Key: "required", Value: totalCost},
-		)
-		return "", fmt.Errorf("%w. Баланс: %d ₽, Требуется: %d ₽", domain.ErrInsufficientFunds, user.Balance, totalCost)
+		return "", s.logError("ошибка обновления баланса пользователя в DB", err, logger.Field{Key: "user_id", Value: username})
 	}
+	s.metrics.BalanceDebited("subscription", totalCost)
 
-	// Списываем средства
-	newBalance := user.Balance - totalCost
-	_, err = s.dbRepo.UpdateUser(username, models.UpdateUserTGDTO{
-		Balance: &newBalance,
-	})
+	result, err = s.activateInPanel(ctx, user, totalDays)
 	if err != nil {
-		return "", s.logError("ошибка обновления баланса пользователя в DB", err, logger.Field{Key: "user_id", Value: username})
+		// Подписка не выдана, деньги возвращаем: иначе повторная попытка
+		// (например проверка заказа с сайта) спишет цену второй раз
+		if refundErr := s.refund(ctx, username, totalCost); refundErr != nil {
+			return "", fmt.Errorf("%w: %w", domain.ErrNotRefunded, err)
+		}
+
+		return "", err
 	}
 
+	return result, nil
+}
+
+// activateInPanel создает пользователя в панели на days дней или продлевает его подписку
+func (s *SubscriptionService) activateInPanel(ctx context.Context, user *models.UserTG, totalDays int) (string, error) {
+	username := user.ID
+
 	// Проверяем есть ли пользователь в панели
-	userUUID, err := s.remna.GetUUIDByUsername(username)
+	userUUID, err := s.remna.GetUUIDByUsername(ctx, username)
 	if err != nil {
 		// Если пользователя нет, создаем его в панели
 		if errors.Is(err, remnawave.ErrNotFound) {
 			s.logger.Info("пользователь не найден, создаем нового", logger.Field{Key: "username", Value: username})
-			err = s.remna.CreateUser(username, totalDays)
+			err = s.remna.CreateUser(ctx, username, totalDays, UserPlan(s.settings, user))
 			if err != nil {
 				return "", s.logError("ошибка создания пользователя", err, logger.Field{Key: "username", Value: username})
 			}
+			s.applyDeviceLimit(ctx, user)
 
 			return fmt.Sprintf("пользователь %s создан на %d дней", username, totalDays), nil
 		}
@@ -132,14 +187,65 @@ func (s *SubscriptionService) ActivateSubscription(telegramID int64, months int)
 
 	s.logger.Info("пользователь найден", logger.Field{Key: "username", Value: username})
 
-	err = s.remna.ExtendClientSubscription(userUUID, username, totalDays)
+	err = s.remna.ExtendClientSubscription(ctx, userUUID, username, totalDays)
 	if err != nil {
 		return "", s.logError("ошибка продления подписки", err, logger.Field{Key: "username", Value: username})
 	}
 
+	s.applyDeviceLimit(ctx, user)
+
 	s.logger.Info("подписка продлена",
 		logger.Field{Key: "username", Value: username},
 		logger.Field{Key: "days", Value: totalDays},
 	)
 	return "подписка для пользователя " + username + " продлена на " + strconv.Itoa(totalDays) + " дней", nil
 }
+
+// refund возвращает на баланс цену неудавшейся активации
+func (s *SubscriptionService) refund(ctx context.Context, username string, cost int) error {
+	if _, err := s.dbRepo.AddBalance(ctx, username, cost); err != nil {
+		s.logger.Error("подписка не активирована и деньги не возвращены",
+			logger.Field{Key: "user_id", Value: username},
+			logger.Field{Key: "amount", Value: cost},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return err
+	}
+
+	s.logger.Info("цена неудавшейся активации возвращена на баланс",
+		logger.Field{Key: "user_id", Value: username},
+		logger.Field{Key: "amount", Value: cost},
+	)
+
+	return nil
+}
+
+// applyDeviceLimit передает в панель лимит устройств тарифа. Подписка уже
+// оплачена и активирована, поэтому ошибку только логируем: лимит
+// применится при следующем продлении или покупке устройства
+func (s *SubscriptionService) applyDeviceLimit(ctx context.Context, user *models.UserTG) {
+	limit := DeviceLimit(s.settings, user)
+	if err := s.remna.SetDevices(ctx, user.ID, limit); err != nil {
+		s.logger.Error("не удалось задать лимит устройств",
+			logger.Field{Key: "user_id", Value: user.ID},
+			logger.Field{Key: "limit", Value: limit},
+			logger.Field{Key: "error", Value: err},
+		)
+	}
+}
+
+// UserPlan параметры тарифа для нового пользователя панели: сквад
+// выбранного региона и лимит трафика из настроек
+func UserPlan(settings domain.Settings, user *models.UserTG) models.UserPlan {
+	return models.UserPlan{
+		SquadUUID:            user.Region,
+		TrafficLimitBytes:    TrafficLimit(settings, user),
+		TrafficLimitStrategy: settings.TrafficReset(),
+	}
+}
+
+// TariffPrice цена подписки на months месяцев в рублях по текущей цене месяца
+func TariffPrice(settings domain.Settings, months int) int {
+	return months * settings.PricePerMonth()
+}
@@ -0,0 +1,21 @@
+// Package service спаны бизнес-операций для трейсинга
+package service
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer спаны сервисов. Провайдер берется глобальный, без настройки - no-op
+var tracer = otel.Tracer("ProxyMaster_v2/internal/service")
+
+// endSpan закрывает спан, отмечая ошибку, если она есть
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
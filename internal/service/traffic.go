@@ -0,0 +1,306 @@
+// Package service трафик подписки: пакеты сверх лимита тарифа и
+// предупреждения, когда трафик подходит к концу. Израсходованный трафик
+// знает только панель, поэтому ее периодически опрашиваем. Пакеты действуют
+// до сброса трафика в панели, после сброса лимит возвращается к тарифу.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// trafficPageSize сколько пользователей панели запрашивать за раз
+const trafficPageSize = 500
+
+// Проверяем на этапе компиляции, что TrafficService реализует интерфейс
+var _ domain.TrafficService = (*TrafficService)(nil)
+
+// TrafficService трафик подписки
+type TrafficService struct {
+	remna domain.RemnawaveClient
+	users domain.UserRepository
+	// settings цена гигабайта
+	settings domain.Settings
+	metrics  domain.Metrics
+	// notify отправляет предупреждение о трафике, nil - не предупреждаем
+	notify domain.TrafficNotifyFunc
+	logger logger.Logger
+}
+
+// NewTrafficService конструктор сервиса.
+func NewTrafficService(
+	remna domain.RemnawaveClient,
+	users domain.UserRepository,
+	settings domain.Settings,
+	l logger.Logger,
+) *TrafficService {
+	l.Info("Создан экземпляр сервиса трафика")
+	return &TrafficService{
+		remna:    remna,
+		users:    users,
+		settings: settings,
+		metrics:  domain.NopMetrics{},
+		logger:   l,
+	}
+}
+
+// SetMetrics включает метрики списаний с баланса
+func (s *TrafficService) SetMetrics(m domain.Metrics) {
+	s.metrics = m
+}
+
+// SetNotifier включает предупреждения о трафике через fn
+func (s *TrafficService) SetNotifier(fn domain.TrafficNotifyFunc) {
+	s.notify = fn
+}
+
+// Traffic израсходованный трафик и лимит из панели
+func (s *TrafficService) Traffic(ctx context.Context, userID string) (domain.TrafficInfo, error) {
+	userUUID, err := panelUUID(ctx, s.remna, userID)
+	if err != nil {
+		return domain.TrafficInfo{}, err
+	}
+
+	return s.traffic(ctx, userUUID)
+}
+
+// Packs пакеты трафика по текущей цене гигабайта
+func (s *TrafficService) Packs() []domain.TrafficPack {
+	packs := make([]domain.TrafficPack, 0, len(domain.TrafficPacksGB))
+	for _, gb := range domain.TrafficPacksGB {
+		packs = append(packs, domain.TrafficPack{GB: gb, Price: gb * s.settings.PricePerGB()})
+	}
+
+	return packs
+}
+
+// BuyPack покупка пакета: лимит поднимается на gb до следующего сброса трафика
+func (s *TrafficService) BuyPack(ctx context.Context, userID string, gb int) (domain.TrafficInfo, error) {
+	log := logger.FromContext(ctx, s.logger)
+
+	if !slices.Contains(domain.TrafficPacksGB, gb) {
+		return domain.TrafficInfo{}, fmt.Errorf("неизвестный пакет трафика: %d ГБ", gb)
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return domain.TrafficInfo{}, err
+	}
+
+	userUUID, err := panelUUID(ctx, s.remna, userID)
+	if err != nil {
+		return domain.TrafficInfo{}, err
+	}
+
+	panelUser, err := s.remna.GetUserInfo(ctx, userUUID)
+	if err != nil {
+		return domain.TrafficInfo{}, fmt.Errorf("ошибка получения трафика: %w", err)
+	}
+	info := domain.TrafficInfo{
+		Used:  int64(panelUser.Response.UserTraffic.UsedTrafficBytes),
+		Limit: int64(panelUser.Response.TrafficLimitBytes),
+	}
+	if info.Limit == 0 || s.settings.TrafficLimitGB() == 0 {
+		// info нужен, чтобы показать израсходованный трафик
+		return info, domain.ErrUnlimitedTraffic
+	}
+
+	// Пакеты прошлого периода не должны попасть в новый лимит
+	if resetAt := panelUser.Response.LastTrafficResetAt; user.TrafficPacksGB > 0 && resetAt != nil {
+		if _, err := s.users.ResetTrafficPacks(ctx, userID, *resetAt); err != nil {
+			return domain.TrafficInfo{}, fmt.Errorf("ошибка сброса пакетов трафика: %w", err)
+		}
+	}
+
+	// Проверка баланса и списание - один запрос, поэтому параллельная
+	// покупка не уведет баланс в минус
+	price := gb * s.settings.PricePerGB()
+	balance := user.Balance
+	user, err = s.users.AddTrafficPack(ctx, userID, gb, price)
+	if errors.Is(err, domain.ErrInsufficientFunds) {
+		return domain.TrafficInfo{}, fmt.Errorf("%w. Баланс: %d ₽, Требуется: %d ₽", domain.ErrInsufficientFunds, balance, price)
+	}
+	if err != nil {
+		return domain.TrafficInfo{}, fmt.Errorf("ошибка списания за трафик: %w", err)
+	}
+
+	info.Limit = TrafficLimit(s.settings, user)
+	if err := s.remna.SetTrafficLimit(ctx, userUUID, info.Limit); err != nil {
+		// Лимит не изменился, поэтому и пакет, и деньги возвращаем сразу
+		if _, cancelErr := s.users.CancelTrafficPack(ctx, userID, gb, price); cancelErr != nil {
+			log.Error("пакет трафика не применен и деньги не возвращены",
+				logger.Field{Key: "user_id", Value: userID},
+				logger.Field{Key: "price", Value: price},
+				logger.Field{Key: "error", Value: cancelErr},
+			)
+
+			return domain.TrafficInfo{}, fmt.Errorf("%w: %w", domain.ErrNotRefunded, err)
+		}
+
+		return domain.TrafficInfo{}, fmt.Errorf("ошибка изменения лимита трафика: %w", err)
+	}
+	s.metrics.BalanceDebited("traffic", price)
+
+	log.Info("куплен пакет трафика",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "gb", Value: gb},
+		logger.Field{Key: "price", Value: price},
+	)
+
+	return info, nil
+}
+
+// TrafficLimit лимит трафика пользователя в байтах: из тарифа плюс пакеты
+// текущего периода. 0 - безлимит
+func TrafficLimit(settings domain.Settings, user *models.UserTG) int64 {
+	base := int64(settings.TrafficLimitGB()) * domain.GB
+	if base == 0 {
+		return 0
+	}
+
+	return base + int64(user.TrafficPacksGB)*domain.GB
+}
+
+// Watch проверяет трафик всех пользователей каждые interval, пока не отменят ctx
+func (s *TrafficService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.checkAll(ctx); err != nil {
+				s.logger.Warn("трафик пользователей не проверен", logger.Field{Key: "error", Value: err})
+			}
+		}
+	}
+}
+
+// checkAll проходит по пользователям панели: убирает пакеты после сброса
+// трафика и отправляет предупреждения
+func (s *TrafficService) checkAll(ctx context.Context) error {
+	dbUsers, err := s.users.GetAllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+	known := make(map[string]*models.UserTG, len(dbUsers))
+	for i := range dbUsers {
+		known[dbUsers[i].ID] = &dbUsers[i]
+	}
+
+	for start := 0; ; start += trafficPageSize {
+		page, err := s.remna.GetUsers(ctx, start, trafficPageSize)
+		if err != nil {
+			return fmt.Errorf("ошибка получения пользователей панели: %w", err)
+		}
+
+		for _, pu := range page.Response.Users {
+			user, ok := known[pu.Username]
+			if !ok {
+				continue
+			}
+
+			info := domain.TrafficInfo{
+				Used:  int64(pu.UserTraffic.UsedTrafficBytes),
+				Limit: pu.TrafficLimitBytes,
+			}
+			if user.TrafficPacksGB > 0 && pu.LastTrafficResetAt != nil {
+				if limit, reset := s.resetPacks(ctx, user, pu.UUID, *pu.LastTrafficResetAt); reset {
+					info.Limit = limit
+				}
+			}
+
+			// LIMITED - трафик кончился, об этом тоже предупреждаем.
+			// Истекшие и выключенные подписки не трогаем
+			if s.notify == nil || (pu.Status != "ACTIVE" && pu.Status != "LIMITED") {
+				continue
+			}
+
+			s.checkUser(ctx, user, info)
+		}
+
+		if len(page.Response.Users) < trafficPageSize || start+trafficPageSize >= page.Response.Total {
+			return nil
+		}
+	}
+}
+
+// resetPacks убирает пакеты, купленные до сброса трафика resetAt, и
+// возвращает лимит тарифа в панель. true и новый лимит, если пакеты убраны
+func (s *TrafficService) resetPacks(ctx context.Context, user *models.UserTG, userUUID string, resetAt time.Time) (int64, bool) {
+	reset, err := s.users.ResetTrafficPacks(ctx, user.ID, resetAt)
+	if err != nil || !reset {
+		return 0, false
+	}
+
+	user.TrafficPacksGB = 0
+	limit := TrafficLimit(s.settings, user)
+	if err := s.remna.SetTrafficLimit(ctx, userUUID, limit); err != nil {
+		s.logger.Error("пакеты трафика убраны, но лимит в панели не изменен",
+			logger.Field{Key: "user_id", Value: user.ID},
+			logger.Field{Key: "limit", Value: limit},
+			logger.Field{Key: "error", Value: err},
+		)
+
+		return 0, false
+	}
+
+	s.logger.Info("пакеты трафика закончились со сбросом трафика", logger.Field{Key: "user_id", Value: user.ID})
+
+	return limit, true
+}
+
+// checkUser предупреждает о каждом пороге один раз. Когда трафик сбросился
+// или куплен пакет, процент падает, и порог снова сработает позже
+func (s *TrafficService) checkUser(ctx context.Context, user *models.UserTG, info domain.TrafficInfo) {
+	level := 0
+	for _, threshold := range domain.TrafficThresholds {
+		if info.Percent() >= threshold {
+			level = threshold
+		}
+	}
+	if level == user.TrafficNotified {
+		return
+	}
+
+	if level > user.TrafficNotified {
+		if err := s.notify(ctx, user.ID, info, level); err != nil {
+			// Отметку не сохраняем, чтобы попробовать снова при следующей проверке
+			s.logger.Warn("предупреждение о трафике не отправлено",
+				logger.Field{Key: "user_id", Value: user.ID},
+				logger.Field{Key: "error", Value: err},
+			)
+
+			return
+		}
+	}
+
+	if _, err := s.users.UpdateUser(ctx, user.ID, models.UpdateUserTGDTO{TrafficNotified: &level}); err != nil {
+		s.logger.Error("не удалось сохранить отметку о предупреждении",
+			logger.Field{Key: "user_id", Value: user.ID},
+			logger.Field{Key: "error", Value: err},
+		)
+	}
+}
+
+// traffic трафик пользователя панели userUUID
+func (s *TrafficService) traffic(ctx context.Context, userUUID string) (domain.TrafficInfo, error) {
+	info, err := s.remna.GetUserInfo(ctx, userUUID)
+	if err != nil {
+		return domain.TrafficInfo{}, fmt.Errorf("ошибка получения трафика: %w", err)
+	}
+
+	return domain.TrafficInfo{
+		Used:  int64(info.Response.UserTraffic.UsedTrafficBytes),
+		Limit: int64(info.Response.TrafficLimitBytes),
+	}, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+)
+
+// Трафик в тестах: 100 ГБ в тарифе, гигабайт по 2 ₽
+const (
+	testTrafficGB  = 100
+	testPricePerGB = 2
+)
+
+// fakeTrafficSettings настройки трафика
+type fakeTrafficSettings struct {
+	domain.Settings
+}
+
+// TrafficLimitGB трафик тарифа
+func (fakeTrafficSettings) TrafficLimitGB() int { return testTrafficGB }
+
+// PricePerGB цена гигабайта
+func (fakeTrafficSettings) PricePerGB() int { return testPricePerGB }
+
+// fakeTrafficUsers один пользователь со списаниями за пакеты
+type fakeTrafficUsers struct {
+	domain.UserRepository
+
+	user      models.UserTG
+	cancelErr error
+}
+
+// GetUserByID копия пользователя
+func (f *fakeTrafficUsers) GetUserByID(_ context.Context, _ string) (*models.UserTG, error) {
+	user := f.user
+	return &user, nil
+}
+
+// AddTrafficPack списывает price и добавляет пакет
+func (f *fakeTrafficUsers) AddTrafficPack(_ context.Context, _ string, gb, price int) (*models.UserTG, error) {
+	if f.user.Balance < price {
+		return nil, domain.ErrInsufficientFunds
+	}
+	f.user.Balance -= price
+	f.user.TrafficPacksGB += gb
+
+	user := f.user
+	return &user, nil
+}
+
+// CancelTrafficPack возвращает price и убирает пакет или возвращает cancelErr
+func (f *fakeTrafficUsers) CancelTrafficPack(_ context.Context, _ string, gb, price int) (*models.UserTG, error) {
+	if f.cancelErr != nil {
+		return nil, f.cancelErr
+	}
+	f.user.Balance += price
+	f.user.TrafficPacksGB -= gb
+
+	user := f.user
+	return &user, nil
+}
+
+// fakeTrafficPanel панель, в которой SetTrafficLimit возвращает err
+type fakeTrafficPanel struct {
+	domain.RemnawaveClient
+
+	err   error
+	limit int64
+}
+
+// GetUUIDByUsername подписка есть у всех
+func (f *fakeTrafficPanel) GetUUIDByUsername(_ context.Context, username string) (string, error) {
+	return "uuid-" + username, nil
+}
+
+// GetUserInfo подписка с лимитом тарифа
+func (f *fakeTrafficPanel) GetUserInfo(_ context.Context, _ string) (models.GetUserInfoResponse, error) {
+	var info models.GetUserInfoResponse
+	info.Response.TrafficLimitBytes = int(testTrafficGB * domain.GB)
+
+	return info, nil
+}
+
+// SetTrafficLimit запоминает лимит или возвращает err
+func (f *fakeTrafficPanel) SetTrafficLimit(_ context.Context, _ string, limit int64) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.limit = limit
+
+	return nil
+}
+
+func TestBuyPack(t *testing.T) {
+	panelDown := errors.New("panel down")
+	gb := domain.TrafficPacksGB[0]
+	price := gb * testPricePerGB
+
+	tests := []struct {
+		name      string
+		panelErr  error
+		cancelErr error
+		// wantErr nil - покупка прошла
+		wantErr     error
+		wantBalance int
+		wantPacksGB int
+	}{
+		{name: "лимит применен", wantBalance: 1000 - price, wantPacksGB: gb},
+		{name: "панель не ответила", panelErr: panelDown, wantErr: panelDown, wantBalance: 1000},
+		{
+			name:        "панель не ответила и деньги не вернулись",
+			panelErr:    panelDown,
+			cancelErr:   errors.New("db down"),
+			wantErr:     domain.ErrNotRefunded,
+			wantBalance: 1000 - price,
+			wantPacksGB: gb,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeTrafficUsers{user: models.UserTG{ID: "1", Balance: 1000}, cancelErr: tt.cancelErr}
+			panel := &fakeTrafficPanel{err: tt.panelErr}
+			s := NewTrafficService(panel, users, fakeTrafficSettings{}, newTestLogger(t))
+
+			info, err := s.BuyPack(context.Background(), "1", gb)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("BuyPack: %v", err)
+				}
+				want := int64(testTrafficGB+gb) * domain.GB
+				if info.Limit != want || panel.limit != want {
+					t.Errorf("лимит %d, в панели %d, ожидали %d", info.Limit, panel.limit, want)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BuyPack: %v, ожидали %v", err, tt.wantErr)
+			}
+
+			if users.user.Balance != tt.wantBalance || users.user.TrafficPacksGB != tt.wantPacksGB {
+				t.Errorf("баланс %d, пакеты %d ГБ, ожидали %d и %d",
+					users.user.Balance, users.user.TrafficPacksGB, tt.wantBalance, tt.wantPacksGB)
+			}
+		})
+	}
+}
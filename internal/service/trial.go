@@ -0,0 +1,120 @@
+// Package service пробный период. Длину задает настройка trial_days,
+// выдается один раз и только тем, у кого еще не было подписки.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+// Проверяем на этапе компиляции, что TrialService реализует интерфейс
+var _ domain.TrialService = (*TrialService)(nil)
+
+// TrialService выдача пробного периода
+type TrialService struct {
+	remna domain.RemnawaveClient
+	users domain.UserRepository
+	// settings длина пробного периода, меняется без рестарта
+	settings domain.Settings
+	logger   logger.Logger
+}
+
+// NewTrialService конструктор сервиса.
+func NewTrialService(
+	remna domain.RemnawaveClient,
+	users domain.UserRepository,
+	settings domain.Settings,
+	l logger.Logger,
+) *TrialService {
+	l.Info("Создан экземпляр сервиса пробного периода")
+	return &TrialService{
+		remna:    remna,
+		users:    users,
+		settings: settings,
+		logger:   l,
+	}
+}
+
+// ActivateTrial создает пользователя в панели на settings.TrialDays дней.
+// ErrTrialDisabled если пробный период выключен, ErrTrialUsed если он уже
+// был или пользователь уже есть в панели
+func (s *TrialService) ActivateTrial(ctx context.Context, telegramID int64) (int, error) {
+	log := logger.FromContext(ctx, s.logger)
+	userID := strconv.FormatInt(telegramID, 10)
+
+	// Настройку читаем один раз: админ может поменять ее посреди выдачи
+	days := s.settings.TrialDays()
+	if days == 0 {
+		return 0, domain.ErrTrialDisabled
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		user, err = s.users.CreateUser(ctx, models.CreateUserTGDTO{ID: userID})
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	if user.Trial {
+		return 0, domain.ErrTrialUsed
+	}
+
+	// Кто уже покупал подписку, пробный период не получает
+	_, err = s.remna.GetUUIDByUsername(ctx, userID)
+	if err == nil {
+		return 0, domain.ErrTrialUsed
+	}
+	if !errors.Is(err, remnawave.ErrNotFound) {
+		return 0, fmt.Errorf("ошибка поиска пользователя в панели: %w", err)
+	}
+
+	claimed, err := s.users.ClaimTrial(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отметки пробного периода: %w", err)
+	}
+	if !claimed {
+		return 0, domain.ErrTrialUsed
+	}
+
+	if err := s.remna.CreateUser(ctx, userID, days, UserPlan(s.settings, user)); err != nil {
+		// Подписка не выдана, пробный период остается доступным
+		s.releaseTrial(ctx, userID)
+
+		return 0, fmt.Errorf("ошибка создания пользователя в панели: %w", err)
+	}
+
+	// Подписка уже выдана, лимит применится при покупке устройства или продлении
+	limit := DeviceLimit(s.settings, user)
+	if err := s.remna.SetDevices(ctx, userID, limit); err != nil {
+		log.Error("не удалось задать лимит устройств",
+			logger.Field{Key: "user_id", Value: userID},
+			logger.Field{Key: "limit", Value: limit},
+			logger.Field{Key: "error", Value: err},
+		)
+	}
+
+	log.Info("выдан пробный период",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "days", Value: days},
+	)
+
+	return days, nil
+}
+
+// releaseTrial снимает отметку о пробном периоде, если выдать его не удалось
+func (s *TrialService) releaseTrial(ctx context.Context, userID string) {
+	trial := false
+	if _, err := s.users.UpdateUser(ctx, userID, models.UpdateUserTGDTO{Trial: &trial}); err != nil {
+		logger.FromContext(ctx, s.logger).Error("пробный период не выдан и остался отмеченным",
+			logger.Field{Key: "user_id", Value: userID},
+			logger.Field{Key: "error", Value: err},
+		)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/infrastructure/remnawave"
+	"ProxyMaster_v2/internal/models"
+)
+
+// fakeTrialSettings настройки с пробным периодом на days дней
+type fakeTrialSettings struct {
+	domain.Settings
+
+	days int
+}
+
+// TrialDays длина пробного периода
+func (f fakeTrialSettings) TrialDays() int { return f.days }
+
+// Devices устройств в подписке
+func (fakeTrialSettings) Devices() int { return testDevices }
+
+// TrafficLimitGB безлимит
+func (fakeTrialSettings) TrafficLimitGB() int { return 0 }
+
+// TrafficReset без сброса
+func (fakeTrialSettings) TrafficReset() string { return domain.TrafficResetNever }
+
+// fakeTrialUsers один пользователь с отметкой пробного периода
+type fakeTrialUsers struct {
+	domain.UserRepository
+
+	user models.UserTG
+}
+
+// GetUserByID копия пользователя
+func (f *fakeTrialUsers) GetUserByID(_ context.Context, _ string) (*models.UserTG, error) {
+	user := f.user
+	return &user, nil
+}
+
+// ClaimTrial отмечает пробный период, false если он уже отмечен
+func (f *fakeTrialUsers) ClaimTrial(_ context.Context, _ string) (bool, error) {
+	if f.user.Trial {
+		return false, nil
+	}
+	f.user.Trial = true
+
+	return true, nil
+}
+
+// UpdateUser меняет только отметку пробного периода
+func (f *fakeTrialUsers) UpdateUser(_ context.Context, _ string, data models.UpdateUserTGDTO) (*models.UserTG, error) {
+	if data.Trial != nil {
+		f.user.Trial = *data.Trial
+	}
+
+	user := f.user
+	return &user, nil
+}
+
+// fakeTrialPanel панель, в которой пользователь есть или нет
+type fakeTrialPanel struct {
+	domain.RemnawaveClient
+
+	exists    bool
+	createErr error
+	// days на сколько дней создан пользователь, 0 - не создан
+	days int
+}
+
+// GetUUIDByUsername uuid, если пользователь есть в панели
+func (f *fakeTrialPanel) GetUUIDByUsername(_ context.Context, username string) (string, error) {
+	if !f.exists {
+		return "", remnawave.ErrNotFound
+	}
+
+	return "uuid-" + username, nil
+}
+
+// CreateUser запоминает срок или возвращает createErr
+func (f *fakeTrialPanel) CreateUser(_ context.Context, _ string, days int, _ models.UserPlan) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.days = days
+
+	return nil
+}
+
+// SetDevices лимит устройств не проверяем
+func (f *fakeTrialPanel) SetDevices(_ context.Context, _ string, _ int) error {
+	return nil
+}
+
+func TestActivateTrial(t *testing.T) {
+	tests := []struct {
+		name      string
+		days      int
+		used      bool
+		inPanel   bool
+		createErr error
+		wantErr   error
+		// wantTrial отметка пробного периода после вызова
+		wantTrial bool
+	}{
+		{name: "выдан", days: 3, wantTrial: true},
+		{name: "выключен настройкой", days: 0, wantErr: domain.ErrTrialDisabled},
+		{name: "уже был", days: 3, used: true, wantErr: domain.ErrTrialUsed, wantTrial: true},
+		{name: "подписка уже была", days: 3, inPanel: true, wantErr: domain.ErrTrialUsed},
+		{name: "панель не ответила", days: 3, createErr: errors.New("panel down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeTrialUsers{user: models.UserTG{ID: "1", Trial: tt.used}}
+			panel := &fakeTrialPanel{exists: tt.inPanel, createErr: tt.createErr}
+			s := NewTrialService(panel, users, fakeTrialSettings{days: tt.days}, newTestLogger(t))
+
+			days, err := s.ActivateTrial(context.Background(), 1)
+			switch {
+			case tt.createErr != nil:
+				if !errors.Is(err, tt.createErr) {
+					t.Fatalf("ActivateTrial: %v, ожидали %v", err, tt.createErr)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("ActivateTrial: %v, ожидали %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && tt.createErr == nil {
+				if days != tt.days || panel.days != tt.days {
+					t.Errorf("выдано %d дней, в панели %d, ожидали %d", days, panel.days, tt.days)
+				}
+			} else if panel.days != 0 {
+				t.Errorf("пользователь создан в панели на %d дней", panel.days)
+			}
+			if users.user.Trial != tt.wantTrial {
+				t.Errorf("отметка пробного периода %v, ожидали %v", users.user.Trial, tt.wantTrial)
+			}
+		})
+	}
+}
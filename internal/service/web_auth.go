@@ -0,0 +1,291 @@
+// Package service вход на сайт без пароля: одноразовый код приходит
+// в телеграм (вход по Telegram ID) или на почту (вход по email).
+// Коды живут в памяти несколько минут, сессия - подписанный HMAC токен.
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ProxyMaster_v2/internal/domain"
+	"ProxyMaster_v2/internal/models"
+	"ProxyMaster_v2/pkg/logger"
+)
+
+const (
+	// loginCodeTTL сколько действует код
+	loginCodeTTL = 10 * time.Minute
+	// loginCodeResend через сколько можно запросить новый код
+	loginCodeResend = time.Minute
+	// loginCodeAttempts попыток ввода на один код
+	loginCodeAttempts = 5
+	// sessionTTL сколько живет вход на сайт
+	sessionTTL = 30 * 24 * time.Hour
+	// webUserPrefix id пользователей, пришедших с сайта по email
+	webUserPrefix = "web_"
+)
+
+// loginCode выданный код и его состояние
+type loginCode struct {
+	code      string
+	expiresAt time.Time
+	sentAt    time.Time
+	attempts  int
+}
+
+// WebAuthService вход на сайт по одноразовому коду
+type WebAuthService struct {
+	users domain.UserRepository
+	// telegram доставляет код в бота, email - на почту (nil если почта не настроена)
+	telegram domain.LoginCodeSender
+	email    domain.LoginCodeSender
+	// secret ключ подписи сессий
+	secret []byte
+	logger logger.Logger
+
+	mu    sync.Mutex
+	codes map[string]*loginCode
+}
+
+// NewWebAuthService конструктор сервиса. emailSender может быть nil, тогда
+// вход по email выключен. Пустой secret - случайный ключ, сессии не переживут рестарт
+func NewWebAuthService(
+	users domain.UserRepository,
+	telegramSender domain.LoginCodeSender,
+	emailSender domain.LoginCodeSender,
+	secret string,
+	l logger.Logger,
+) (*WebAuthService, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("ошибка генерации ключа сессий: %w", err)
+		}
+		l.Warn("ключ сессий сайта не задан, после рестарта всем придется войти заново")
+	}
+
+	l.Info("Создан экземпляр сервиса входа на сайт")
+	return &WebAuthService{
+		users:    users,
+		telegram: telegramSender,
+		email:    emailSender,
+		secret:   key,
+		logger:   l,
+		codes:    make(map[string]*loginCode),
+	}, nil
+}
+
+// parseLogin приводит логин к виду ключа: Telegram ID как есть, email в нижнем регистре
+func parseLogin(login string) (string, bool, error) {
+	login = strings.TrimSpace(login)
+
+	if _, err := strconv.ParseInt(login, 10, 64); err == nil && !strings.HasPrefix(login, "-") {
+		return login, false, nil
+	}
+
+	addr, err := mail.ParseAddress(login)
+	if err != nil || addr.Address != login {
+		return "", false, domain.ErrInvalidLogin
+	}
+
+	return strings.ToLower(addr.Address), true, nil
+}
+
+// RequestCode отправляет код на login. Повторно не чаще раза в минуту
+func (s *WebAuthService) RequestCode(ctx context.Context, login string) error {
+	login, isEmail, err := parseLogin(login)
+	if err != nil {
+		return err
+	}
+
+	sender := s.telegram
+	if isEmail {
+		sender = s.email
+	}
+	if sender == nil {
+		return fmt.Errorf("%w: вход по email выключен", domain.ErrInvalidLogin)
+	}
+
+	code, err := newLoginCode()
+	if err != nil {
+		return fmt.Errorf("ошибка генерации кода: %w", err)
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	s.cleanup(now)
+	if prev, ok := s.codes[login]; ok && now.Sub(prev.sentAt) < loginCodeResend {
+		s.mu.Unlock()
+		return domain.ErrCodeRequestedRecently
+	}
+	s.codes[login] = &loginCode{code: code, expiresAt: now.Add(loginCodeTTL), sentAt: now}
+	s.mu.Unlock()
+
+	if err := sender.SendLoginCode(ctx, login, code); err != nil {
+		// Код не дошел - не заставляем ждать минуту до следующей попытки
+		s.mu.Lock()
+		delete(s.codes, login)
+		s.mu.Unlock()
+
+		return fmt.Errorf("ошибка отправки кода: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyCode проверяет код. После нескольких неверных попыток код сгорает
+func (s *WebAuthService) VerifyCode(ctx context.Context, login, code string) (string, error) {
+	login, isEmail, err := parseLogin(login)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	entry, ok := s.codes[login]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(s.codes, login)
+		s.mu.Unlock()
+		return "", domain.ErrInvalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(entry.code), []byte(strings.TrimSpace(code))) != 1 {
+		entry.attempts++
+		if entry.attempts >= loginCodeAttempts {
+			delete(s.codes, login)
+		}
+		s.mu.Unlock()
+		return "", domain.ErrInvalidCode
+	}
+
+	delete(s.codes, login)
+	s.mu.Unlock()
+
+	if isEmail {
+		return s.emailUser(ctx, login)
+	}
+
+	return s.telegramUser(ctx, login)
+}
+
+// telegramUser пользователь по Telegram ID, создаем как в боте, если его нет
+func (s *WebAuthService) telegramUser(ctx context.Context, telegramID string) (string, error) {
+	_, err := s.users.GetUserByID(ctx, telegramID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		_, err = s.users.CreateUser(ctx, models.CreateUserTGDTO{ID: telegramID})
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	return telegramID, nil
+}
+
+// emailUser пользователь по email. Новому выдаем id с префиксом web_,
+// он же будет username в remnawave
+func (s *WebAuthService) emailUser(ctx context.Context, email string) (string, error) {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err == nil {
+		return user.ID, nil
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		return "", fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("ошибка генерации id: %w", err)
+	}
+
+	user, err = s.users.CreateUser(ctx, models.CreateUserTGDTO{
+		ID:    webUserPrefix + hex.EncodeToString(suffix),
+		Email: &email,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания пользователя: %w", err)
+	}
+
+	s.logger.Info("зарегистрирован пользователь сайта", logger.Field{Key: "user_id", Value: user.ID})
+
+	return user.ID, nil
+}
+
+// NewSession токен вида base64(id|срок).base64(hmac)
+func (s *WebAuthService) NewSession(userID string) (string, time.Time) {
+	expiresAt := time.Now().Add(sessionTTL)
+	payload := userID + "|" + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+
+	return token, expiresAt
+}
+
+// SessionUser проверяет подпись и срок токена
+func (s *WebAuthService) SessionUser(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", domain.ErrInvalidSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", domain.ErrInvalidSession
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.sign(string(payload))) {
+		return "", domain.ErrInvalidSession
+	}
+
+	userID, expires, ok := strings.Cut(string(payload), "|")
+	if !ok {
+		return "", domain.ErrInvalidSession
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", domain.ErrInvalidSession
+	}
+
+	return userID, nil
+}
+
+// sign HMAC-SHA256 от payload
+func (s *WebAuthService) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
+
+// cleanup удаляет истекшие коды, чтобы карта не росла. Вызывать под mu
+func (s *WebAuthService) cleanup(now time.Time) {
+	for login, entry := range s.codes {
+		if now.After(entry.expiresAt) {
+			delete(s.codes, login)
+		}
+	}
+}
+
+// newLoginCode случайный код из 6 цифр
+func newLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
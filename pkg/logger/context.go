@@ -0,0 +1,46 @@
+// Package logger поля запроса в ctx: update_id, user_id и trace_id попадают
+// во все логи обработки одного обновления, даже если компонент про них не знает.
+// По trace_id из лога в Loki находится весь трейс запроса, и наоборот.
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ctxFieldsKey ключ полей запроса в ctx
+type ctxFieldsKey struct{}
+
+// WithContext добавляет поля к тем, что уже лежат в ctx.
+// Обычно update_id и user_id на входе обновления
+func WithContext(ctx context.Context, fields ...Field) context.Context {
+	prev, _ := ctx.Value(ctxFieldsKey{}).([]Field)
+
+	// Копируем, чтобы дочерние ctx не писали в общий срез родителя
+	merged := make([]Field, 0, len(prev)+len(fields))
+	merged = append(merged, prev...)
+	merged = append(merged, fields...)
+
+	return context.WithValue(ctx, ctxFieldsKey{}, merged)
+}
+
+// FromContext логгер компонента l с полями запроса из ctx и trace_id/span_id
+// текущего спана. Без полей и спана возвращает l как есть
+func FromContext(ctx context.Context, l Logger) Logger {
+	fields, _ := ctx.Value(ctxFieldsKey{}).([]Field)
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.IsValid() {
+		fields = append(fields[:len(fields):len(fields)],
+			Field{Key: "trace_id", Value: spanContext.TraceID().String()},
+			Field{Key: "span_id", Value: spanContext.SpanID().String()},
+		)
+	}
+
+	if len(fields) == 0 {
+		return l
+	}
+
+	return l.With(fields...)
+}
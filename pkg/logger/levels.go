@@ -0,0 +1,166 @@
+// Package logger уровни логов по модулям. Модуль - имя из Named:
+// "remnawave", "telegram" и т.д. Уровни можно менять на ходу.
+package logger
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels уровень по умолчанию и уровни отдельных модулей
+type Levels struct {
+	mu           sync.RWMutex
+	defaultLevel zapcore.Level
+	modules      map[string]zapcore.Level
+
+	// initial уровни из конфига, к ним возвращает Reset
+	initialDefault zapcore.Level
+	initialModules map[string]zapcore.Level
+}
+
+// NewLevels уровни из конфига. defaultLevel - уровень всех модулей (пусто - info),
+// modules - исключения вида "remnawave=debug,telegram=warn"
+func NewLevels(defaultLevel, modules string) (*Levels, error) {
+	def := zapcore.InfoLevel
+	if defaultLevel != "" {
+		var err error
+		if def, err = zapcore.ParseLevel(defaultLevel); err != nil {
+			return nil, fmt.Errorf("неверный уровень логов %q: %w", defaultLevel, err)
+		}
+	}
+
+	parsed := make(map[string]zapcore.Level)
+	for _, item := range strings.Split(modules, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, raw, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("неверный уровень модуля %q, нужно модуль=уровень", item)
+		}
+		level, err := zapcore.ParseLevel(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("неверный уровень модуля %q: %w", item, err)
+		}
+		parsed[strings.TrimSpace(name)] = level
+	}
+
+	return &Levels{
+		defaultLevel:   def,
+		modules:        parsed,
+		initialDefault: def,
+		initialModules: maps.Clone(parsed),
+	}, nil
+}
+
+// Set меняет уровень модуля. Пустой module - уровень по умолчанию
+func (l *Levels) Set(module, level string) error {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("неверный уровень логов %q: %w", level, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if module == "" {
+		l.defaultLevel = parsed
+	} else {
+		l.modules[module] = parsed
+	}
+
+	return nil
+}
+
+// Reset возвращает уровни из конфига
+func (l *Levels) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.defaultLevel = l.initialDefault
+	l.modules = maps.Clone(l.initialModules)
+}
+
+// Snapshot текущие уровни: "" - по умолчанию, остальные ключи - модули
+func (l *Levels) Snapshot() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make(map[string]string, len(l.modules)+1)
+	result[""] = l.defaultLevel.String()
+	for name, level := range l.modules {
+		result[name] = level.String()
+	}
+
+	return result
+}
+
+// enabled пишет ли модуль name записи уровня level. Для вложенных имен
+// ("telegram.webhook") ищется самый точный заданный модуль: сначала
+// "telegram.webhook", потом "telegram"
+func (l *Levels) enabled(name string, level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for name != "" {
+		if moduleLevel, ok := l.modules[name]; ok {
+			return moduleLevel.Enabled(level)
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+
+	return l.defaultLevel.Enabled(level)
+}
+
+// anyEnabled пишет ли хоть один модуль записи уровня level
+func (l *Levels) anyEnabled(level zapcore.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.defaultLevel.Enabled(level) {
+		return true
+	}
+	for _, moduleLevel := range l.modules {
+		if moduleLevel.Enabled(level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// levelCore core, который решает по имени логгера, писать ли запись
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+// Enabled грубая проверка без имени модуля, точная - в Check
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.levels.anyEnabled(level)
+}
+
+// With добавляет поля, сохраняя проверку уровней
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+// Check пропускает запись, если ее уровень включен для модуля
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.enabled(ent.LoggerName, ent.Level) {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
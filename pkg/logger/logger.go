@@ -4,9 +4,12 @@ package logger
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Field описывает поле лога (ключ-значение).
@@ -35,37 +38,140 @@ type zapLogger struct {
 	logger *zap.Logger
 }
 
+// Форматы вывода
+const (
+	// ModeJSON JSON для Loki, по умолчанию
+	ModeJSON = "json"
+	// ModeConsole цветной текст для локальной разработки
+	ModeConsole = "console"
+)
+
+// Options настройки логгера
+type Options struct {
+	// Mode формат вывода в консоль: ModeJSON или ModeConsole
+	Mode string
+	// Levels уровни по умолчанию и по модулям, см. NewLevels.
+	// nil - info для всех
+	Levels *Levels
+
+	// File путь к файлу логов (всегда JSON). Пусто - только консоль
+	File string
+	// FileMaxSizeMB размер файла, после которого он ротируется. 0 - 100 MB
+	FileMaxSizeMB int
+	// FileMaxBackups сколько старых файлов хранить. 0 - все
+	FileMaxBackups int
+	// FileMaxAgeDays сколько дней хранить старые файлы. 0 - без ограничения
+	FileMaxAgeDays int
+
+	// SampleInitial и SampleThereafter прореживание debug логов: в секунду
+	// пишутся первые SampleInitial одинаковых сообщений, дальше каждое
+	// SampleThereafter-е. 0 - без прореживания. Info и выше не прореживаются
+	SampleInitial    int
+	SampleThereafter int
+
+	// Secrets значения из конфига (токены, пароли), которые маскируются в логах
+	// вдобавок к шаблонам из redact.go
+	Secrets []string
+}
+
 // New создаем экземпляр zap логгер.
-func New(level string) (Logger, error) {
-	// Конфигурация по умолчанию (Prod)
-	cfg := zap.NewProductionConfig()
-
-	// Установка уровня логирования
-	if level != "" {
-		l, err := zap.ParseAtomicLevel(level)
-		if err == nil {
-			cfg.Level = l
+func New(opts Options) (Logger, error) {
+	levels := opts.Levels
+	if levels == nil {
+		var err error
+		if levels, err = NewLevels("", ""); err != nil {
+			return nil, err
 		}
 	}
 
+	// Кодировщик JSON как у zap.NewProductionConfig
+	jsonConfig := zap.NewProductionEncoderConfig()
 	// Настройка формата времени
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	jsonConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	// Настройка формата длительности (например, "1.5s" вместо числа наносекунд)
-	cfg.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+	jsonConfig.EncodeDuration = zapcore.StringDurationEncoder
+
+	var consoleEncoder zapcore.Encoder
+	switch opts.Mode {
+	case "", ModeJSON:
+		consoleEncoder = zapcore.NewJSONEncoder(jsonConfig)
+	case ModeConsole:
+		devConfig := zap.NewDevelopmentEncoderConfig()
+		devConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		devConfig.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+		devConfig.EncodeDuration = zapcore.StringDurationEncoder
+		consoleEncoder = zapcore.NewConsoleEncoder(devConfig)
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов: %s", opts.Mode)
+	}
+	AddSecrets(opts.Secrets...)
+	consoleEncoder = &redactEncoder{Encoder: consoleEncoder}
+
+	// Уровни проверяет levelCore, сами выводы пишут все, что до них дошло
+	cores := []zapcore.Core{
+		sampled(zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stderr), zapcore.DebugLevel), opts),
+	}
+	if opts.File != "" {
+		maxSize := opts.FileMaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+
+		file := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    maxSize,
+			MaxBackups: opts.FileMaxBackups,
+			MaxAge:     opts.FileMaxAgeDays,
+			Compress:   true,
+		}
+		cores = append(cores, sampled(zapcore.NewCore(&redactEncoder{Encoder: zapcore.NewJSONEncoder(jsonConfig)}, zapcore.AddSync(file), zapcore.DebugLevel), opts))
+	}
+
+	core := &levelCore{Core: zapcore.NewTee(cores...), levels: levels}
 
 	// AddCallerSkil(1) нужен, чтобы в логах указывалось место вызова методов
 	// интерфейса, а не методов обертки zapLogger.
 	//
 	// Если ставить 1, то он ссылкой ведет прям туда где была ошибка (к примеру main:15)
 	// А если 0, то на самого себя. (к примеру logger:15)
-	l, err := cfg.Build(zap.AddCallerSkip(1))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при билде логгера: %w", err)
-	}
+	l := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.ErrorLevel))
 
 	return &zapLogger{logger: l}, nil
 }
 
+// sampled прореживает debug записи core, если прореживание включено
+func sampled(core zapcore.Core, opts Options) zapcore.Core {
+	if opts.SampleInitial <= 0 {
+		return core
+	}
+
+	return &debugSampler{
+		Core:    core,
+		sampler: zapcore.NewSamplerWithOptions(core, time.Second, opts.SampleInitial, opts.SampleThereafter),
+	}
+}
+
+// debugSampler пропускает debug записи через sampler, остальные пишет как есть:
+// ошибки и предупреждения терять нельзя, даже если их много
+type debugSampler struct {
+	zapcore.Core
+	sampler zapcore.Core
+}
+
+// With добавляет поля и в основной core, и в sampler
+func (c *debugSampler) With(fields []zapcore.Field) zapcore.Core {
+	return &debugSampler{Core: c.Core.With(fields), sampler: c.sampler.With(fields)}
+}
+
+// Check отдает debug записи sampler-у
+func (c *debugSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level == zapcore.DebugLevel {
+		return c.sampler.Check(ent, ce)
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
 // Debug логирует сообщение с уровнем Debug.
 func (l *zapLogger) Debug(msg string, fields ...Field) {
 	l.logger.Debug(msg, l.toZapFields(fields)...)
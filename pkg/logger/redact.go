@@ -0,0 +1,104 @@
+// Package logger маскировка секретов. Каждая строка лога перед записью
+// проходит через Redact: токены, пароли, ключи подписок и секретные
+// query string не должны попадать ни в консоль, ни в Loki.
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted чем заменяется секрет
+const Redacted = "[REDACTED]"
+
+// minSecretLen секреты короче не маскируем: "1" или "ru" заменились бы по всему логу
+const minSecretLen = 6
+
+// sensitiveKey имена полей с секретами: password, remna_key, trojanPassword,
+// vlessUuid, subscriptionUrl, X-API-Key и т.д.
+const sensitiveKey = `[A-Za-z0-9_.-]*(?i:password|passwd|token|secret|api_?key|remna_?key|authorization|cookie|vless_?uuid|subscription_?url|sub_?url)[A-Za-z0-9_.-]*`
+
+// redactRules шаблоны секретов в тексте. $1 - то, что оставляем перед маской
+var redactRules = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	// "password": "..." в JSON, в том числе JSON внутри строки ({\"password\":\"...\"})
+	{regexp.MustCompile(`(\\?"` + sensitiveKey + `\\?"\s*:\s*\\?")(?:[^"\\]|\\[^"])*`), "${1}" + Redacted},
+	// password=... в query string, form и логах вида ключ=значение
+	{regexp.MustCompile(`(\b` + sensitiveKey + `=)[^&\s"\\]+`), "${1}" + Redacted},
+	// Authorization: Bearer ...
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + Redacted},
+	// Токен бота в адресе Bot API: /bot123456:ABC.../getMe
+	{regexp.MustCompile(`(bot)\d+:[A-Za-z0-9_-]{20,}`), "${1}" + Redacted},
+	// Ссылка подписки Remnawave: https://panel/api/sub/<shortUuid>
+	{regexp.MustCompile(`(https?://[^\s"\\]+/sub/)[A-Za-z0-9_-]+`), "${1}" + Redacted},
+	// Query string в адресах. Секретный токен панели передается именно так
+	{regexp.MustCompile(`(https?://[^\s"\\?]+\?)[^\s"\\]+`), "${1}" + Redacted},
+}
+
+// secrets известные значения секретов из конфига, см. AddSecrets
+var secrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// AddSecrets запоминает значения, которые нужно маскировать где бы они ни встретились:
+// токены, ключи API, пароли из конфига. Пустые и слишком короткие пропускаются
+func AddSecrets(values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	for _, value := range values {
+		if len(value) >= minSecretLen {
+			secrets.values = append(secrets.values, value)
+		}
+	}
+}
+
+// Redact маскирует секреты в s. Для текста ошибок, который уходит
+// за пределы логов (ответы API, сообщения админам)
+func Redact(s string) string {
+	secrets.mu.RLock()
+	for _, value := range secrets.values {
+		s = strings.ReplaceAll(s, value, Redacted)
+	}
+	secrets.mu.RUnlock()
+
+	for _, rule := range redactRules {
+		s = rule.pattern.ReplaceAllString(s, rule.replace)
+	}
+
+	return s
+}
+
+// redactEncoder маскирует секреты в уже собранной строке лога.
+// Так маскируются и сообщения, и поля, и структуры, которые zap сериализовал сам
+type redactEncoder struct {
+	zapcore.Encoder
+}
+
+// Clone копия с полями из With, тоже с маскировкой
+func (e *redactEncoder) Clone() zapcore.Encoder {
+	return &redactEncoder{Encoder: e.Encoder.Clone()}
+}
+
+// EncodeEntry собирает строку и заменяет в ней секреты
+func (e *redactEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	line := buf.String()
+	if redactedLine := Redact(line); redactedLine != line {
+		buf.Reset()
+		buf.AppendString(redactedLine)
+	}
+
+	return buf, nil
+}
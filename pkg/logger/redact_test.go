@@ -0,0 +1,152 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newBufferLogger логгер с тем же кодировщиком, что в New, который пишет в buf
+func newBufferLogger(buf *bytes.Buffer, console bool) Logger {
+	var encoder zapcore.Encoder
+	if console {
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	} else {
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+
+	core := zapcore.NewCore(&redactEncoder{Encoder: encoder}, zapcore.AddSync(buf), zapcore.DebugLevel)
+
+	return &zapLogger{logger: zap.New(core)}
+}
+
+// panelUser пользователь панели, как его присылает Remnawave
+type panelUser struct {
+	UUID            string `json:"uuid"`
+	Username        string `json:"username"`
+	TrojanPassword  string `json:"trojanPassword"`
+	VlessUUID       string `json:"vlessUuid"`
+	SSPassword      string `json:"ssPassword"`
+	SubscriptionURL string `json:"subscriptionUrl"`
+}
+
+func TestRedactEncoder(t *testing.T) {
+	AddSecrets("config-secret-value")
+
+	user := panelUser{
+		UUID:            "user-uuid-visible",
+		Username:        "123456789",
+		TrojanPassword:  "trojan-secret-1",
+		VlessUUID:       "vless-secret-2",
+		SSPassword:      "ss-secret-3",
+		SubscriptionURL: "https://panel.example.com/api/sub/shortsecret4",
+	}
+
+	tests := []struct {
+		name string
+		log  func(l Logger)
+		// leaked секреты, которых не должно быть в строке лога
+		leaked []string
+		// kept что должно остаться как есть
+		kept []string
+	}{
+		{
+			name: "токен бота в ошибке Bot API",
+			log: func(l Logger) {
+				err := errors.New(`Post "https://api.telegram.org/bot123456:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw/getMe": timeout`)
+				l.Error("ошибка телеграма", Field{Key: "error", Value: err})
+			},
+			leaked: []string{"AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"},
+			kept:   []string{"api.telegram.org/bot", "getMe"},
+		},
+		{
+			name:   "пароли и ключи пользователя панели в структуре",
+			log:    func(l Logger) { l.Debug("ответ панели", Field{Key: "user", Value: user}) },
+			leaked: []string{"trojan-secret-1", "vless-secret-2", "ss-secret-3", "shortsecret4"},
+			kept:   []string{"user-uuid-visible", "123456789"},
+		},
+		{
+			name: "тело ответа панели строкой",
+			log: func(l Logger) {
+				body := `{"trojanPassword":"trojan-secret-1","vlessUuid":"vless-secret-2","ssPassword":"ss-secret-3"}`
+				l.Debug("ответ панели", Field{Key: "body", Value: body})
+			},
+			leaked: []string{"trojan-secret-1", "vless-secret-2", "ss-secret-3"},
+		},
+		{
+			name: "ссылка подписки в сообщении",
+			log: func(l Logger) {
+				l.Info("подписка выдана: https://panel.example.com/api/sub/shortsecret4")
+			},
+			leaked: []string{"shortsecret4"},
+			kept:   []string{"https://panel.example.com/api/sub/"},
+		},
+		{
+			name: "секретные параметры в адресе",
+			log: func(l Logger) {
+				l.Warn("запрос", Field{Key: "url", Value: "https://panel.example.com/api/users?caddy_token=query-secret-5&page=1"})
+			},
+			leaked: []string{"query-secret-5"},
+			kept:   []string{"https://panel.example.com/api/users?"},
+		},
+		{
+			name: "заголовок Authorization",
+			log: func(l Logger) {
+				l.Debug("запрос", Field{Key: "headers", Value: "Authorization: Bearer bearer-secret-6"})
+			},
+			leaked: []string{"bearer-secret-6"},
+		},
+		{
+			name: "секрет из конфига в поле With",
+			log: func(l Logger) {
+				l.With(Field{Key: "dsn", Value: "postgres://bot:config-secret-value@db/bot"}).Info("подключение")
+			},
+			leaked: []string{"config-secret-value"},
+			kept:   []string{"postgres://bot:"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, console := range []bool{false, true} {
+			mode := ModeJSON
+			if console {
+				mode = ModeConsole
+			}
+
+			t.Run(tt.name+"/"+mode, func(t *testing.T) {
+				var buf bytes.Buffer
+				tt.log(newBufferLogger(&buf, console))
+
+				line := buf.String()
+				for _, secret := range tt.leaked {
+					if strings.Contains(line, secret) {
+						t.Errorf("в логе остался %q: %s", secret, line)
+					}
+				}
+				for _, value := range tt.kept {
+					if !strings.Contains(line, value) {
+						t.Errorf("в логе нет %q: %s", value, line)
+					}
+				}
+				if len(tt.leaked) > 0 && !strings.Contains(line, Redacted) {
+					t.Errorf("в логе нет %s: %s", Redacted, line)
+				}
+			})
+		}
+	}
+}
+
+func TestRedactShortSecrets(t *testing.T) {
+	AddSecrets("ru", "")
+
+	var buf bytes.Buffer
+	newBufferLogger(&buf, false).Info("язык ru")
+
+	if strings.Contains(buf.String(), Redacted) {
+		t.Errorf("короткое значение замаскировано: %s", buf.String())
+	}
+}
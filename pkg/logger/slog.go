@@ -0,0 +1,125 @@
+// Package logger мост из log/slog в Logger: библиотеки, которые пишут
+// через slog или стандартный log, попадают в тот же JSON, что и наш код.
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler slog.Handler поверх Logger
+type slogHandler struct {
+	logger Logger
+	// prefix имя открытой группы с точкой: "http." для slog.Group("http", ...)
+	prefix string
+}
+
+// NewSlogHandler обработчик slog, который пишет в l.
+// Подключается через slog.SetDefault(slog.New(logger.NewSlogHandler(l)))
+func NewSlogHandler(l Logger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// Enabled пишет ли l записи уровня level. Для zap решает его уровень
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if zl, ok := h.logger.(*zapLogger); ok {
+		return zl.logger.Core().Enabled(zapLevel(level))
+	}
+
+	return true
+}
+
+// Handle переносит запись slog в Logger вместе с местом вызова
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make([]Field, 0, record.NumAttrs()+1)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	l := FromContext(ctx, h.logger)
+	// Свой caller у zap указал бы на этот файл, поэтому берем место вызова из записи.
+	// У строк из стандартного log его нет
+	if zl, ok := l.(*zapLogger); ok {
+		l = &zapLogger{logger: zl.logger.WithOptions(zap.WithCaller(false))}
+	}
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		fields = append(fields, Field{
+			Key:   "caller",
+			Value: zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true).TrimmedPath(),
+		})
+	}
+
+	switch {
+	case record.Level >= slog.LevelError:
+		l.Error(record.Message, fields...)
+	case record.Level >= slog.LevelWarn:
+		l.Warn(record.Message, fields...)
+	case record.Level >= slog.LevelInfo:
+		l.Info(record.Message, fields...)
+	default:
+		l.Debug(record.Message, fields...)
+	}
+
+	return nil
+}
+
+// WithAttrs обработчик с постоянными полями
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendAttr(fields, h.prefix, attr)
+	}
+
+	return &slogHandler{logger: h.logger.With(fields...), prefix: h.prefix}
+}
+
+// WithGroup обработчик, у которого ключи следующих полей идут с префиксом name.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+// appendAttr добавляет поле slog к fields. Группы раскрываются в ключи через точку
+func appendAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	value := attr.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		if attr.Key == "" {
+			return fields
+		}
+
+		return append(fields, Field{Key: prefix + attr.Key, Value: value.Any()})
+	}
+
+	// Группа без имени - поля на текущем уровне
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	for _, nested := range value.Group() {
+		fields = appendAttr(fields, prefix, nested)
+	}
+
+	return fields
+}
+
+// zapLevel уровень zap для уровня slog
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	}
+
+	return zapcore.DebugLevel
+}
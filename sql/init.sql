@@ -4,6 +4,14 @@ CREATE TABLE users (
     id VARCHAR(20) NOT NULL UNIQUE, -- ID телеграмма 8-10 символов, но берем про запас
     balance INTEGER,
     trial BOOLEAN NOT NULL DEFAULT FALSE,
+    banned BOOLEAN NOT NULL DEFAULT FALSE, -- забаненным бот не отвечает
+    language VARCHAR(8) NOT NULL DEFAULT '', -- выбранный язык, пусто - язык из телеграма
+    region VARCHAR(36) NOT NULL DEFAULT '', -- UUID сквада панели (регион), пусто - сквад по умолчанию
+    extra_devices INTEGER NOT NULL DEFAULT 0, -- устройства, купленные сверх подписки
+    traffic_notified INTEGER NOT NULL DEFAULT 0, -- порог последнего предупреждения о трафике, %
+    traffic_packs_gb INTEGER NOT NULL DEFAULT 0, -- пакеты трафика, купленные в текущем периоде
+    traffic_packs_at TIMESTAMP, -- когда куплен первый пакет периода, NULL - пакетов нет
+    email VARCHAR(254) UNIQUE, -- email для входа на сайт, NULL у пользователей только из телеграма
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -11,9 +19,62 @@ CREATE TABLE transactions (
     id VARCHAR(36) PRIMARY KEY, -- UUID транзакции
     user_id VARCHAR(20) NOT NULL, -- ID пользователя
     amount INTEGER NOT NULL, -- Сумма пополнения
-    status VARCHAR(20) NOT NULL, -- Статус: pending, success, failed
-    provider VARCHAR(50) NOT NULL, -- Провайдер платежа
+    fee INTEGER NOT NULL DEFAULT 0, -- Комиссия платежки сверх суммы, пользователь платит amount + fee
+    status VARCHAR(20) NOT NULL, -- Статус: pending, paid (получена, не зачислена), success, failed, refunded
+    provider VARCHAR(50) NOT NULL, -- Провайдер платежа (ID из реестра платежек)
     external_id VARCHAR(100), -- ID транзакции в платежной системе
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+
+-- Заказы подписки с сайта: тариф и транзакция, которой его оплачивают.
+-- Подписка активируется один раз при переходе paid -> activated
+CREATE TABLE orders (
+    id VARCHAR(36) PRIMARY KEY, -- UUID заказа
+    user_id VARCHAR(20) NOT NULL, -- ID пользователя
+    months INTEGER NOT NULL, -- Срок подписки
+    price INTEGER NOT NULL, -- Цена тарифа в рублях
+    transaction_id VARCHAR(36), -- Транзакция пополнения, NULL если хватило баланса
+    payment_url TEXT NOT NULL DEFAULT '', -- Ссылка на оплату от платежки
+    status VARCHAR(20) NOT NULL, -- Статус: pending, paid, activated, failed
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ручные изменения баланса через admin API: кто, на сколько и почему
+CREATE TABLE balance_adjustments (
+    id VARCHAR(36) PRIMARY KEY, -- UUID записи
+    user_id VARCHAR(20) NOT NULL, -- ID пользователя
+    delta INTEGER NOT NULL, -- Изменение баланса в рублях, может быть отрицательным
+    reason TEXT NOT NULL, -- Причина
+    actor VARCHAR(64) NOT NULL, -- Имя ключа admin API
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Состояние бота: последний обработанный update_id и т.п.
+CREATE TABLE bot_state (
+    key VARCHAR(50) PRIMARY KEY,
+    value BIGINT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ключи идемпотентности: id callback query, которые уже обработали.
+-- Нужно, чтобы повторный create_user_N не списал деньги дважды.
+-- Телеграм не присылает callback повторно через сутки, поэтому старые
+-- ключи бот удаляет сам (DeleteProcessedCallbacks)
+CREATE TABLE processed_callbacks (
+    callback_id VARCHAR(64) PRIMARY KEY, -- id callback query от телеграма
+    user_id VARCHAR(20) NOT NULL, -- ID пользователя
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_processed_callbacks_created_at ON processed_callbacks (created_at);
+
+-- Настройки, которые меняются без рестарта (/set в боте).
+-- Нет строки - значение из конфига или значение по умолчанию
+CREATE TABLE settings (
+    key VARCHAR(50) PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_by VARCHAR(64) NOT NULL DEFAULT '', -- Кто изменил: telegram id админа
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);